@@ -5,15 +5,17 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 )
 
 func main() {
 	endpoint := flag.String("endpoint", "opc.tcp://localhost:4840", "OPC UA server endpoint")
-	nodeID := flag.String("node", "ns=2;i=1000", "Node ID to read (e.g., ns=2;i=1000)")
+	nodeID := flag.String("node", "nsu=urn:go-opcua-sim:nodes;i=1000", "Node ID to read (e.g., ns=2;i=1000 or nsu=urn:go-opcua-sim:nodes;s=Tank1_Level)")
 	continuous := flag.Bool("continuous", false, "Continuously read values")
 	interval := flag.Int("interval", 1000, "Read interval in milliseconds (for continuous mode)")
 	flag.Parse()
@@ -37,8 +39,8 @@ func main() {
 
 	fmt.Println("Connected successfully!")
 
-	// Parse node ID
-	nid, err := ua.ParseNodeID(*nodeID)
+	// Parse node ID, namespace URIs (nsu=) are resolved with the server NamespaceArray
+	nid, err := resolveNodeID(ctx, client, *nodeID)
 	if err != nil {
 		log.Fatalf("Failed to parse node ID: %v", err)
 	}
@@ -59,6 +61,35 @@ func main() {
 	}
 }
 
+// resolveNodeID parses a node ID, resolving a namespace URI to its index on the connected server
+func resolveNodeID(ctx context.Context, client *opcua.Client, nodeID string) (*ua.NodeID, error) {
+	if !strings.HasPrefix(nodeID, "nsu=") {
+		return ua.ParseNodeID(nodeID)
+	}
+
+	resp, err := client.Read(ctx, &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: ua.NewNumericNodeID(0, id.Server_NamespaceArray), AttributeID: ua.AttributeIDValue},
+		},
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read NamespaceArray: %w", err)
+	}
+	namespaces, ok := resp.Results[0].Value.Value().([]string)
+	if !ok {
+		return nil, fmt.Errorf("read NamespaceArray: %v", resp.Results[0].Status)
+	}
+
+	uri, identifier, _ := strings.Cut(strings.TrimPrefix(nodeID, "nsu="), ";")
+	for index, namespace := range namespaces {
+		if namespace == uri {
+			return ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", index, identifier))
+		}
+	}
+	return nil, fmt.Errorf("namespace %s not found in the server NamespaceArray", uri)
+}
+
 func readAndPrintValue(ctx context.Context, client *opcua.Client, nodeID *ua.NodeID) {
 	// Create read request
 	req := &ua.ReadRequest{
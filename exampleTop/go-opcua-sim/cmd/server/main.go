@@ -7,8 +7,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
-	"time"
 
 	"go-opcua-sim/internal/config"
 	"go-opcua-sim/internal/opcuaserver"
@@ -23,8 +23,33 @@ func main() {
 	scanTimeMs := flag.Int("scantime", 100, "PLC scan time in milliseconds")
 	enablePLC := flag.Bool("plc", true, "Enable PLC Lua logic execution")
 	endpoint := flag.String("endpoint", "opc.tcp://0.0.0.0:4840", "OPC UA server endpoint")
+	defaultSecurity := opcuaserver.DefaultSecurityConfig()
+	allowNone := flag.Bool("security-none", defaultSecurity.AllowNone, "Offer the None security policy (the secure endpoints are always offered)")
+	pkiDir := flag.String("pki", defaultSecurity.PKIDir, "PKI directory (server.crt/key, trusted/, issuers/, rejected/)")
+	strictCerts := flag.Bool("strict", false, "Reject client certificates that are not in the trusted store")
+	usersFile := flag.String("users", "", "Path to user credentials file (enables username/certificate login)")
+	allowAnonymous := flag.Bool("anonymous", true, "Allow anonymous sessions")
+	anonymousWrite := flag.Bool("anonymous-write", false, "Allow anonymous sessions to write tags and call methods")
+	historyDepth := flag.Int("history", 10000, "Value changes kept per tag for HistoryRead (0 = disabled)")
+	exportNodeSet := flag.String("export-nodeset", "", "Write the address space as NodeSet2 XML to this file at startup and after model changes")
+	chaosSchedule := flag.String("chaos-schedule", "", "Path to fault injection schedule file (enables the chaos proxy)")
+	chaosAPI := flag.String("chaos-api", "", "HTTP address of the fault injection control API, e.g. 127.0.0.1:4850 (enables the chaos proxy)")
+	hashPassword := flag.String("hash-password", "", "Print the bcrypt hash of a password for the users file and exit")
 	flag.Parse()
 
+	if *hashPassword != "" {
+		hash, err := config.HashPassword(*hashPassword)
+		if err != nil {
+			log.Fatalf("Failed to hash password: %v", err)
+		}
+		fmt.Println(hash)
+		return
+	}
+
+	if *historyDepth < 0 {
+		log.Fatalf("-history must not be negative: %d", *historyDepth)
+	}
+
 	fmt.Println("=== Go OPC UA PLC Simulation Server ===")
 
 	// Load sensor configuration
@@ -34,6 +59,31 @@ func main() {
 	}
 	fmt.Printf("[CONFIG] Loaded %d sensor definitions\n", len(cfg.Sensors))
 
+	// Load user credentials (optional)
+	var users *config.UserConfig
+	if *usersFile != "" {
+		users, err = config.LoadUsers(*usersFile)
+		if err != nil {
+			log.Fatalf("Failed to load users: %v", err)
+		}
+		fmt.Printf("[CONFIG] Loaded %d users, %d user certificates\n", len(users.Users), len(users.Certificates))
+	} else if !*allowAnonymous {
+		log.Fatalf("-anonymous=false requires a -users file")
+	}
+
+	// Fault injection (optional)
+	var chaos *opcuaserver.ChaosConfig
+	if *chaosSchedule != "" || *chaosAPI != "" {
+		chaos = &opcuaserver.ChaosConfig{APIAddress: *chaosAPI}
+		if *chaosSchedule != "" {
+			chaos.Schedule, err = config.LoadChaosSchedule(*chaosSchedule)
+			if err != nil {
+				log.Fatalf("Failed to load chaos schedule: %v", err)
+			}
+			fmt.Printf("[CONFIG] Loaded chaos schedule with %d steps\n", len(chaos.Schedule.Steps))
+		}
+	}
+
 	// Generate tags from sensor definitions
 	tagManager, err := plc.GenerateTagsFromSensors(cfg.Sensors)
 	if err != nil {
@@ -50,7 +100,7 @@ func main() {
 	}
 
 	// Start sensor simulation
-	sensorManager.Start(100 * time.Millisecond)
+	sensorManager.Start(sim.UpdateCycle)
 	defer sensorManager.Stop()
 
 	// Initialize PLC Lua Engine (if enabled)
@@ -81,8 +131,33 @@ func main() {
 		fmt.Printf("[PLC] Lua engine started (scan time: %dms)\n", *scanTimeMs)
 	}
 
+	// Reload applies the changed sensors of the configuration file (SIGHUP and ReloadConfig method)
+	reload := func() error {
+		return reloadConfig(*configFile, cfg, sensorManager)
+	}
+
 	// Create and start OPC UA server
-	opcuaServer := opcuaserver.NewOPCUAServer(*endpoint, tagManager)
+	opcuaServer := opcuaserver.NewOPCUAServer(opcuaserver.Config{
+		Endpoint: *endpoint,
+		Security: opcuaserver.SecurityConfig{
+			AllowNone: *allowNone,
+			PKIDir:    *pkiDir,
+			Strict:    *strictCerts,
+		},
+		Users:          users,
+		Anonymous:      *allowAnonymous,
+		AnonymousWrite: *anonymousWrite,
+		History:        *historyDepth,
+		Reload:         reload,
+		ExportTo:       *exportNodeSet,
+		Chaos:          chaos,
+		Limits:         cfg.ServerLimits,
+		Locales:        cfg.Locales,
+
+		NamespaceURI:     cfg.NamespaceURI,
+		DeviceNamespaces: cfg.DeviceNamespaces,
+		NodeSets:         cfg.NodeSets,
+	}, tagManager, sensorManager)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -93,11 +168,37 @@ func main() {
 		}
 	}()
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, reload the configuration on SIGHUP
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	<-sigs
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if err := reload(); err != nil {
+			log.Printf("[CONFIG] Reload failed: %v", err)
+		}
+	}
 
 	fmt.Println("\nShutting down...")
 	opcuaServer.Stop()
 }
+
+// reloadConfig loads the configuration file again and applies the added, removed and changed sensors.
+// Namespace settings and server limits are fixed at startup.
+func reloadConfig(filename string, current *config.SensorConfig, sensorManager *sim.SensorManager) error {
+	cfg, err := config.LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+	if cfg.NamespaceURI != current.NamespaceURI || !reflect.DeepEqual(cfg.DeviceNamespaces, current.DeviceNamespaces) ||
+		!reflect.DeepEqual(cfg.NodeSets, current.NodeSets) || !reflect.DeepEqual(cfg.ServerLimits, current.ServerLimits) ||
+		!reflect.DeepEqual(cfg.Locales, current.Locales) {
+		log.Printf("[CONFIG] namespaceUri/deviceNamespaces/nodeSets/serverLimits/locales changed, restart the server to apply them")
+	}
+
+	changes, err := sensorManager.ApplyConfig(cfg)
+	fmt.Printf("[CONFIG] Reloaded %s: %d added, %d removed, %d replaced\n",
+		filename, len(changes.Added), len(changes.Removed), len(changes.Replaced))
+	return err
+}
@@ -5,6 +5,7 @@ go 1.23.8
 require (
 	github.com/gopcua/opcua v0.8.0
 	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/crypto v0.31.0
 )
 
 require (
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Chaos faults injected into the OPC UA server
+const (
+	FaultDropSessions   = "dropSessions"   // delete the sessions used on channels with the None security policy
+	FaultDisconnect     = "disconnect"     // close all client connections, sessions are kept
+	FaultExpireChannels = "expireChannels" // close channels whose token is not renewed within lifetimeMs
+	FaultCloseListener  = "closeListener"  // close the connections and refuse new ones
+	FaultDelayResponses = "delayResponses" // hold back server messages for delayMs
+	FaultDropResponses  = "dropResponses"  // discard whole server messages, later ones arrive with a sequence number gap
+	FaultRefuseSessions = "refuseSessions" // fail session activation with Bad_TooManySessions
+	FaultSensorQuality  = "sensorQuality"  // set the quality of sensors, restored when the fault ends
+)
+
+// ChaosSchedule represents the fault injection schedule file
+type ChaosSchedule struct {
+	RepeatMs int         `json:"repeatMs,omitempty"` // period the schedule starts over with, 0 = run once
+	Steps    []ChaosStep `json:"steps"`
+}
+
+// ChaosStep is a fault injected at a fixed time after the server start
+type ChaosStep struct {
+	AtMs int `json:"atMs"`
+	ChaosFault
+}
+
+// ChaosFault defines a fault and how long it lasts
+type ChaosFault struct {
+	Fault      string   `json:"fault"`
+	DurationMs int      `json:"durationMs,omitempty"` // timed faults, 0 = until cleared
+	DelayMs    int      `json:"delayMs,omitempty"`    // delayResponses
+	LifetimeMs int      `json:"lifetimeMs,omitempty"` // expireChannels: security token lifetime
+	Quality    string   `json:"quality,omitempty"`    // sensorQuality: quality name (e.g. "Bad_SensorFailure")
+	Sensors    []string `json:"sensors,omitempty"`    // sensorQuality: affected sensors, empty = all
+}
+
+// LoadChaosSchedule loads a fault injection schedule from a JSON file
+func LoadChaosSchedule(filename string) (*ChaosSchedule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chaos schedule: %w", err)
+	}
+
+	var schedule ChaosSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return nil, fmt.Errorf("failed to parse chaos schedule JSON: %w", err)
+	}
+
+	if err := validateChaosSchedule(&schedule); err != nil {
+		return nil, fmt.Errorf("invalid chaos schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// validateChaosSchedule validates the steps of a schedule
+func validateChaosSchedule(schedule *ChaosSchedule) error {
+	if len(schedule.Steps) == 0 {
+		return fmt.Errorf("no steps defined")
+	}
+	if schedule.RepeatMs < 0 {
+		return fmt.Errorf("repeatMs must not be negative: %d", schedule.RepeatMs)
+	}
+
+	for i, step := range schedule.Steps {
+		if step.AtMs < 0 {
+			return fmt.Errorf("step %d: atMs must not be negative: %d", i, step.AtMs)
+		}
+		if schedule.RepeatMs > 0 && step.AtMs >= schedule.RepeatMs {
+			return fmt.Errorf("step %d: atMs %d is not within repeatMs %d", i, step.AtMs, schedule.RepeatMs)
+		}
+		if err := step.ChaosFault.Validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the fault name and its parameters
+func (f ChaosFault) Validate() error {
+	switch f.Fault {
+	case FaultDropSessions, FaultDisconnect:
+		if f.DurationMs != 0 {
+			return fmt.Errorf("%s happens once and takes no durationMs", f.Fault)
+		}
+	case FaultCloseListener, FaultDropResponses, FaultRefuseSessions:
+	case FaultDelayResponses:
+		if f.DelayMs <= 0 {
+			return fmt.Errorf("%s requires a positive delayMs", f.Fault)
+		}
+	case FaultExpireChannels:
+		if f.LifetimeMs <= 0 {
+			return fmt.Errorf("%s requires a positive lifetimeMs", f.Fault)
+		}
+	case FaultSensorQuality:
+		if f.Quality == "" {
+			return fmt.Errorf("%s requires a quality", f.Fault)
+		}
+	default:
+		return fmt.Errorf("unknown fault '%s'", f.Fault)
+	}
+	if f.DurationMs < 0 {
+		return fmt.Errorf("durationMs must not be negative: %d", f.DurationMs)
+	}
+	return nil
+}
@@ -4,11 +4,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// ControlFolder is the top-level folder holding the sensor control objects (reserved browse name)
+const ControlFolder = "Sensors"
+
+// MaxArrayLength is the largest supported length of an array tag
+const MaxArrayLength = 65536
+
+// Access modes of a sensor tag for clients
+const (
+	AccessReadWrite = "rw"
+	AccessReadOnly  = "r"
+)
+
+// DefaultNamespaceURI is the namespace of the simulator nodes when the configuration does not set one
+const DefaultNamespaceURI = "urn:go-opcua-sim:nodes"
+
 // SensorConfig represents the complete sensor configuration
 type SensorConfig struct {
-	Sensors []SensorDefinition `json:"sensors"`
+	NamespaceURI     string             `json:"namespaceUri,omitempty"`     // namespace of the simulator nodes, default DefaultNamespaceURI
+	DeviceNamespaces map[string]string  `json:"deviceNamespaces,omitempty"` // folder/device browse path -> namespace of it and all nodes below
+	NodeSets         []string           `json:"nodeSets,omitempty"`         // NodeSet2 XML files imported into the address space, relative to the config file
+	ServerLimits     *ServerLimits      `json:"serverLimits,omitempty"`     // session, subscription, operation and message limits
+	Locales          []string           `json:"locales,omitempty"`          // locales of the localized texts in order of preference, default "en"
+	Sensors          []SensorDefinition `json:"sensors"`
 }
 
 // SensorDefinition defines a single sensor
@@ -17,9 +40,53 @@ type SensorDefinition struct {
 	Type             string                 `json:"type"`
 	Enabled          bool                   `json:"enabled"`
 	Address          string                 `json:"address"`
+	BrowsePath       string                 `json:"browsePath,omitempty"` // e.g. "Plant/Tank1/Temperature"
 	UpdateIntervalMs int                    `json:"updateIntervalMs"`
+	DataType         string                 `json:"dataType,omitempty"`    // OPC UA built-in type (e.g. "UInt16"), empty = from address
+	ArrayLength      int                    `json:"arrayLength,omitempty"` // elements of a one-dimensional array tag, 0 = scalar
 	Parameters       map[string]interface{} `json:"parameters"`
 	Description      string                 `json:"description"`
+	DisplayNames     map[string]string      `json:"displayNames,omitempty"`     // locale -> DisplayName, default = browse name
+	Descriptions     map[string]string      `json:"descriptions,omitempty"`     // locale -> Description, default = description
+	Access           string                 `json:"access,omitempty"`           // "rw" or "r", default by sensor type (TagAccess)
+	Permissions      map[string]string      `json:"permissions,omitempty"`      // role -> "rw", "r" or "none"
+	Alarms           *AlarmLimits           `json:"alarms,omitempty"`           // limit alarm (analog tags)
+	EngineeringUnits string                 `json:"engineeringUnits,omitempty"` // UNECE common code (e.g. "CEL"), analog tags
+	EURange          *Range                 `json:"euRange,omitempty"`          // normal operating range, default = instrument range
+	InstrumentRange  *Range                 `json:"instrumentRange,omitempty"`  // sensor output range, default = from the sensor parameters
+	DeadbandPercent  float64                `json:"deadbandPercent,omitempty"`  // changes within this percent of the EURange are not published
+	NodeID           string                 `json:"nodeId,omitempty"`           // NodeId identifier (e.g. "i=1000"), default = "s=<name>"
+	NamespaceURI     string                 `json:"namespaceUri,omitempty"`     // namespace of nodeId, default = simulator namespace
+	Aliases          []string               `json:"aliases,omitempty"`          // additional NodeIds of the tag (e.g. "ns=2;i=1000")
+}
+
+// TagAccess returns the access mode of the sensor tag. Without access set, measurements are read-only
+// and actuators and memory variables, whose values clients write, are read-write.
+func (s SensorDefinition) TagAccess() string {
+	if s.Access != "" {
+		return s.Access
+	}
+	switch s.Type {
+	case "temperature", "pressure", "sine", "random", "digital", "vibration", "noise", "waveform", "vibrationwaveform":
+		return AccessReadOnly
+	default:
+		return AccessReadWrite
+	}
+}
+
+// Range is a value range of an analog tag
+type Range struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// AlarmLimits defines the HiHi/Hi/Lo/LoLo limits of an exclusive limit alarm (unset = no limit)
+type AlarmLimits struct {
+	HighHigh *float64 `json:"highHigh,omitempty"`
+	High     *float64 `json:"high,omitempty"`
+	Low      *float64 `json:"low,omitempty"`
+	LowLow   *float64 `json:"lowLow,omitempty"`
+	Deadband float64  `json:"deadband,omitempty"` // hysteresis before an alarm level is left
 }
 
 // LoadConfig loads sensor configuration from a JSON file
@@ -39,9 +106,23 @@ func LoadConfig(filename string) (*SensorConfig, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	if config.NamespaceURI == "" {
+		config.NamespaceURI = DefaultNamespaceURI
+	}
+	for i, path := range config.NodeSets {
+		if !filepath.IsAbs(path) {
+			config.NodeSets[i] = filepath.Join(filepath.Dir(filename), path)
+		}
+	}
+
 	return &config, nil
 }
 
+// Validate validates a sensor configuration, e.g. one changed at runtime
+func Validate(config *SensorConfig) error {
+	return validateConfig(config)
+}
+
 // validateConfig validates the sensor configuration
 func validateConfig(config *SensorConfig) error {
 	if len(config.Sensors) == 0 {
@@ -50,6 +131,7 @@ func validateConfig(config *SensorConfig) error {
 
 	addressMap := make(map[string]bool)
 	nameMap := make(map[string]bool)
+	browsePathMap := make(map[string]string)
 
 	for i, sensor := range config.Sensors {
 		// Check required fields
@@ -66,6 +148,10 @@ func validateConfig(config *SensorConfig) error {
 			return fmt.Errorf("sensor '%s' has invalid updateIntervalMs: %d", sensor.Name, sensor.UpdateIntervalMs)
 		}
 
+		if sensor.ArrayLength < 0 || sensor.ArrayLength > MaxArrayLength {
+			return fmt.Errorf("sensor '%s' has invalid arrayLength: %d (expected 0-%d)", sensor.Name, sensor.ArrayLength, MaxArrayLength)
+		}
+
 		// Check for duplicate names
 		if nameMap[sensor.Name] {
 			return fmt.Errorf("duplicate sensor name: %s", sensor.Name)
@@ -82,8 +168,185 @@ func validateConfig(config *SensorConfig) error {
 		if len(sensor.Address) < 4 || sensor.Address[0] != '%' {
 			return fmt.Errorf("sensor '%s' has invalid address format: %s (expected format: %%DFxxx or %%DWxxx)", sensor.Name, sensor.Address)
 		}
+
+		// Validate browse path
+		if sensor.BrowsePath != "" {
+			for _, segment := range strings.Split(sensor.BrowsePath, "/") {
+				if segment == "" {
+					return fmt.Errorf("sensor '%s' has invalid browse path: %s", sensor.Name, sensor.BrowsePath)
+				}
+			}
+			if other, exists := browsePathMap[sensor.BrowsePath]; exists {
+				return fmt.Errorf("duplicate browse path: %s (used by %s and %s)", sensor.BrowsePath, other, sensor.Name)
+			}
+			browsePathMap[sensor.BrowsePath] = sensor.Name
+		}
+
+		if sensor.Access != "" && sensor.Access != AccessReadWrite && sensor.Access != AccessReadOnly {
+			return fmt.Errorf("sensor '%s' has invalid access: %s (expected rw or r)", sensor.Name, sensor.Access)
+		}
+
+		for _, texts := range []map[string]string{sensor.DisplayNames, sensor.Descriptions} {
+			for locale, text := range texts {
+				if locale == "" || text == "" {
+					return fmt.Errorf("sensor '%s' has an empty locale or text: %q: %q", sensor.Name, locale, text)
+				}
+			}
+		}
+
+		// Validate role permissions, role names are compared in lowercase
+		permissions := make(map[string]bool, len(sensor.Permissions))
+		for role, access := range sensor.Permissions {
+			normalized := NormalizeRole(role)
+			if normalized == "" {
+				return fmt.Errorf("sensor '%s' has a permission with empty role", sensor.Name)
+			}
+			if permissions[normalized] {
+				return fmt.Errorf("sensor '%s' has duplicate permissions for role %s", sensor.Name, normalized)
+			}
+			if access != "rw" && access != "r" && access != "none" {
+				return fmt.Errorf("sensor '%s' has invalid permission for role %s: %s (expected rw, r or none)", sensor.Name, role, access)
+			}
+			permissions[normalized] = true
+		}
 	}
 
+	// Validate alarm limits
+	for _, sensor := range config.Sensors {
+		if sensor.Alarms != nil {
+			if sensor.ArrayLength > 0 {
+				return fmt.Errorf("sensor '%s' is an array tag and cannot have alarms", sensor.Name)
+			}
+			if err := validateAlarmLimits(sensor.Alarms); err != nil {
+				return fmt.Errorf("sensor '%s' has invalid alarms: %w", sensor.Name, err)
+			}
+		}
+	}
+
+	// Validate engineering units and ranges
+	for _, sensor := range config.Sensors {
+		if sensor.EngineeringUnits != "" {
+			if _, err := LookupUnit(sensor.EngineeringUnits); err != nil {
+				return fmt.Errorf("sensor '%s' has invalid engineeringUnits: %w", sensor.Name, err)
+			}
+		}
+		if err := validateRange(sensor.EURange); err != nil {
+			return fmt.Errorf("sensor '%s' has invalid euRange: %w", sensor.Name, err)
+		}
+		if err := validateRange(sensor.InstrumentRange); err != nil {
+			return fmt.Errorf("sensor '%s' has invalid instrumentRange: %w", sensor.Name, err)
+		}
+		if sensor.DeadbandPercent < 0 || sensor.DeadbandPercent > 100 {
+			return fmt.Errorf("sensor '%s' has invalid deadbandPercent %g (0-100)", sensor.Name, sensor.DeadbandPercent)
+		}
+	}
+
+	// Device namespaces must name a folder or device object of the browse paths
+	devicePaths := make([]string, 0, len(config.DeviceNamespaces))
+	for path := range config.DeviceNamespaces {
+		devicePaths = append(devicePaths, path)
+	}
+	sort.Strings(devicePaths)
+	for _, path := range devicePaths {
+		if config.DeviceNamespaces[path] == "" {
+			return fmt.Errorf("device namespace of %s has an empty URI", path)
+		}
+		found := false
+		for _, sensor := range config.Sensors {
+			if strings.HasPrefix(sensor.BrowsePath, path+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("device namespace path %s is not a folder or device of any browse path", path)
+		}
+	}
+
+	// Validate explicit NodeIds and aliases (uniqueness is checked when the nodes are created)
+	for _, sensor := range config.Sensors {
+		if sensor.NodeID != "" {
+			if err := validateNodeID(sensor.NodeID); err != nil {
+				return fmt.Errorf("sensor '%s' has invalid nodeId: %w", sensor.Name, err)
+			}
+		}
+		for _, alias := range sensor.Aliases {
+			if err := validateAlias(alias); err != nil {
+				return fmt.Errorf("sensor '%s' has invalid alias: %w", sensor.Name, err)
+			}
+		}
+	}
+
+	// Folders and tags share the namespace, so a folder path must not be a tag browse path or name
+	for _, sensor := range config.Sensors {
+		if sensor.Name == ControlFolder {
+			return fmt.Errorf("sensor name %s is reserved for the sensor control folder", ControlFolder)
+		}
+		segments := strings.Split(sensor.BrowsePath, "/")
+		if segments[0] == ControlFolder {
+			return fmt.Errorf("browse path %s of sensor '%s' uses the reserved folder %s", sensor.BrowsePath, sensor.Name, ControlFolder)
+		}
+		for i := 1; i < len(segments); i++ {
+			folder := strings.Join(segments[:i], "/")
+			if other, exists := browsePathMap[folder]; exists {
+				return fmt.Errorf("browse path %s of sensor '%s' is also a folder of sensor '%s'", folder, other, sensor.Name)
+			}
+			if nameMap[folder] {
+				return fmt.Errorf("folder %s of sensor '%s' conflicts with a sensor name", folder, sensor.Name)
+			}
+		}
+	}
+
+	if config.ServerLimits != nil {
+		if err := validateServerLimits(config.ServerLimits); err != nil {
+			return fmt.Errorf("invalid serverLimits: %w", err)
+		}
+	}
+
+	locales := make(map[string]bool)
+	for _, locale := range config.Locales {
+		if locale == "" {
+			return fmt.Errorf("locales contains an empty locale")
+		}
+		if locales[strings.ToLower(locale)] {
+			return fmt.Errorf("duplicate locale: %s", locale)
+		}
+		locales[strings.ToLower(locale)] = true
+	}
+
+	return nil
+}
+
+// validateAlarmLimits checks that the limits are ordered LowLow < Low < High < HighHigh
+func validateAlarmLimits(limits *AlarmLimits) error {
+	ordered := []*float64{limits.LowLow, limits.Low, limits.High, limits.HighHigh}
+	names := []string{"lowLow", "low", "high", "highHigh"}
+
+	var last *float64
+	lastName := ""
+	for i, limit := range ordered {
+		if limit == nil {
+			continue
+		}
+		if last != nil && *limit <= *last {
+			return fmt.Errorf("%s (%g) must be greater than %s (%g)", names[i], *limit, lastName, *last)
+		}
+		last, lastName = limit, names[i]
+	}
+	if last == nil {
+		return fmt.Errorf("no limits defined")
+	}
+	if limits.Deadband < 0 {
+		return fmt.Errorf("deadband must not be negative: %g", limits.Deadband)
+	}
+	return nil
+}
+
+// validateRange checks that a range (if set) is not empty
+func validateRange(r *Range) error {
+	if r != nil && r.Low >= r.High {
+		return fmt.Errorf("low (%g) must be less than high (%g)", r.Low, r.High)
+	}
 	return nil
 }
 
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testSensor returns a valid sensor definition
+func testSensor(name, address string) SensorDefinition {
+	return SensorDefinition{Name: name, Type: "memory", Enabled: true, Address: address, UpdateIntervalMs: 100}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions map[string]string
+		err         string
+	}{
+		{"mixed case roles", map[string]string{"Operator": "rw", "Observer": "r", "anonymous": "none"}, ""},
+		{"duplicate after normalization", map[string]string{"Operator": "rw", "operator": "r"}, "duplicate permissions"},
+		{"empty role", map[string]string{" ": "r"}, "empty role"},
+		{"invalid access", map[string]string{"operator": "w"}, "invalid permission"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensor := testSensor("Memory", "%MW0")
+			sensor.Permissions = tt.permissions
+			cfg := &SensorConfig{Sensors: []SensorDefinition{sensor}}
+			before := make(map[string]string, len(tt.permissions))
+			for role, access := range tt.permissions {
+				before[role] = access
+			}
+
+			err := Validate(cfg)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Validate: %v, want %q", err, tt.err)
+			}
+			// Validation leaves the configuration as it is
+			if !reflect.DeepEqual(cfg.Sensors[0].Permissions, before) {
+				t.Errorf("permissions = %v after Validate, want %v", cfg.Sensors[0].Permissions, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import "fmt"
+
+// MinChunkSize is the smallest message chunk size a server must accept (Part 6)
+const MinChunkSize = 8192
+
+// ServerLimits defines the limits the server advertises in ServerCapabilities and enforces, 0 = server default
+type ServerLimits struct {
+	MaxSessions              int     `json:"maxSessions,omitempty"`              // default unlimited
+	MaxSubscriptions         int     `json:"maxSubscriptions,omitempty"`         // all sessions, default unlimited
+	MaxMonitoredItemsPerCall int     `json:"maxMonitoredItemsPerCall,omitempty"` // default 1000
+	MaxNodesPerRead          int     `json:"maxNodesPerRead,omitempty"`          // default 1000
+	MaxNodesPerWrite         int     `json:"maxNodesPerWrite,omitempty"`         // default 1000
+	MaxNodesPerBrowse        int     `json:"maxNodesPerBrowse,omitempty"`        // default 1000
+	MaxNodesPerMethodCall    int     `json:"maxNodesPerMethodCall,omitempty"`    // default 1000
+	MinSupportedSampleRate   float64 `json:"minSupportedSampleRate,omitempty"`   // ms, default 100
+	MaxMessageSize           int     `json:"maxMessageSize,omitempty"`           // bytes, default 64 MiB
+	MaxChunkSize             int     `json:"maxChunkSize,omitempty"`             // bytes, default 64 KiB
+	MaxChunkCount            int     `json:"maxChunkCount,omitempty"`            // default 4096
+}
+
+// validateServerLimits checks that the limits are not negative and the chunk size is usable
+func validateServerLimits(limits *ServerLimits) error {
+	counts := []struct {
+		name  string
+		value int
+	}{
+		{"maxSessions", limits.MaxSessions},
+		{"maxSubscriptions", limits.MaxSubscriptions},
+		{"maxMonitoredItemsPerCall", limits.MaxMonitoredItemsPerCall},
+		{"maxNodesPerRead", limits.MaxNodesPerRead},
+		{"maxNodesPerWrite", limits.MaxNodesPerWrite},
+		{"maxNodesPerBrowse", limits.MaxNodesPerBrowse},
+		{"maxNodesPerMethodCall", limits.MaxNodesPerMethodCall},
+		{"maxMessageSize", limits.MaxMessageSize},
+		{"maxChunkSize", limits.MaxChunkSize},
+		{"maxChunkCount", limits.MaxChunkCount},
+	}
+	for _, count := range counts {
+		if count.value < 0 {
+			return fmt.Errorf("%s must not be negative: %d", count.name, count.value)
+		}
+	}
+
+	if limits.MinSupportedSampleRate < 0 {
+		return fmt.Errorf("minSupportedSampleRate must not be negative: %g", limits.MinSupportedSampleRate)
+	}
+	if limits.MaxChunkSize != 0 && limits.MaxChunkSize < MinChunkSize {
+		return fmt.Errorf("maxChunkSize must be at least %d: %d", MinChunkSize, limits.MaxChunkSize)
+	}
+	if limits.MaxMessageSize != 0 && limits.MaxChunkSize != 0 && limits.MaxMessageSize < limits.MaxChunkSize {
+		return fmt.Errorf("maxMessageSize %d is smaller than maxChunkSize %d", limits.MaxMessageSize, limits.MaxChunkSize)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// validateNodeID checks an explicit NodeId identifier ("i=1000", "s=Tank1.Temperature", "g=<guid>" or "b=<base64>").
+// The namespace is given separately by namespaceUri.
+func validateNodeID(id string) error {
+	if strings.HasPrefix(id, "ns=") || strings.HasPrefix(id, "nsu=") {
+		return fmt.Errorf("%s must not contain a namespace (use namespaceUri)", id)
+	}
+	if ua.ParseNodeID(id) == nil {
+		return fmt.Errorf("%s is not a NodeId identifier (i=, s=, g= or b=)", id)
+	}
+	return nil
+}
+
+// validateAlias checks an alias NodeId ("ns=2;i=1000", "nsu=<uri>;s=Name", or an identifier in the simulator namespace)
+func validateAlias(alias string) error {
+	if strings.HasPrefix(alias, "nsu=") {
+		if ua.ParseExpandedNodeID(alias).NodeID == nil {
+			return fmt.Errorf("%s is not a NodeId", alias)
+		}
+		return nil
+	}
+	if ua.ParseNodeID(alias) == nil {
+		return fmt.Errorf("%s is not a NodeId", alias)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnitsNamespaceURI is the namespace of UNECE Recommendation 20 unit codes in OPC UA EUInformation
+const UnitsNamespaceURI = "http://www.opcfoundation.org/UA/units/un/cefact"
+
+// EngineeringUnit is a UNECE Recommendation 20 unit
+type EngineeringUnit struct {
+	Code   string // Common code (e.g. "CEL")
+	Symbol string // Display name (e.g. "°C")
+	Name   string // Description (e.g. "degree Celsius")
+}
+
+// engineeringUnits are the common codes of the units used by the simulated sensors
+var engineeringUnits = map[string]EngineeringUnit{
+	"CEL": {"CEL", "°C", "degree Celsius"},
+	"FAH": {"FAH", "°F", "degree Fahrenheit"},
+	"KEL": {"KEL", "K", "kelvin"},
+	"BAR": {"BAR", "bar", "bar [unit of pressure]"},
+	"MBR": {"MBR", "mbar", "millibar"},
+	"PAL": {"PAL", "Pa", "pascal"},
+	"KPA": {"KPA", "kPa", "kilopascal"},
+	"MPA": {"MPA", "MPa", "megapascal"},
+	"P1":  {"P1", "%", "percent"},
+	"C62": {"C62", "1", "one"},
+	"2N":  {"2N", "dB", "decibel"},
+	"HTZ": {"HTZ", "Hz", "hertz"},
+	"RPM": {"RPM", "r/min", "revolutions per minute"},
+	"DD":  {"DD", "°", "degree [unit of angle]"},
+	"C16": {"C16", "mm/s", "millimetre per second"},
+	"MTS": {"MTS", "m/s", "metre per second"},
+	"MSK": {"MSK", "m/s²", "metre per second squared"},
+	"MMT": {"MMT", "mm", "millimetre"},
+	"MTR": {"MTR", "m", "metre"},
+	"NU":  {"NU", "N·m", "newton metre"},
+	"NEW": {"NEW", "N", "newton"},
+	"VLT": {"VLT", "V", "volt"},
+	"AMP": {"AMP", "A", "ampere"},
+	"WTT": {"WTT", "W", "watt"},
+	"KWT": {"KWT", "kW", "kilowatt"},
+	"LTR": {"LTR", "l", "litre"},
+	"L2":  {"L2", "l/min", "litre per minute"},
+	"MQH": {"MQH", "m³/h", "cubic metre per hour"},
+	"SEC": {"SEC", "s", "second [unit of time]"},
+	"C26": {"C26", "ms", "millisecond"},
+	"KGM": {"KGM", "kg", "kilogram"},
+}
+
+// LookupUnit returns the unit of a UNECE common code.
+// Codes missing from the table are accepted with the code as symbol.
+func LookupUnit(code string) (EngineeringUnit, error) {
+	if unit, ok := engineeringUnits[code]; ok {
+		return unit, nil
+	}
+	if len(code) == 0 || len(code) > 3 || strings.ToUpper(code) != code {
+		return EngineeringUnit{}, fmt.Errorf("%q is not a UNECE common code (1-3 upper case characters)", code)
+	}
+	for _, c := range code {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return EngineeringUnit{}, fmt.Errorf("%q is not a UNECE common code (1-3 upper case characters)", code)
+		}
+	}
+	return EngineeringUnit{Code: code, Symbol: code}, nil
+}
+
+// UnitID returns the OPC UA UnitId of the common code (the code characters packed into an integer)
+func (u EngineeringUnit) UnitID() int32 {
+	var id int32
+	for i := 0; i < len(u.Code); i++ {
+		id = id<<8 | int32(u.Code[i])
+	}
+	return id
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserConfig represents the user credentials file
+type UserConfig struct {
+	Users        []UserDefinition        `json:"users"`
+	Certificates []CertificateDefinition `json:"certificates"`
+}
+
+// UserDefinition defines a username/password identity
+type UserDefinition struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"passwordHash"` // bcrypt hash (see -hash-password)
+	Roles        []string `json:"roles"`
+}
+
+// CertificateDefinition defines an X.509 user certificate identity
+type CertificateDefinition struct {
+	Name       string   `json:"name"`
+	Thumbprint string   `json:"thumbprint"` // SHA-1 thumbprint of the DER certificate (hex, colons allowed)
+	Roles      []string `json:"roles"`
+}
+
+// LoadUsers loads user credentials from a JSON file
+func LoadUsers(filename string) (*UserConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var users UserConfig
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse users JSON: %w", err)
+	}
+
+	// Normalize thumbprints to lowercase hex without separators and role names to lowercase
+	for i := range users.Users {
+		normalizeRoles(users.Users[i].Roles)
+	}
+	for i := range users.Certificates {
+		users.Certificates[i].Thumbprint = NormalizeThumbprint(users.Certificates[i].Thumbprint)
+		normalizeRoles(users.Certificates[i].Roles)
+	}
+
+	if err := validateUsers(&users); err != nil {
+		return nil, fmt.Errorf("invalid users file: %w", err)
+	}
+
+	return &users, nil
+}
+
+// validateUsers validates the user credentials
+func validateUsers(users *UserConfig) error {
+	nameMap := make(map[string]bool)
+	for i, user := range users.Users {
+		if user.Username == "" {
+			return fmt.Errorf("user at index %d has empty username", i)
+		}
+		if nameMap[user.Username] {
+			return fmt.Errorf("duplicate username: %s", user.Username)
+		}
+		nameMap[user.Username] = true
+
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return fmt.Errorf("user '%s' has invalid passwordHash (expected bcrypt hash): %v", user.Username, err)
+		}
+	}
+
+	thumbprintMap := make(map[string]bool)
+	for i, cert := range users.Certificates {
+		if len(cert.Thumbprint) != 40 {
+			return fmt.Errorf("certificate at index %d has invalid thumbprint: %s", i, cert.Thumbprint)
+		}
+		if thumbprintMap[cert.Thumbprint] {
+			return fmt.Errorf("duplicate certificate thumbprint: %s", cert.Thumbprint)
+		}
+		thumbprintMap[cert.Thumbprint] = true
+	}
+
+	return nil
+}
+
+// CheckPassword reports whether the password matches the user's hash
+func (u UserDefinition) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// HashPassword returns the bcrypt hash of a password for the users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// NormalizeRole converts a role name to lowercase, the form role names are compared in
+func NormalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+func normalizeRoles(roles []string) {
+	for i, role := range roles {
+		roles[i] = NormalizeRole(role)
+	}
+}
+
+// NormalizeThumbprint converts a thumbprint to lowercase hex without separators
+func NormalizeThumbprint(thumbprint string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(thumbprint))
+}
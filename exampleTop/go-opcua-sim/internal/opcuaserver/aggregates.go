@@ -0,0 +1,529 @@
+package opcuaserver
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// Historian bits of the StatusCode info field (OPC UA Part 11, 6.3.3)
+const (
+	historianCalculated   ua.StatusCode = 0x0401 // value computed by an aggregate
+	historianInterpolated ua.StatusCode = 0x0402 // value interpolated between raw values
+	historianPartial      ua.StatusCode = 0x0004 // interval not fully covered by recorded data
+)
+
+// maxProcessedIntervals limits the processing intervals of one read
+const maxProcessedIntervals = 100000
+
+// defaultAggregateConfiguration holds the server defaults of Part 13 (4.2.1.2)
+var defaultAggregateConfiguration = ua.AggregateConfiguration{
+	UseServerCapabilitiesDefaults: true,
+	TreatUncertainAsBad:           false,
+	PercentDataBad:                100,
+	PercentDataGood:               100,
+	UseSlopedExtrapolation:        false,
+}
+
+// aggregateSample is a recorded value prepared for aggregate calculations
+type aggregateSample struct {
+	time  time.Time
+	value float64
+	raw   ua.DataValue
+	good  bool
+}
+
+// aggregateInterval is one processing interval [start, end) with its samples
+type aggregateInterval struct {
+	start, end time.Time
+	samples    []aggregateSample // samples within the interval
+	before     *aggregateSample  // last good sample before the interval
+	after      *aggregateSample  // first good sample at or after the interval end
+	partial    bool
+	config     ua.AggregateConfiguration
+}
+
+// aggregateFunc computes the value of an interval, timestamped with the interval start
+type aggregateFunc func(iv *aggregateInterval) ua.DataValue
+
+// aggregateFunctions are the supported Part 13 aggregates
+var aggregateFunctions = map[ua.NodeID]aggregateFunc{
+	ua.ObjectIDAggregateFunctionInterpolative:               aggregateInterpolative,
+	ua.ObjectIDAggregateFunctionAverage:                     aggregateAverage,
+	ua.ObjectIDAggregateFunctionTimeAverage:                 aggregateTimeAverage,
+	ua.ObjectIDAggregateFunctionTotal:                       aggregateTotal,
+	ua.ObjectIDAggregateFunctionMinimum:                     aggregateExtreme(false, false),
+	ua.ObjectIDAggregateFunctionMaximum:                     aggregateExtreme(true, false),
+	ua.ObjectIDAggregateFunctionMinimumActualTime:           aggregateExtreme(false, true),
+	ua.ObjectIDAggregateFunctionMaximumActualTime:           aggregateExtreme(true, true),
+	ua.ObjectIDAggregateFunctionRange:                       aggregateRange,
+	ua.ObjectIDAggregateFunctionCount:                       aggregateCount,
+	ua.ObjectIDAggregateFunctionStart:                       aggregateBoundary(false),
+	ua.ObjectIDAggregateFunctionEnd:                         aggregateBoundary(true),
+	ua.ObjectIDAggregateFunctionDelta:                       aggregateDelta,
+	ua.ObjectIDAggregateFunctionStandardDeviationSample:     aggregateDeviation(true, true),
+	ua.ObjectIDAggregateFunctionStandardDeviationPopulation: aggregateDeviation(false, true),
+	ua.ObjectIDAggregateFunctionVarianceSample:              aggregateDeviation(true, false),
+	ua.ObjectIDAggregateFunctionVariancePopulation:          aggregateDeviation(false, false),
+}
+
+// ReadProcessed computes the requested aggregate of each node over the processing intervals
+func (h *historian) ReadProcessed(ctx context.Context, nodesToRead []ua.HistoryReadValueID, details ua.ReadProcessedDetails,
+	timestampsToReturn ua.TimestampsToReturn, releaseContinuationPoints bool) ([]ua.HistoryReadResult, ua.StatusCode) {
+	if timestampsToReturn < ua.TimestampsToReturnSource || timestampsToReturn >= ua.TimestampsToReturnNeither {
+		return nil, ua.BadTimestampsToReturnInvalid
+	}
+	if len(details.AggregateType) != len(nodesToRead) {
+		return nil, ua.BadAggregateListMismatch
+	}
+
+	config := details.AggregateConfiguration
+	if config.UseServerCapabilitiesDefaults {
+		config = defaultAggregateConfiguration
+	}
+
+	results := make([]ua.HistoryReadResult, len(nodesToRead))
+	for i, node := range nodesToRead {
+		results[i] = h.readProcessed(node, details, details.AggregateType[i], config, timestampsToReturn, releaseContinuationPoints)
+	}
+	return results, ua.Good
+}
+
+// readProcessed computes the aggregate of one node
+func (h *historian) readProcessed(node ua.HistoryReadValueID, details ua.ReadProcessedDetails, aggregateType ua.NodeID,
+	config ua.AggregateConfiguration, timestampsToReturn ua.TimestampsToReturn, release bool) ua.HistoryReadResult {
+	if node.ContinuationPoint != "" {
+		cursor, ok := h.takeCursor(node.ContinuationPoint, node.NodeID)
+		if !ok {
+			return ua.HistoryReadResult{StatusCode: ua.BadContinuationPointInvalid}
+		}
+		if release {
+			return ua.HistoryReadResult{StatusCode: ua.Good}
+		}
+		return h.page(cursor.nodeID, cursor.values, cursor.limit, timestampsToReturn)
+	}
+	if release {
+		return ua.HistoryReadResult{StatusCode: ua.Good}
+	}
+
+	aggregate, ok := aggregateFunctions[aggregateType]
+	if !ok {
+		return ua.HistoryReadResult{StatusCode: ua.BadAggregateNotSupported}
+	}
+	if config.PercentDataBad > 100 || config.PercentDataGood > 100 || config.PercentDataBad+config.PercentDataGood < 100 {
+		return ua.HistoryReadResult{StatusCode: ua.BadAggregateConfigurationRejected}
+	}
+
+	start, end := specifiedTime(details.StartTime), specifiedTime(details.EndTime)
+	if start.IsZero() || end.IsZero() || start.Equal(end) || details.ProcessingInterval < 0 {
+		return ua.HistoryReadResult{StatusCode: ua.BadInvalidTimestampArgument}
+	}
+
+	h.mu.RLock()
+	buffer, ok := h.buffers[node.NodeID]
+	var values []ua.DataValue
+	if ok {
+		values = buffer.snapshot()
+	}
+	h.mu.RUnlock()
+	if !ok {
+		return ua.HistoryReadResult{StatusCode: ua.BadHistoryOperationUnsupported}
+	}
+
+	samples, ok := aggregateSamples(values, config)
+	if !ok {
+		return ua.HistoryReadResult{StatusCode: ua.BadAggregateInvalidInputs}
+	}
+
+	// Intervals are computed forward; a backward read returns them in reverse, stamped with the later bound
+	backward := end.Before(start)
+	if backward {
+		start, end = end, start
+	}
+	interval := time.Duration(details.ProcessingInterval * float64(time.Millisecond))
+	if interval <= 0 || interval > end.Sub(start) {
+		interval = end.Sub(start)
+	}
+	if end.Sub(start)/interval > maxProcessedIntervals {
+		return ua.HistoryReadResult{StatusCode: ua.BadInvalidArgument}
+	}
+
+	var processed []ua.DataValue
+	for t := start; t.Before(end); t = t.Add(interval) {
+		ivEnd := t.Add(interval)
+		if ivEnd.After(end) {
+			ivEnd = end
+		}
+		iv := newAggregateInterval(samples, t, ivEnd, config)
+		value := aggregate(iv)
+		if backward && value.SourceTimestamp.Equal(t) {
+			value.SourceTimestamp = ivEnd
+		}
+		value.ServerTimestamp = time.Now()
+		processed = append(processed, value)
+	}
+	if backward {
+		for i, j := 0, len(processed)-1; i < j; i, j = i+1, j-1 {
+			processed[i], processed[j] = processed[j], processed[i]
+		}
+	}
+
+	return h.page(node.NodeID, processed, 0, timestampsToReturn)
+}
+
+// aggregateSamples converts recorded values to numbers; false if a value is not numeric
+func aggregateSamples(values []ua.DataValue, config ua.AggregateConfiguration) ([]aggregateSample, bool) {
+	samples := make([]aggregateSample, 0, len(values))
+	for _, value := range values {
+		var v float64
+		switch val := value.Value.(type) {
+		case bool:
+			if val {
+				v = 1
+			}
+		case nil:
+			// bad values carry no value
+		default:
+			var ok bool
+			if v, ok = numericValue(val); !ok {
+				return nil, false
+			}
+		}
+		good := value.StatusCode.IsGood() || (value.StatusCode.IsUncertain() && !config.TreatUncertainAsBad)
+		samples = append(samples, aggregateSample{time: value.SourceTimestamp, value: v, raw: value, good: good && value.Value != nil})
+	}
+	return samples, true
+}
+
+// newAggregateInterval collects the samples of [start, end) and the good samples around it
+func newAggregateInterval(samples []aggregateSample, start, end time.Time, config ua.AggregateConfiguration) *aggregateInterval {
+	i0 := sort.Search(len(samples), func(i int) bool { return !samples[i].time.Before(start) })
+	i1 := sort.Search(len(samples), func(i int) bool { return !samples[i].time.Before(end) })
+
+	iv := &aggregateInterval{start: start, end: end, samples: samples[i0:i1], config: config}
+	for i := i0 - 1; i >= 0; i-- {
+		if samples[i].good {
+			iv.before = &samples[i]
+			break
+		}
+	}
+	for i := i1; i < len(samples); i++ {
+		if samples[i].good {
+			iv.after = &samples[i]
+			break
+		}
+	}
+	// Data does not cover the interval when it starts before the first or ends after the last value
+	iv.partial = len(samples) == 0 || start.Before(samples[0].time) || (end.After(samples[len(samples)-1].time) && iv.after == nil)
+	return iv
+}
+
+// good returns the good samples of the interval
+func (iv *aggregateInterval) good() []aggregateSample {
+	good := make([]aggregateSample, 0, len(iv.samples))
+	for _, s := range iv.samples {
+		if s.good {
+			good = append(good, s)
+		}
+	}
+	return good
+}
+
+// quality returns the interval status from the share of good samples (PercentDataGood/PercentDataBad)
+func (iv *aggregateInterval) quality() ua.StatusCode {
+	if len(iv.samples) == 0 {
+		return ua.BadNoData
+	}
+	goodCount := len(iv.good())
+	percentGood := 100 * goodCount / len(iv.samples)
+	percentBad := 100 - percentGood
+	var status ua.StatusCode
+	switch {
+	case goodCount == 0 || percentBad >= int(iv.config.PercentDataBad):
+		return ua.BadNoData
+	case percentGood >= int(iv.config.PercentDataGood):
+		status = ua.Good
+	default:
+		status = ua.UncertainDataSubNormal
+	}
+	if iv.partial {
+		status |= historianPartial
+	}
+	return status
+}
+
+// valueAt returns the value at t, interpolated between the surrounding good samples
+// (stepped extrapolation after the last sample); the flag is false when there is no earlier value
+func (iv *aggregateInterval) valueAt(t time.Time) (float64, ua.StatusCode, bool) {
+	var prev, next *aggregateSample
+	if iv.before != nil {
+		prev = iv.before
+	}
+	for i := range iv.samples {
+		s := &iv.samples[i]
+		if !s.good {
+			continue
+		}
+		if s.time.After(t) {
+			next = s
+			break
+		}
+		prev = s
+	}
+	if next == nil && iv.after != nil {
+		// t is at most the interval end, so the first sample after the interval is later or at t
+		if iv.after.time.After(t) {
+			next = iv.after
+		} else {
+			prev = iv.after
+		}
+	}
+
+	switch {
+	case prev == nil:
+		return 0, ua.BadNoData, false
+	case prev.time.Equal(t):
+		return prev.value, ua.Good, true
+	case next == nil:
+		// No later value: extrapolate the last value
+		return prev.value, ua.UncertainDataSubNormal | historianInterpolated, true
+	}
+	ratio := float64(t.Sub(prev.time)) / float64(next.time.Sub(prev.time))
+	return prev.value + (next.value-prev.value)*ratio, ua.Good | historianInterpolated, true
+}
+
+// aggregateInterpolative returns the interpolated value at the interval start
+func aggregateInterpolative(iv *aggregateInterval) ua.DataValue {
+	value, status, ok := iv.valueAt(iv.start)
+	if !ok {
+		return ua.DataValue{StatusCode: ua.BadNoData, SourceTimestamp: iv.start}
+	}
+	return ua.DataValue{Value: value, StatusCode: status, SourceTimestamp: iv.start}
+}
+
+// aggregateAverage returns the mean of the good raw values
+func aggregateAverage(iv *aggregateInterval) ua.DataValue {
+	status := iv.quality()
+	if status.IsBad() {
+		return ua.DataValue{StatusCode: status, SourceTimestamp: iv.start}
+	}
+	sum := 0.0
+	good := iv.good()
+	for _, s := range good {
+		sum += s.value
+	}
+	return ua.DataValue{Value: sum / float64(len(good)), StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// timeIntegral integrates the interpolated values over the interval (value*seconds)
+// and returns the integrated duration in seconds
+func (iv *aggregateInterval) timeIntegral() (float64, float64, ua.StatusCode, bool) {
+	good := iv.good()
+	from := iv.start
+	startValue, startStatus, ok := iv.valueAt(from)
+	if !ok {
+		// Nothing precedes the interval: integrate from the first good value
+		if len(good) == 0 {
+			return 0, 0, ua.BadNoData, false
+		}
+		from, startValue, startStatus = good[0].time, good[0].value, ua.UncertainDataSubNormal
+	}
+	endValue, endStatus, _ := iv.valueAt(iv.end)
+
+	points := []aggregateSample{{time: from, value: startValue}}
+	for _, s := range good {
+		if s.time.After(from) {
+			points = append(points, s)
+		}
+	}
+	points = append(points, aggregateSample{time: iv.end, value: endValue})
+
+	area := 0.0
+	for i := 1; i < len(points); i++ {
+		dt := points[i].time.Sub(points[i-1].time).Seconds()
+		area += (points[i].value + points[i-1].value) / 2 * dt
+	}
+
+	status := ua.Good
+	if !startStatus.IsGood() || !endStatus.IsGood() {
+		status = ua.UncertainDataSubNormal
+	}
+	if iv.partial {
+		status |= historianPartial
+	}
+	return area, iv.end.Sub(from).Seconds(), status, true
+}
+
+// aggregateTimeAverage returns the time-weighted average using linear interpolation
+func aggregateTimeAverage(iv *aggregateInterval) ua.DataValue {
+	area, seconds, status, ok := iv.timeIntegral()
+	if !ok || seconds <= 0 {
+		return ua.DataValue{StatusCode: ua.BadNoData, SourceTimestamp: iv.start}
+	}
+	return ua.DataValue{Value: area / seconds, StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// aggregateTotal returns the time integral of the values (value*seconds)
+func aggregateTotal(iv *aggregateInterval) ua.DataValue {
+	area, _, status, ok := iv.timeIntegral()
+	if !ok {
+		return ua.DataValue{StatusCode: ua.BadNoData, SourceTimestamp: iv.start}
+	}
+	return ua.DataValue{Value: area, StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// aggregateExtreme returns the minimum or maximum good raw value, stamped with the
+// interval start or the time of the value (ActualTime variants)
+func aggregateExtreme(maximum, actualTime bool) aggregateFunc {
+	return func(iv *aggregateInterval) ua.DataValue {
+		status := iv.quality()
+		if status.IsBad() {
+			return ua.DataValue{StatusCode: status, SourceTimestamp: iv.start}
+		}
+		good := iv.good()
+		best := good[0]
+		for _, s := range good[1:] {
+			if (maximum && s.value > best.value) || (!maximum && s.value < best.value) {
+				best = s
+			}
+		}
+		timestamp := iv.start
+		if actualTime {
+			timestamp = best.time
+		}
+		return ua.DataValue{Value: best.raw.Value, StatusCode: status | historianCalculated, SourceTimestamp: timestamp}
+	}
+}
+
+// aggregateRange returns the difference between the maximum and minimum good value
+func aggregateRange(iv *aggregateInterval) ua.DataValue {
+	status := iv.quality()
+	if status.IsBad() {
+		return ua.DataValue{StatusCode: status, SourceTimestamp: iv.start}
+	}
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, s := range iv.good() {
+		minValue = math.Min(minValue, s.value)
+		maxValue = math.Max(maxValue, s.value)
+	}
+	return ua.DataValue{Value: maxValue - minValue, StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// aggregateCount returns the number of good raw values
+func aggregateCount(iv *aggregateInterval) ua.DataValue {
+	status := ua.Good
+	if len(iv.samples) > 0 {
+		if quality := iv.quality(); !quality.IsBad() {
+			status = quality
+		}
+	} else if iv.partial {
+		status |= historianPartial
+	}
+	return ua.DataValue{Value: int32(len(iv.good())), StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// aggregateBoundary returns the first (Start) or last (End) raw value of the interval with its timestamp
+func aggregateBoundary(last bool) aggregateFunc {
+	return func(iv *aggregateInterval) ua.DataValue {
+		if len(iv.samples) == 0 {
+			return ua.DataValue{StatusCode: ua.BadNoData, SourceTimestamp: iv.start}
+		}
+		s := iv.samples[0]
+		if last {
+			s = iv.samples[len(iv.samples)-1]
+		}
+		return s.raw
+	}
+}
+
+// aggregateDelta returns the difference between the last and first good value
+func aggregateDelta(iv *aggregateInterval) ua.DataValue {
+	status := iv.quality()
+	if status.IsBad() {
+		return ua.DataValue{StatusCode: status, SourceTimestamp: iv.start}
+	}
+	good := iv.good()
+	delta := good[len(good)-1].value - good[0].value
+	return ua.DataValue{Value: delta, StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+}
+
+// aggregateDeviation returns the standard deviation or variance of the good values,
+// using n-1 (sample) or n (population) as divisor
+func aggregateDeviation(sample, standardDeviation bool) aggregateFunc {
+	return func(iv *aggregateInterval) ua.DataValue {
+		status := iv.quality()
+		if status.IsBad() {
+			return ua.DataValue{StatusCode: status, SourceTimestamp: iv.start}
+		}
+		good := iv.good()
+		n := float64(len(good))
+		divisor := n
+		if sample {
+			divisor = n - 1
+		}
+		if divisor <= 0 {
+			return ua.DataValue{StatusCode: ua.BadNoData, SourceTimestamp: iv.start}
+		}
+
+		mean := 0.0
+		for _, s := range good {
+			mean += s.value
+		}
+		mean /= n
+		variance := 0.0
+		for _, s := range good {
+			variance += (s.value - mean) * (s.value - mean)
+		}
+		variance /= divisor
+
+		value := variance
+		if standardDeviation {
+			value = math.Sqrt(variance)
+		}
+		return ua.DataValue{Value: value, StatusCode: status | historianCalculated, SourceTimestamp: iv.start}
+	}
+}
+
+// advertiseAggregates lists the supported aggregates under ServerCapabilities and
+// HistoryServerCapabilities and publishes the history capabilities
+func (s *OPCUAServer) advertiseAggregates() {
+	nm := s.server.NamespaceManager()
+
+	aggregateIDs := make([]ua.NodeIDNumeric, 0, len(aggregateFunctions))
+	for id := range aggregateFunctions {
+		aggregateIDs = append(aggregateIDs, id.(ua.NodeIDNumeric))
+	}
+	sort.Slice(aggregateIDs, func(i, j int) bool { return aggregateIDs[i].ID < aggregateIDs[j].ID })
+
+	for _, folderID := range []ua.NodeID{
+		ua.ObjectIDServerServerCapabilitiesAggregateFunctions,
+		ua.ObjectIDHistoryServerCapabilitiesAggregateFunctions,
+	} {
+		folder, ok := nm.FindObject(folderID)
+		if !ok {
+			continue
+		}
+		refs := folder.References()
+		for _, aggregateID := range aggregateIDs {
+			refs = append(refs, ua.Reference{
+				ReferenceTypeID: ua.ReferenceTypeIDOrganizes,
+				TargetID:        ua.ExpandedNodeID{NodeID: aggregateID},
+			})
+		}
+		folder.SetReferences(refs)
+	}
+
+	capabilities := map[ua.NodeID]any{
+		ua.VariableIDHistoryServerCapabilitiesAccessHistoryDataCapability: true,
+		ua.VariableIDHistoryServerCapabilitiesMaxReturnDataValues:         uint32(maxHistoryValuesPerRead),
+		ua.VariableIDHistoryServerCapabilitiesMaxReturnEventValues:        uint32(0),
+	}
+	for id, value := range capabilities {
+		if n, ok := nm.FindVariable(id); ok {
+			n.SetValue(ua.NewDataValue(value, 0, time.Now(), 0, time.Now(), 0))
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package opcuaserver
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// aggregateTestHistorian returns a historian with the values of one node
+func aggregateTestHistorian(values []ua.DataValue) (*historian, ua.NodeID) {
+	nodeID := ua.NodeIDString{NamespaceIndex: 2, ID: "Tag"}
+	h := newHistorian(1000)
+	h.register(nodeID)
+	for _, value := range values {
+		h.WriteValue(context.Background(), nodeID, value)
+	}
+	return h, nodeID
+}
+
+// readAggregate reads an aggregate of the node between the test times from and to
+func readAggregate(t *testing.T, values []ua.DataValue, aggregate ua.NodeID, from, to int, intervalMs float64, config ua.AggregateConfiguration) []ua.DataValue {
+	t.Helper()
+	h, nodeID := aggregateTestHistorian(values)
+	details := ua.ReadProcessedDetails{
+		StartTime:              historyTestTime(from),
+		EndTime:                historyTestTime(to),
+		ProcessingInterval:     intervalMs,
+		AggregateType:          []ua.NodeID{aggregate},
+		AggregateConfiguration: config,
+	}
+	results, status := h.ReadProcessed(context.Background(), []ua.HistoryReadValueID{{NodeID: nodeID}}, details, ua.TimestampsToReturnSource, false)
+	if status != ua.Good || results[0].StatusCode != ua.Good {
+		t.Fatalf("ReadProcessed: status %v, result %v", status, results[0].StatusCode)
+	}
+	return results[0].HistoryData.(ua.HistoryData).DataValues
+}
+
+// checkAggregate checks the value, status and timestamp of a processed value
+func checkAggregate(t *testing.T, got ua.DataValue, value float64, status ua.StatusCode, timestamp time.Time) {
+	t.Helper()
+	if got.StatusCode != status {
+		t.Errorf("status = %#x, want %#x", uint32(got.StatusCode), uint32(status))
+	}
+	if !got.SourceTimestamp.Equal(timestamp) {
+		t.Errorf("timestamp = %v, want %v", got.SourceTimestamp, timestamp)
+	}
+	if status.IsBad() {
+		return
+	}
+	v, ok := got.Value.(float64)
+	if !ok || math.Abs(v-value) > 1e-9 {
+		t.Errorf("value = %v, want %v", got.Value, value)
+	}
+}
+
+func TestAggregateIntervals(t *testing.T) {
+	values := historyTestValues(10)
+	average := ua.ObjectIDAggregateFunctionAverage
+
+	t.Run("forward", func(t *testing.T) {
+		got := readAggregate(t, values, average, 0, 10, 5000, defaultAggregateConfiguration)
+		if len(got) != 2 {
+			t.Fatalf("%d intervals, want 2", len(got))
+		}
+		checkAggregate(t, got[0], 2, ua.Good|historianCalculated, historyTestTime(0))
+		// The data ends at 9s, before the end of the second interval
+		checkAggregate(t, got[1], 7, ua.Good|historianCalculated|historianPartial, historyTestTime(5))
+	})
+
+	t.Run("shorter last interval", func(t *testing.T) {
+		got := readAggregate(t, values, average, 0, 7, 5000, defaultAggregateConfiguration)
+		if len(got) != 2 {
+			t.Fatalf("%d intervals, want 2", len(got))
+		}
+		checkAggregate(t, got[1], 5.5, ua.Good|historianCalculated, historyTestTime(5))
+	})
+
+	t.Run("whole range", func(t *testing.T) {
+		got := readAggregate(t, values, average, 0, 9, 0, defaultAggregateConfiguration)
+		if len(got) != 1 {
+			t.Fatalf("%d intervals, want 1", len(got))
+		}
+		checkAggregate(t, got[0], 4, ua.Good|historianCalculated, historyTestTime(0))
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		got := readAggregate(t, values, average, 10, 0, 5000, defaultAggregateConfiguration)
+		if len(got) != 2 {
+			t.Fatalf("%d intervals, want 2", len(got))
+		}
+		// Newest interval first, stamped with its later bound
+		checkAggregate(t, got[0], 7, ua.Good|historianCalculated|historianPartial, historyTestTime(10))
+		checkAggregate(t, got[1], 2, ua.Good|historianCalculated, historyTestTime(5))
+	})
+
+	t.Run("before data", func(t *testing.T) {
+		got := readAggregate(t, values, average, -10, 0, 5000, defaultAggregateConfiguration)
+		for i, value := range got {
+			checkAggregate(t, value, 0, ua.BadNoData, historyTestTime(-10+5*i))
+		}
+		count := readAggregate(t, values, ua.ObjectIDAggregateFunctionCount, -10, -5, 0, defaultAggregateConfiguration)
+		if count[0].Value != int32(0) || count[0].StatusCode != ua.Good|historianCalculated|historianPartial {
+			t.Errorf("Count = %v (%#x), want 0 partial", count[0].Value, uint32(count[0].StatusCode))
+		}
+	})
+}
+
+func TestAggregateInvalidArguments(t *testing.T) {
+	h, nodeID := aggregateTestHistorian(historyTestValues(3))
+	tests := []struct {
+		name    string
+		details ua.ReadProcessedDetails
+		want    ua.StatusCode
+	}{
+		{"unsupported aggregate", ua.ReadProcessedDetails{StartTime: historyTestTime(0), EndTime: historyTestTime(1),
+			AggregateType: []ua.NodeID{ua.ObjectIDAggregateFunctionWorstQuality}}, ua.BadAggregateNotSupported},
+		{"start equals end", ua.ReadProcessedDetails{StartTime: historyTestTime(1), EndTime: historyTestTime(1),
+			AggregateType: []ua.NodeID{ua.ObjectIDAggregateFunctionAverage}, AggregateConfiguration: defaultAggregateConfiguration}, ua.BadInvalidTimestampArgument},
+		{"too many intervals", ua.ReadProcessedDetails{StartTime: historyTestTime(0), EndTime: historyTestTime(1000), ProcessingInterval: 1,
+			AggregateType: []ua.NodeID{ua.ObjectIDAggregateFunctionAverage}, AggregateConfiguration: defaultAggregateConfiguration}, ua.BadInvalidArgument},
+		{"percentages below 100", ua.ReadProcessedDetails{StartTime: historyTestTime(0), EndTime: historyTestTime(1),
+			AggregateType:          []ua.NodeID{ua.ObjectIDAggregateFunctionAverage},
+			AggregateConfiguration: ua.AggregateConfiguration{PercentDataBad: 40, PercentDataGood: 50}}, ua.BadAggregateConfigurationRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _ := h.ReadProcessed(context.Background(), []ua.HistoryReadValueID{{NodeID: nodeID}}, tt.details, ua.TimestampsToReturnSource, false)
+			if results[0].StatusCode != tt.want {
+				t.Errorf("status = %v, want %v", results[0].StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateQuality(t *testing.T) {
+	// 0..4, the value at 1s is bad and the one at 2s uncertain
+	values := historyTestValues(5)
+	values[1] = ua.DataValue{StatusCode: ua.BadSensorFailure, SourceTimestamp: historyTestTime(1)}
+	values[2].StatusCode = ua.UncertainLastUsableValue
+
+	tests := []struct {
+		name   string
+		config ua.AggregateConfiguration
+		value  float64
+		status ua.StatusCode
+	}{
+		// 75% good: short of PercentDataGood 100, below PercentDataBad 100
+		{"server defaults", ua.AggregateConfiguration{UseServerCapabilitiesDefaults: true}, 5.0 / 3, ua.UncertainDataSubNormal | historianCalculated},
+		{"good enough", ua.AggregateConfiguration{PercentDataBad: 50, PercentDataGood: 75}, 5.0 / 3, ua.Good | historianCalculated},
+		{"too bad", ua.AggregateConfiguration{PercentDataBad: 25, PercentDataGood: 75}, 0, ua.BadNoData},
+		// 50% good when uncertain counts as bad
+		{"uncertain as bad", ua.AggregateConfiguration{TreatUncertainAsBad: true, PercentDataBad: 60, PercentDataGood: 50}, 1.5, ua.Good | historianCalculated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAggregate(t, values, ua.ObjectIDAggregateFunctionAverage, 0, 4, 0, tt.config)
+			checkAggregate(t, got[0], tt.value, tt.status, historyTestTime(0))
+		})
+	}
+}
+
+func TestAggregateFunctions(t *testing.T) {
+	// A ramp from 0 to 10 over 10s, with a 4 at 2s
+	values := []ua.DataValue{
+		ua.NewDataValue(0.0, ua.Good, historyTestTime(0), 0, historyTestTime(0), 0),
+		ua.NewDataValue(4.0, ua.Good, historyTestTime(2), 0, historyTestTime(2), 0),
+		ua.NewDataValue(10.0, ua.Good, historyTestTime(10), 0, historyTestTime(10), 0),
+	}
+	config := defaultAggregateConfiguration
+	tests := []struct {
+		name      string
+		aggregate ua.NodeID
+		from, to  int
+		value     float64
+		status    ua.StatusCode
+		timestamp int
+	}{
+		{"Interpolative", ua.ObjectIDAggregateFunctionInterpolative, 6, 10, 7, ua.Good | historianInterpolated, 6},
+		{"Interpolative at a value", ua.ObjectIDAggregateFunctionInterpolative, 2, 10, 4, ua.Good, 2},
+		{"Interpolative after the data", ua.ObjectIDAggregateFunctionInterpolative, 12, 14, 10, ua.UncertainDataSubNormal | historianInterpolated, 12},
+		{"TimeAverage", ua.ObjectIDAggregateFunctionTimeAverage, 0, 10, 6, ua.Good | historianCalculated, 0},
+		{"Total", ua.ObjectIDAggregateFunctionTotal, 0, 10, 60, ua.Good | historianCalculated, 0},
+		{"TimeAverage interpolated bounds", ua.ObjectIDAggregateFunctionTimeAverage, 1, 6, 5, ua.Good | historianCalculated, 1},
+		{"Minimum", ua.ObjectIDAggregateFunctionMinimum, 0, 10, 0, ua.Good | historianCalculated, 0},
+		{"MaximumActualTime", ua.ObjectIDAggregateFunctionMaximumActualTime, 0, 10, 4, ua.Good | historianCalculated, 2},
+		{"Range", ua.ObjectIDAggregateFunctionRange, 0, 10, 4, ua.Good | historianCalculated, 0},
+		{"Delta", ua.ObjectIDAggregateFunctionDelta, 0, 11, 10, ua.Good | historianCalculated | historianPartial, 0},
+		{"End", ua.ObjectIDAggregateFunctionEnd, 0, 10, 4, ua.Good, 2},
+		{"VariancePopulation", ua.ObjectIDAggregateFunctionVariancePopulation, 0, 11, 152.0 / 9, ua.Good | historianCalculated | historianPartial, 0},
+		{"StandardDeviationSample", ua.ObjectIDAggregateFunctionStandardDeviationSample, 0, 11, math.Sqrt(76.0 / 3), ua.Good | historianCalculated | historianPartial, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAggregate(t, values, tt.aggregate, tt.from, tt.to, 0, config)
+			checkAggregate(t, got[0], tt.value, tt.status, historyTestTime(tt.timestamp))
+		})
+	}
+}
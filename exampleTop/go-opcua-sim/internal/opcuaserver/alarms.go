@@ -0,0 +1,652 @@
+package opcuaserver
+
+import (
+	"crypto/rand"
+	"fmt"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// limitState is the active level of an exclusive limit alarm
+type limitState int
+
+const (
+	limitNormal limitState = iota
+	limitLowLow
+	limitLow
+	limitHigh
+	limitHighHigh
+)
+
+// String returns the ExclusiveLimitStateMachine state name
+func (l limitState) String() string {
+	switch l {
+	case limitLowLow:
+		return "LowLow"
+	case limitLow:
+		return "Low"
+	case limitHigh:
+		return "High"
+	case limitHighHigh:
+		return "HighHigh"
+	default:
+		return "Normal"
+	}
+}
+
+// stateID returns the ExclusiveLimitStateMachineType state node (nil when normal)
+func (l limitState) stateID() ua.NodeID {
+	switch l {
+	case limitLowLow:
+		return ua.ObjectIDExclusiveLimitStateMachineTypeLowLow
+	case limitLow:
+		return ua.ObjectIDExclusiveLimitStateMachineTypeLow
+	case limitHigh:
+		return ua.ObjectIDExclusiveLimitStateMachineTypeHigh
+	case limitHighHigh:
+		return ua.ObjectIDExclusiveLimitStateMachineTypeHighHigh
+	default:
+		return nil
+	}
+}
+
+// rank orders the levels by distance from normal
+func (l limitState) rank() int {
+	switch l {
+	case limitLow, limitHigh:
+		return 1
+	case limitLowLow, limitHighHigh:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// severity returns the event severity of a level (1-1000)
+func (l limitState) severity() uint16 {
+	switch l.rank() {
+	case 2:
+		return 900
+	case 1:
+		return 600
+	default:
+		return 100
+	}
+}
+
+// classifyLimits returns the level of a value; the deadband widens the alarm ranges toward normal
+func classifyLimits(limits *config.AlarmLimits, value, deadband float64) limitState {
+	switch {
+	case limits.HighHigh != nil && value >= *limits.HighHigh-deadband:
+		return limitHighHigh
+	case limits.LowLow != nil && value <= *limits.LowLow+deadband:
+		return limitLowLow
+	case limits.High != nil && value >= *limits.High-deadband:
+		return limitHigh
+	case limits.Low != nil && value <= *limits.Low+deadband:
+		return limitLow
+	default:
+		return limitNormal
+	}
+}
+
+// limitAlarm is an ExclusiveLimitAlarmType condition on an analog tag
+type limitAlarm struct {
+	mu          sync.Mutex
+	nm          *server.NamespaceManager
+	conditionID ua.NodeID
+	notifierID  ua.NodeID
+	sourceID    ua.NodeID
+	sourceName  string
+	limits      *config.AlarmLimits
+
+	state     limitState
+	active    bool
+	acked     bool
+	confirmed bool
+	severity  uint16
+	message   string
+	comment   ua.LocalizedText
+	userID    string
+	eventID   ua.ByteString
+	time      time.Time
+}
+
+// newLimitAlarm creates an inactive, acknowledged condition
+func newLimitAlarm(nm *server.NamespaceManager, tag *plc.Tag, conditionID, notifierID, sourceID ua.NodeID) *limitAlarm {
+	return &limitAlarm{
+		nm:          nm,
+		conditionID: conditionID,
+		notifierID:  notifierID,
+		sourceID:    sourceID,
+		sourceName:  tag.Name,
+		limits:      tag.Alarms,
+		acked:       true,
+		confirmed:   true,
+		severity:    limitNormal.severity(),
+		message:     fmt.Sprintf("%s is normal", tag.Name),
+		time:        time.Now(),
+	}
+}
+
+// retain reports whether the condition is still of interest to clients
+func (a *limitAlarm) retain() bool {
+	return a.active || !a.acked || !a.confirmed
+}
+
+// evaluate updates the limit state from a new value and reports state changes as events
+func (a *limitAlarm) evaluate(value float64) {
+	a.mu.Lock()
+	next := classifyLimits(a.limits, value, 0)
+	if next.rank() < a.state.rank() {
+		// Leaving an alarm level requires the value to clear the limit by the deadband
+		if held := classifyLimits(a.limits, value, a.limits.Deadband); held.rank() >= a.state.rank() {
+			next = a.state
+		} else {
+			next = held
+		}
+	}
+	if next == a.state {
+		a.mu.Unlock()
+		return
+	}
+
+	a.state = next
+	if next != limitNormal {
+		a.active, a.acked, a.confirmed = true, false, false
+		a.severity = next.severity()
+		a.message = fmt.Sprintf("%s %s alarm (value %.3f)", a.sourceName, next, value)
+	} else {
+		a.active = false
+		a.message = fmt.Sprintf("%s returned to normal (value %.3f)", a.sourceName, value)
+	}
+	a.comment, a.userID = ua.LocalizedText{}, ""
+	evt := a.newEvent()
+	a.mu.Unlock()
+
+	log.Printf("[ALARM] %s (severity %d)", evt.Message.Text, evt.Severity)
+	a.fire(evt)
+}
+
+// acknowledge acknowledges the event identified by eventID
+func (a *limitAlarm) acknowledge(eventID ua.ByteString, comment ua.LocalizedText, userID string) ua.StatusCode {
+	a.mu.Lock()
+	if eventID != a.eventID {
+		a.mu.Unlock()
+		return ua.BadEventIDUnknown
+	}
+	if a.acked {
+		a.mu.Unlock()
+		return ua.BadConditionBranchAlreadyAcked
+	}
+	a.acked = true
+	a.comment, a.userID = comment, userID
+	a.message = fmt.Sprintf("%s alarm acknowledged", a.sourceName)
+	evt := a.newEvent()
+	a.mu.Unlock()
+
+	log.Printf("[ALARM] %s by %s", evt.Message.Text, userID)
+	a.fire(evt)
+	return ua.Good
+}
+
+// confirm confirms the event identified by eventID
+func (a *limitAlarm) confirm(eventID ua.ByteString, comment ua.LocalizedText, userID string) ua.StatusCode {
+	a.mu.Lock()
+	if eventID != a.eventID {
+		a.mu.Unlock()
+		return ua.BadEventIDUnknown
+	}
+	if a.confirmed {
+		a.mu.Unlock()
+		return ua.BadConditionBranchAlreadyConfirmed
+	}
+	a.confirmed = true
+	a.comment, a.userID = comment, userID
+	a.message = fmt.Sprintf("%s alarm confirmed", a.sourceName)
+	evt := a.newEvent()
+	a.mu.Unlock()
+
+	log.Printf("[ALARM] %s by %s", evt.Message.Text, userID)
+	a.fire(evt)
+	return ua.Good
+}
+
+// snapshot returns the current condition state as an event (for ConditionRefresh)
+func (a *limitAlarm) snapshot() (*limitAlarmEvent, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.retain() {
+		return nil, false
+	}
+	evt := a.event(a.eventID)
+	evt.ReceiveTime = time.Now()
+	return evt, true
+}
+
+// newEvent creates an event for a state change with a new event ID (caller holds the lock)
+func (a *limitAlarm) newEvent() *limitAlarmEvent {
+	a.eventID = newEventID()
+	a.time = time.Now()
+	return a.event(a.eventID)
+}
+
+// event captures the condition state (caller holds the lock)
+func (a *limitAlarm) event(eventID ua.ByteString) *limitAlarmEvent {
+	return &limitAlarmEvent{
+		EventID:     eventID,
+		SourceNode:  a.sourceID,
+		SourceName:  a.sourceName,
+		Time:        a.time,
+		ReceiveTime: a.time,
+		Message:     ua.LocalizedText{Text: a.message, Locale: "en"},
+		Severity:    a.severity,
+		ConditionID: a.conditionID,
+		Retain:      a.retain(),
+		Acked:       a.acked,
+		Confirmed:   a.confirmed,
+		Active:      a.active,
+		State:       a.state,
+		Comment:     a.comment,
+		UserID:      a.userID,
+		Limits:      a.limits,
+	}
+}
+
+// fire reports the event through the notifier hierarchy up to the Server object
+func (a *limitAlarm) fire(evt *limitAlarmEvent) {
+	if notifier, ok := a.nm.FindObject(a.notifierID); ok {
+		a.nm.OnEvent(notifier, evt)
+	}
+}
+
+// limitAlarmEvent is an ExclusiveLimitAlarmType event
+type limitAlarmEvent struct {
+	EventID     ua.ByteString
+	SourceNode  ua.NodeID
+	SourceName  string
+	Time        time.Time
+	ReceiveTime time.Time
+	Message     ua.LocalizedText
+	Severity    uint16
+	ConditionID ua.NodeID
+	Retain      bool
+	Acked       bool
+	Confirmed   bool
+	Active      bool
+	State       limitState
+	Comment     ua.LocalizedText
+	UserID      string
+	Limits      *config.AlarmLimits
+}
+
+// GetAttribute returns an event field selected by browse path.
+// Fields are matched by path so clients may use any type in the condition hierarchy as TypeDefinitionId.
+func (e *limitAlarmEvent) GetAttribute(clause ua.SimpleAttributeOperand) ua.Variant {
+	names := make([]string, len(clause.BrowsePath))
+	for i, name := range clause.BrowsePath {
+		names[i] = name.Name
+	}
+	path := strings.Join(names, "/")
+
+	if clause.AttributeID == ua.AttributeIDNodeID {
+		if path == "" {
+			return e.ConditionID
+		}
+		return nil
+	}
+
+	switch path {
+	case "EventId":
+		return e.EventID
+	case "EventType":
+		return ua.ObjectTypeIDExclusiveLimitAlarmType
+	case "SourceNode":
+		return e.SourceNode
+	case "SourceName":
+		return e.SourceName
+	case "Time":
+		return e.Time
+	case "ReceiveTime":
+		return e.ReceiveTime
+	case "Message":
+		return e.Message
+	case "Severity":
+		return e.Severity
+	case "ConditionClassId":
+		return ua.ObjectTypeIDProcessConditionClassType
+	case "ConditionClassName":
+		return ua.LocalizedText{Text: "Process", Locale: "en"}
+	case "ConditionName":
+		return "LimitAlarm"
+	case "BranchId":
+		return ua.NodeID(nil)
+	case "Retain":
+		return e.Retain
+	case "Quality":
+		return ua.Good
+	case "LastSeverity":
+		return e.Severity
+	case "Comment":
+		return e.Comment
+	case "ClientUserId":
+		return e.UserID
+	case "EnabledState":
+		return stateText(true, "Enabled", "Disabled")
+	case "EnabledState/Id":
+		return true
+	case "AckedState":
+		return stateText(e.Acked, "Acknowledged", "Unacknowledged")
+	case "AckedState/Id":
+		return e.Acked
+	case "ConfirmedState":
+		return stateText(e.Confirmed, "Confirmed", "Unconfirmed")
+	case "ConfirmedState/Id":
+		return e.Confirmed
+	case "ActiveState", "ActiveState/EffectiveDisplayName":
+		if e.Active {
+			return ua.LocalizedText{Text: e.State.String(), Locale: "en"}
+		}
+		return ua.LocalizedText{Text: "Inactive", Locale: "en"}
+	case "ActiveState/Id":
+		return e.Active
+	case "InputNode":
+		return e.SourceNode
+	case "LimitState/CurrentState":
+		if e.State == limitNormal {
+			return nil
+		}
+		return ua.LocalizedText{Text: e.State.String(), Locale: "en"}
+	case "LimitState/CurrentState/Id":
+		if e.State == limitNormal {
+			return nil
+		}
+		return e.State.stateID()
+	case "HighHighLimit":
+		return optionalLimit(e.Limits.HighHigh)
+	case "HighLimit":
+		return optionalLimit(e.Limits.High)
+	case "LowLimit":
+		return optionalLimit(e.Limits.Low)
+	case "LowLowLimit":
+		return optionalLimit(e.Limits.LowLow)
+	default:
+		return nil
+	}
+}
+
+// stateText returns the display text of a two-state variable
+func stateText(state bool, trueText, falseText string) ua.LocalizedText {
+	if state {
+		return ua.LocalizedText{Text: trueText, Locale: "en"}
+	}
+	return ua.LocalizedText{Text: falseText, Locale: "en"}
+}
+
+// optionalLimit returns a limit value or nil when the limit is not set
+func optionalLimit(limit *float64) ua.Variant {
+	if limit == nil {
+		return nil
+	}
+	return *limit
+}
+
+// newEventID returns a random 16 byte event ID
+func newEventID() ua.ByteString {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return ua.ByteString(b)
+}
+
+// alarmNodes creates the condition object of a tag under its control object
+func (s *OPCUAServer) alarmNodes(tag *plc.Tag, controlID, sourceID ua.NodeID) []server.Node {
+	conditionID := ua.NodeIDString{NamespaceIndex: s.namespace, ID: config.ControlFolder + "/" + tag.Name + "/LimitAlarm"}
+	s.publishMu.Lock()
+	s.alarms[tag.Name] = newLimitAlarm(s.server.NamespaceManager(), tag, conditionID, controlID, sourceID)
+	s.publishMu.Unlock()
+
+	condition := server.NewObjectNode(
+		s.server,
+		conditionID,
+		ua.QualifiedName{NamespaceIndex: s.namespace, Name: "LimitAlarm"},
+		ua.LocalizedText{Text: "LimitAlarm"},
+		ua.LocalizedText{Text: "HiHi/Hi/Lo/LoLo limit alarm of " + tag.Name},
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+				TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectTypeIDExclusiveLimitAlarmType},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasComponent,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: controlID},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasCondition,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: sourceID},
+			},
+		},
+		0,
+	)
+
+	nodes := []server.Node{condition}
+	limits := []struct {
+		name  string
+		value *float64
+	}{
+		{"HighHighLimit", tag.Alarms.HighHigh},
+		{"HighLimit", tag.Alarms.High},
+		{"LowLimit", tag.Alarms.Low},
+		{"LowLowLimit", tag.Alarms.LowLow},
+	}
+	for _, limit := range limits {
+		if limit.value == nil {
+			continue
+		}
+		nodes = append(nodes, server.NewVariableNode(
+			s.server,
+			ua.NodeIDString{NamespaceIndex: s.namespace, ID: conditionID.ID + "/" + limit.name},
+			ua.QualifiedName{NamespaceIndex: 0, Name: limit.name},
+			ua.LocalizedText{Text: limit.name},
+			ua.LocalizedText{},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.VariableTypeIDPropertyType},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasProperty,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: conditionID},
+				},
+			},
+			ua.NewDataValue(*limit.value, 0, time.Now(), 0, time.Now(), 0),
+			ua.DataTypeIDDouble,
+			ua.ValueRankScalar,
+			[]uint32{},
+			ua.AccessLevelsCurrentRead,
+			0,
+			false,
+			nil,
+		))
+	}
+
+	return nodes
+}
+
+// evaluateAlarm checks a tag value against the limits of its alarm
+func (s *OPCUAServer) evaluateAlarm(tagName string, value interface{}) {
+	alarm, ok := s.alarms[tagName]
+	if !ok {
+		return
+	}
+	if v, ok := numericValue(value); ok {
+		alarm.evaluate(v)
+	}
+}
+
+// registerConditionMethods installs the Acknowledge, Confirm and ConditionRefresh handlers
+func (s *OPCUAServer) registerConditionMethods() {
+	nm := s.server.NamespaceManager()
+
+	if n, ok := nm.FindMethod(ua.MethodIDAcknowledgeableConditionTypeAcknowledge); ok {
+		n.SetCallMethodHandler(s.newConditionHandler((*limitAlarm).acknowledge))
+	}
+	if n, ok := nm.FindMethod(ua.MethodIDAcknowledgeableConditionTypeConfirm); ok {
+		n.SetCallMethodHandler(s.newConditionHandler((*limitAlarm).confirm))
+	}
+	if n, ok := nm.FindMethod(ua.MethodIDConditionTypeConditionRefresh); ok {
+		n.SetCallMethodHandler(s.handleConditionRefresh)
+	}
+	if n, ok := nm.FindMethod(ua.MethodIDConditionTypeConditionRefresh2); ok {
+		n.SetCallMethodHandler(s.handleConditionRefresh)
+	}
+}
+
+// newConditionHandler returns a handler for Acknowledge/Confirm(EventId, Comment) on a condition object
+func (s *OPCUAServer) newConditionHandler(action func(*limitAlarm, ua.ByteString, ua.LocalizedText, string) ua.StatusCode) func(*server.Session, ua.CallMethodRequest) ua.CallMethodResult {
+	return func(session *server.Session, req ua.CallMethodRequest) ua.CallMethodResult {
+		if len(req.InputArguments) < 2 {
+			return ua.CallMethodResult{StatusCode: ua.BadArgumentsMissing}
+		}
+		if len(req.InputArguments) > 2 {
+			return ua.CallMethodResult{StatusCode: ua.BadTooManyArguments}
+		}
+
+		argResults := make([]ua.StatusCode, 2)
+		eventID, ok := req.InputArguments[0].(ua.ByteString)
+		if !ok {
+			argResults[0] = ua.BadTypeMismatch
+		}
+		comment, ok := req.InputArguments[1].(ua.LocalizedText)
+		if !ok {
+			argResults[1] = ua.BadTypeMismatch
+		}
+		if argResults[0] != ua.Good || argResults[1] != ua.Good {
+			return ua.CallMethodResult{StatusCode: ua.BadInvalidArgument, InputArgumentResults: argResults}
+		}
+
+		alarm := s.findAlarm(req.ObjectID)
+		if alarm == nil {
+			return ua.CallMethodResult{StatusCode: ua.BadNodeIDInvalid}
+		}
+		return ua.CallMethodResult{StatusCode: action(alarm, eventID, comment, sessionUserName(session)), InputArgumentResults: argResults}
+	}
+}
+
+// handleConditionRefresh resends the retained conditions to a subscription (or one monitored item),
+// framed by RefreshStart/RefreshEnd events
+func (s *OPCUAServer) handleConditionRefresh(session *server.Session, req ua.CallMethodRequest) ua.CallMethodResult {
+	want := 1
+	if req.MethodID == ua.MethodIDConditionTypeConditionRefresh2 {
+		want = 2
+	}
+	if len(req.InputArguments) < want {
+		return ua.CallMethodResult{StatusCode: ua.BadArgumentsMissing}
+	}
+	if len(req.InputArguments) > want {
+		return ua.CallMethodResult{StatusCode: ua.BadTooManyArguments}
+	}
+
+	subscriptionID, ok := req.InputArguments[0].(uint32)
+	if !ok {
+		return ua.CallMethodResult{StatusCode: ua.BadInvalidArgument, InputArgumentResults: []ua.StatusCode{ua.BadTypeMismatch}}
+	}
+
+	sub, ok := s.server.SubscriptionManager().Get(subscriptionID)
+	if !ok || !ownsSubscription(s.server, session, sub) {
+		return ua.CallMethodResult{StatusCode: ua.BadSubscriptionIDInvalid}
+	}
+
+	var items []*server.EventMonitoredItem
+	for _, item := range sub.Items() {
+		if em, ok := item.(*server.EventMonitoredItem); ok {
+			if want == 2 && item.ID() != req.InputArguments[1] {
+				continue
+			}
+			items = append(items, em)
+		}
+	}
+	if want == 2 && len(items) == 0 {
+		return ua.CallMethodResult{StatusCode: ua.BadMonitoredItemIDInvalid}
+	}
+
+	events := []ua.Event{refreshEvent(ua.ObjectTypeIDRefreshStartEventType)}
+	s.mu.RLock()
+	for _, alarm := range s.alarms {
+		if evt, ok := alarm.snapshot(); ok {
+			events = append(events, evt)
+		}
+	}
+	s.mu.RUnlock()
+	events = append(events, refreshEvent(ua.ObjectTypeIDRefreshEndEventType))
+
+	for _, item := range items {
+		for _, evt := range events {
+			item.OnEvent(evt)
+		}
+	}
+	return ua.CallMethodResult{StatusCode: ua.Good}
+}
+
+// ownsSubscription reports whether a subscription belongs to the session
+func ownsSubscription(srv *server.Server, session *server.Session, sub *server.Subscription) bool {
+	for _, owned := range srv.SubscriptionManager().GetBySession(session) {
+		if owned == sub {
+			return true
+		}
+	}
+	return false
+}
+
+// findAlarm returns the alarm of a condition node ID
+func (s *OPCUAServer) findAlarm(conditionID ua.NodeID) *limitAlarm {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, alarm := range s.alarms {
+		if alarm.conditionID == conditionID {
+			return alarm
+		}
+	}
+	return nil
+}
+
+// refreshEvent creates a RefreshStart/RefreshEnd event
+func refreshEvent(eventType ua.NodeID) ua.Event {
+	now := time.Now()
+	return &ua.BaseEvent{
+		EventID:     newEventID(),
+		EventType:   eventType,
+		SourceNode:  ua.ObjectIDServer,
+		SourceName:  "Server",
+		Time:        now,
+		ReceiveTime: now,
+		Severity:    100,
+	}
+}
+
+// sessionUserName returns the user name of a session for ClientUserId
+func sessionUserName(session *server.Session) string {
+	if session == nil {
+		return ""
+	}
+	switch identity := session.UserIdentity().(type) {
+	case ua.UserNameIdentity:
+		return identity.UserName
+	case ua.X509Identity:
+		return "X509"
+	default:
+		return "Anonymous"
+	}
+}
@@ -0,0 +1,155 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// analogRanges returns the EURange and InstrumentRange of an analog tag.
+// The instrument range defaults to the sensor output range and the EURange to the instrument range.
+// ok is false for non-numeric tags and tags without any known range.
+func (s *OPCUAServer) analogRanges(tag *plc.Tag) (eu, instrument *config.Range, ok bool) {
+	if !tag.Type.IsNumeric() {
+		return nil, nil, false
+	}
+
+	instrument = tag.Instrument
+	if instrument == nil && s.sensorManager != nil {
+		if low, high, found := s.sensorManager.SensorRange(tag.Name); found && low < high {
+			instrument = &config.Range{Low: low, High: high}
+		}
+	}
+
+	eu = tag.EURange
+	if eu == nil {
+		eu = instrument
+	}
+	return eu, instrument, eu != nil
+}
+
+// checkWriteRange rejects a client write of a value, or array elements, outside the configured instrument range,
+// the command range of an actuator or the output range of a sensor with BadOutOfRange, like a PLC rejects
+// setpoints its device cannot output
+func (s *OPCUAServer) checkWriteRange(tagName string, value interface{}) ua.StatusCode {
+	tag, err := s.tagManager.GetTag(tagName)
+	if err != nil {
+		return ua.BadNodeIDUnknown
+	}
+	if !tag.Type.IsNumeric() {
+		return ua.Good
+	}
+	limits := tag.Instrument
+	if limits == nil && s.sensorManager != nil {
+		if low, high, found := s.sensorManager.WriteRange(tag.Name); found && low < high {
+			limits = &config.Range{Low: low, High: high}
+		}
+	}
+	if limits == nil {
+		return ua.Good
+	}
+
+	for _, v := range elements(value) {
+		f, numeric := numericValue(v)
+		if numeric && !(f >= limits.Low && f <= limits.High) {
+			log.Printf("[OPCUA] Write to %s rejected: %v is outside the write range [%g, %g]", tagName, v, limits.Low, limits.High)
+			return ua.BadOutOfRange
+		}
+	}
+	return ua.Good
+}
+
+// analogProperties creates the EURange, InstrumentRange and EngineeringUnits properties of an AnalogItemType node.
+// The property NodeIds are s=<TagName>.<Property> in the tag namespace.
+func (s *OPCUAServer) analogProperties(tag *plc.Tag, nodeID ua.NodeID, eu, instrument *config.Range) []server.Node {
+	nodes := []server.Node{
+		s.propertyNode(nodeID, tag.Name, "EURange", ua.Range{Low: eu.Low, High: eu.High}, ua.DataTypeIDRange),
+	}
+	if instrument != nil {
+		nodes = append(nodes, s.propertyNode(nodeID, tag.Name, "InstrumentRange", ua.Range{Low: instrument.Low, High: instrument.High}, ua.DataTypeIDRange))
+	}
+	if tag.Units != "" {
+		unit, err := config.LookupUnit(tag.Units)
+		if err == nil {
+			nodes = append(nodes, s.propertyNode(nodeID, tag.Name, "EngineeringUnits", ua.EUInformation{
+				NamespaceURI: config.UnitsNamespaceURI,
+				UnitID:       unit.UnitID(),
+				DisplayName:  ua.LocalizedText{Text: unit.Symbol},
+				Description:  ua.LocalizedText{Text: unit.Name},
+			}, ua.DataTypeIDEUInformation))
+		}
+	}
+	return nodes
+}
+
+// propertyNode creates a read-only standard property (namespace 0 browse name) of a variable node
+func (s *OPCUAServer) propertyNode(parentID ua.NodeID, idPrefix, name string, value ua.Variant, dataType ua.NodeID) server.Node {
+	return server.NewVariableNode(
+		s.server,
+		ua.NodeIDString{NamespaceIndex: namespaceIndex(parentID), ID: idPrefix + "." + name},
+		ua.QualifiedName{Name: name},
+		ua.LocalizedText{Text: name},
+		ua.LocalizedText{},
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+				TargetID:        ua.ExpandedNodeID{NodeID: ua.VariableTypeIDPropertyType},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasProperty,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: parentID},
+			},
+		},
+		ua.NewDataValue(value, 0, time.Now(), 0, time.Now(), 0),
+		dataType,
+		ua.ValueRankScalar,
+		[]uint32{},
+		ua.AccessLevelsCurrentRead,
+		0,
+		false,
+		nil,
+	)
+}
+
+// withinDeadband reports whether a sample differs from the published value by no more than the absolute
+// deadband in every element, so that it is not published. The server library reports every change to
+// monitored items with a percent deadband filter, so the percent deadband of the tag is applied here
+// (Part 8: a percent of the EURange span). Quality changes are always published.
+func withinDeadband(published ua.DataValue, value interface{}, quality plc.Quality, deadband float64) bool {
+	if deadband <= 0 || published.StatusCode != ua.StatusCode(quality) {
+		return false
+	}
+	current, previous := elements(value), elements(published.Value)
+	if len(current) != len(previous) {
+		return false
+	}
+	for i := range current {
+		f, ok := numericValue(current[i])
+		last, lastOK := numericValue(previous[i])
+		if !ok || !lastOK || !(math.Abs(f-last) <= deadband) {
+			return false
+		}
+	}
+	return true
+}
+
+// elements returns the elements of a slice value or the value itself
+func elements(value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return []interface{}{value}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
@@ -0,0 +1,100 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim"
+	"testing"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// analogTestServer returns a server over the tags and sensors of the definitions
+func analogTestServer(t *testing.T, defs ...config.SensorDefinition) *OPCUAServer {
+	t.Helper()
+	tagManager, err := plc.GenerateTagsFromSensors(defs)
+	if err != nil {
+		t.Fatalf("generate tags: %v", err)
+	}
+	sensorManager, err := sim.NewSensorManager(tagManager, &config.SensorConfig{Sensors: defs})
+	if err != nil {
+		t.Fatalf("sensor manager: %v", err)
+	}
+	return NewOPCUAServer(Config{}, tagManager, sensorManager)
+}
+
+func TestCheckWriteRange(t *testing.T) {
+	servo := func(name, outputMode string) config.SensorDefinition {
+		return config.SensorDefinition{
+			Name:    name,
+			Type:    "servomotor",
+			Enabled: true,
+			Address: "%DF152",
+			Parameters: map[string]interface{}{
+				"maxVelocity": 1500.0,
+				"outputMode":  outputMode,
+			},
+		}
+	}
+	position := servo("Servo_Position", "position")
+	velocity := servo("Servo_Velocity", "velocity")
+	velocity.Address = "%DF154"
+	limited := servo("Servo_Limited", "position")
+	limited.Address = "%DF156"
+	limited.InstrumentRange = &config.Range{Low: 0, High: 360}
+	s := analogTestServer(t, position, velocity, limited)
+
+	tests := []struct {
+		name  string
+		tag   string
+		value interface{}
+		want  ua.StatusCode
+	}{
+		// A position servo outputs 0..360 degrees but is commanded in RPM
+		{"position servo velocity", "Servo_Position", 1000.0, ua.Good},
+		{"position servo reverse", "Servo_Position", -1500.0, ua.Good},
+		{"position servo too fast", "Servo_Position", 2000.0, ua.BadOutOfRange},
+		{"velocity servo too fast", "Servo_Velocity", -1600.0, ua.BadOutOfRange},
+		// An explicit instrument range is checked instead
+		{"instrument range", "Servo_Limited", 1000.0, ua.BadOutOfRange},
+		{"within instrument range", "Servo_Limited", 90.0, ua.Good},
+		{"unknown tag", "Missing", 1.0, ua.BadNodeIDUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.checkWriteRange(tt.tag, tt.value); got != tt.want {
+				t.Errorf("checkWriteRange(%s, %v) = %v, want %v", tt.tag, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithinDeadband(t *testing.T) {
+	published := ua.NewDataValue(50.0, ua.Good, historyTestStart, 0, historyTestStart, 0)
+	publishedArray := ua.NewDataValue([]float64{1, 2}, ua.Good, historyTestStart, 0, historyTestStart, 0)
+	tests := []struct {
+		name      string
+		published ua.DataValue
+		value     interface{}
+		quality   plc.Quality
+		deadband  float64
+		want      bool
+	}{
+		{"within", published, 50.9, plc.QualityGood, 1, true},
+		{"at the deadband", published, 49.0, plc.QualityGood, 1, true},
+		{"beyond", published, 51.5, plc.QualityGood, 1, false},
+		{"no deadband", published, 50.0, plc.QualityGood, 0, false},
+		{"quality change", published, 50.0, plc.QualityBad, 1, false},
+		{"first value", ua.DataValue{}, 50.0, plc.QualityGood, 1, false},
+		{"array within", publishedArray, []float64{1.5, 2.5}, plc.QualityGood, 1, true},
+		{"array element beyond", publishedArray, []float64{1, 4}, plc.QualityGood, 1, false},
+		{"array length change", publishedArray, []float64{1, 2, 3}, plc.QualityGood, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinDeadband(tt.published, tt.value, tt.quality, tt.deadband); got != tt.want {
+				t.Errorf("withinDeadband(%v, %v) = %v, want %v", tt.published.Value, tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package opcuaserver
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// Permission sets granted by the per-tag access strings
+const (
+	permissionsNone      = ua.PermissionTypeBrowse
+	permissionsReadOnly  = ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeReadHistory | ua.PermissionTypeReceiveEvents
+	permissionsReadWrite = permissionsReadOnly | ua.PermissionTypeWrite
+)
+
+// serverRolePermissions returns the library default role permissions, where anonymous sessions only browse and read.
+// anonymousWrite also grants the anonymous role write and call access, so EPICS output records and test scripts
+// can drive the simulated actuators and call the methods without logging in.
+func serverRolePermissions(anonymousWrite bool) []ua.RolePermissionType {
+	permissions := make([]ua.RolePermissionType, len(server.DefaultRolePermissions))
+	copy(permissions, server.DefaultRolePermissions)
+	if !anonymousWrite {
+		return permissions
+	}
+	for i := range permissions {
+		if permissions[i].RoleID == ua.ObjectIDWellKnownRoleAnonymous {
+			permissions[i].Permissions |= ua.PermissionTypeWrite | ua.PermissionTypeCall
+		}
+	}
+	return permissions
+}
+
+// wellKnownRoles maps normalized role names to the OPC UA well-known roles
+var wellKnownRoles = map[string]ua.NodeID{
+	"anonymous":         ua.ObjectIDWellKnownRoleAnonymous,
+	"authenticateduser": ua.ObjectIDWellKnownRoleAuthenticatedUser,
+	"observer":          ua.ObjectIDWellKnownRoleObserver,
+	"operator":          ua.ObjectIDWellKnownRoleOperator,
+	"engineer":          ua.ObjectIDWellKnownRoleEngineer,
+	"supervisor":        ua.ObjectIDWellKnownRoleSupervisor,
+	"configureadmin":    ua.ObjectIDWellKnownRoleConfigureAdmin,
+	"securityadmin":     ua.ObjectIDWellKnownRoleSecurityAdmin,
+}
+
+// roleNodeID returns the role node ID for a role name, compared case-insensitively.
+// Well-known role names map to the standard roles, other names get a custom role ID
+// in the server namespace (index 1).
+func roleNodeID(name string) ua.NodeID {
+	name = config.NormalizeRole(name)
+	if id, ok := wellKnownRoles[name]; ok {
+		return id
+	}
+	return ua.NodeIDString{NamespaceIndex: 1, ID: "Roles/" + name}
+}
+
+// tagAccessLevel returns the AccessLevel of a tag variable. The UserAccessLevel of a session is
+// this access level restricted by the role permissions.
+func tagAccessLevel(tag *plc.Tag) byte {
+	if tag.ReadOnly {
+		return ua.AccessLevelsCurrentRead
+	}
+	return ua.AccessLevelsCurrentRead | ua.AccessLevelsCurrentWrite
+}
+
+// tagRolePermissions returns the node role permissions for a tag, or nil to use the server defaults.
+// The tag permissions are keyed by normalized role names.
+// Roles not listed in the tag permissions keep read-only access, except AuthenticatedUser: every logged-in
+// user has it, so it would grant read access to roles listed with "none".
+func (s *OPCUAServer) tagRolePermissions(tag *plc.Tag) []ua.RolePermissionType {
+	if len(tag.Permissions) == 0 {
+		return nil
+	}
+
+	modes := tag.Permissions
+	permissions := make([]ua.RolePermissionType, 0, len(s.permissions)+len(modes))
+	for _, rp := range s.permissions {
+		if _, listed := modes[roleName(rp.RoleID)]; listed {
+			continue
+		}
+		if rp.RoleID == ua.ObjectIDWellKnownRoleAuthenticatedUser {
+			rp.Permissions = permissionsNone
+		}
+		rp.Permissions &^= ua.PermissionTypeWrite
+		permissions = append(permissions, rp)
+	}
+	for _, role := range s.customRoles() {
+		if _, listed := modes[role]; !listed {
+			permissions = append(permissions, ua.RolePermissionType{RoleID: roleNodeID(role), Permissions: permissionsReadOnly})
+		}
+	}
+
+	for role, access := range modes {
+		rp := ua.RolePermissionType{RoleID: roleNodeID(role)}
+		switch access {
+		case "rw":
+			rp.Permissions = permissionsReadWrite
+		case "r":
+			rp.Permissions = permissionsReadOnly
+		default:
+			rp.Permissions = permissionsNone
+		}
+		permissions = append(permissions, rp)
+	}
+
+	return permissions
+}
+
+// customRoles returns the names of the roles of the users file that are not well-known roles
+func (s *OPCUAServer) customRoles() []string {
+	if s.users == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var roles []string
+	add := func(names []string) {
+		for _, name := range names {
+			name = config.NormalizeRole(name)
+			if _, wellKnown := wellKnownRoles[name]; !wellKnown && !seen[name] {
+				seen[name] = true
+				roles = append(roles, name)
+			}
+		}
+	}
+	for _, user := range s.users.Users {
+		add(user.Roles)
+	}
+	for _, cert := range s.users.Certificates {
+		add(cert.Roles)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// roleName returns the normalized name of a role node ID
+func roleName(id ua.NodeID) string {
+	for name, wellKnown := range wellKnownRoles {
+		if wellKnown == id {
+			return name
+		}
+	}
+	if s, ok := id.(ua.NodeIDString); ok {
+		return strings.TrimPrefix(s.ID, "Roles/")
+	}
+	return ""
+}
+
+// userAuthenticator authenticates user identities against the users file
+// and maps them to roles
+type userAuthenticator struct {
+	users        map[string]config.UserDefinition
+	certificates map[string]config.CertificateDefinition // thumbprint -> definition
+}
+
+// newUserAuthenticator creates an authenticator for the given credentials
+func newUserAuthenticator(users *config.UserConfig) *userAuthenticator {
+	a := &userAuthenticator{
+		users:        make(map[string]config.UserDefinition),
+		certificates: make(map[string]config.CertificateDefinition),
+	}
+	for _, user := range users.Users {
+		a.users[user.Username] = user
+	}
+	for _, cert := range users.Certificates {
+		a.certificates[cert.Thumbprint] = cert
+	}
+	return a
+}
+
+// authenticateUserName verifies a username/password identity
+func (a *userAuthenticator) authenticateUserName(identity ua.UserNameIdentity, applicationURI, endpointURL string) error {
+	user, ok := a.users[identity.UserName]
+	if !ok || !user.CheckPassword(identity.Password) {
+		log.Printf("[OPCUA] Rejected user '%s' from %s", identity.UserName, applicationURI)
+		return ua.BadUserAccessDenied
+	}
+	log.Printf("[OPCUA] User '%s' authenticated (roles: %s)", user.Username, strings.Join(user.Roles, ","))
+	return nil
+}
+
+// authenticateX509 verifies an X.509 user certificate identity
+func (a *userAuthenticator) authenticateX509(identity ua.X509Identity, applicationURI, endpointURL string) error {
+	thumbprint := certificateThumbprint(identity.Certificate)
+	cert, ok := a.certificates[thumbprint]
+	if !ok {
+		log.Printf("[OPCUA] Rejected user certificate %s from %s", thumbprint, applicationURI)
+		return ua.BadIdentityTokenRejected
+	}
+	log.Printf("[OPCUA] Certificate user '%s' authenticated (roles: %s)", cert.Name, strings.Join(cert.Roles, ","))
+	return nil
+}
+
+// getRoles returns the roles of a user identity
+func (a *userAuthenticator) getRoles(userIdentity any, applicationURI, endpointURL string) ([]ua.NodeID, error) {
+	switch identity := userIdentity.(type) {
+	case ua.AnonymousIdentity:
+		return []ua.NodeID{ua.ObjectIDWellKnownRoleAnonymous}, nil
+
+	case ua.UserNameIdentity:
+		user, ok := a.users[identity.UserName]
+		if !ok {
+			return nil, ua.BadUserAccessDenied
+		}
+		return appendRoles([]ua.NodeID{ua.ObjectIDWellKnownRoleAuthenticatedUser}, user.Roles), nil
+
+	case ua.X509Identity:
+		cert, ok := a.certificates[certificateThumbprint(identity.Certificate)]
+		if !ok {
+			return nil, ua.BadUserAccessDenied
+		}
+		return appendRoles([]ua.NodeID{ua.ObjectIDWellKnownRoleAuthenticatedUser}, cert.Roles), nil
+
+	default:
+		return nil, ua.BadUserAccessDenied
+	}
+}
+
+// serverOptions returns the identity options for the server
+func (a *userAuthenticator) serverOptions() []server.Option {
+	return []server.Option{
+		server.WithAuthenticateUserNameIdentityFunc(a.authenticateUserName),
+		server.WithAuthenticateX509IdentityFunc(a.authenticateX509),
+		server.WithGetRolesFunc(a.getRoles),
+	}
+}
+
+// appendRoles appends the node IDs of the named roles
+func appendRoles(roles []ua.NodeID, names []string) []ua.NodeID {
+	for _, name := range names {
+		roles = append(roles, roleNodeID(name))
+	}
+	return roles
+}
+
+// certificateThumbprint returns the SHA-1 thumbprint of a DER certificate
+func certificateThumbprint(certificate ua.ByteString) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(certificate)))
+}
@@ -0,0 +1,40 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/plc"
+	"testing"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// anonymousPermissions returns the permissions of the anonymous role in a role permission list
+func anonymousPermissions(permissions []ua.RolePermissionType) ua.PermissionType {
+	for _, rp := range permissions {
+		if rp.RoleID == ua.ObjectIDWellKnownRoleAnonymous {
+			return rp.Permissions
+		}
+	}
+	return 0
+}
+
+func TestServerRolePermissions(t *testing.T) {
+	writeCall := ua.PermissionTypeWrite | ua.PermissionTypeCall
+
+	readOnly := anonymousPermissions(serverRolePermissions(false))
+	if readOnly&writeCall != 0 || readOnly&ua.PermissionTypeRead == 0 || readOnly&ua.PermissionTypeBrowse == 0 {
+		t.Errorf("default anonymous permissions = %#x, want browse and read without write or call", uint32(readOnly))
+	}
+	if writable := anonymousPermissions(serverRolePermissions(true)); writable&writeCall != writeCall {
+		t.Errorf("anonymous write permissions = %#x, want write and call", uint32(writable))
+	}
+
+	// Tag permissions replace the defaults of the listed roles, the other roles keep read access
+	s := &OPCUAServer{permissions: serverRolePermissions(true)}
+	if got := anonymousPermissions(s.tagRolePermissions(&plc.Tag{Permissions: map[string]string{"operator": "rw"}})); got&ua.PermissionTypeWrite != 0 {
+		t.Errorf("anonymous permissions on an operator tag = %#x, want no write", uint32(got))
+	}
+	s.permissions = serverRolePermissions(false)
+	if got := anonymousPermissions(s.tagRolePermissions(&plc.Tag{Permissions: map[string]string{"anonymous": "rw"}})); got&ua.PermissionTypeWrite == 0 {
+		t.Errorf("anonymous permissions on an anonymous rw tag = %#x, want write", uint32(got))
+	}
+}
@@ -0,0 +1,786 @@
+package opcuaserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// ChaosConfig enables fault injection for client reconnection tests
+type ChaosConfig struct {
+	Schedule   *config.ChaosSchedule // Faults injected at fixed times after startup, nil = none
+	APIAddress string                // HTTP control address (e.g. 127.0.0.1:4850), empty = none
+}
+
+const (
+	messageHeaderSize     = 8
+	maxMessageSize        = 16 * 1024 * 1024
+	chunkTypeIntermediate = 'C' // header byte 3, the final chunk of a message is 'F' (or 'A' when aborted)
+)
+
+// chaos is the fault injection layer. A proxy listens on the endpoint and forwards the connections
+// to the server on an internal port. It passes whole OPC UA TCP messages (the header is never encrypted),
+// so server messages can be held back or discarded without breaking the framing.
+// On channels with the None security policy the message bodies are plain, so the proxy also
+// learns the session tokens and can shorten the security token lifetime.
+type chaos struct {
+	cfg        ChaosConfig
+	listenAddr string // endpoint port
+	targetAddr string // internal server address
+	srv        *server.Server
+	sensors    *sim.SensorManager // sensorQuality targets
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*chaosConn]bool
+	active   map[string]*activeFault // timed faults
+	api      *http.Server
+	stopped  bool
+}
+
+// activeFault is a timed fault in effect
+type activeFault struct {
+	config.ChaosFault
+	until   time.Time // zero = until cleared
+	timer   *time.Timer
+	restore map[string]plc.Quality // sensorQuality: the sensor qualities before the fault
+}
+
+// newChaos creates the fault injection layer for an endpoint and returns the internal endpoint of the server
+func newChaos(cfg ChaosConfig, endpoint string, sensorManager *sim.SensorManager) (*chaos, string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Port() == "" {
+		return nil, "", fmt.Errorf("invalid endpoint %s", endpoint)
+	}
+	if cfg.Schedule != nil {
+		for i, step := range cfg.Schedule.Steps {
+			if step.Fault != config.FaultSensorQuality {
+				continue
+			}
+			if _, err := plc.ParseQuality(step.Quality); err != nil {
+				return nil, "", fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+	}
+
+	// The library listens on the port of its endpoint URL, pick a free one for it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to reserve internal port: %w", err)
+	}
+	targetAddr := ln.Addr().String()
+	ln.Close()
+
+	c := &chaos{
+		cfg:        cfg,
+		listenAddr: ":" + u.Port(),
+		targetAddr: targetAddr,
+		sensors:    sensorManager,
+		conns:      make(map[*chaosConn]bool),
+		active:     make(map[string]*activeFault),
+	}
+	return c, "opc.tcp://" + targetAddr, nil
+}
+
+// start opens the proxy listener, the control API and runs the schedule until ctx is done
+func (c *chaos) start(ctx context.Context, srv *server.Server) error {
+	c.srv = srv
+	if err := c.listen(); err != nil {
+		return err
+	}
+	log.Printf("[CHAOS] Fault injection proxy on %s -> %s", c.listenAddr, c.targetAddr)
+
+	if c.cfg.APIAddress != "" {
+		c.api = &http.Server{Addr: c.cfg.APIAddress, Handler: c.apiHandler()}
+		go func() {
+			if err := c.api.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[CHAOS] Control API error: %v", err)
+			}
+		}()
+		log.Printf("[CHAOS] Control API on http://%s/chaos", c.cfg.APIAddress)
+	}
+	if c.cfg.Schedule != nil {
+		go c.runSchedule(ctx, c.cfg.Schedule)
+		log.Printf("[CHAOS] Schedule: %d steps (repeat: %dms)", len(c.cfg.Schedule.Steps), c.cfg.Schedule.RepeatMs)
+	}
+	return nil
+}
+
+// stop closes the proxy, the client connections and the control API
+func (c *chaos) stop() {
+	c.mu.Lock()
+	c.stopped = true
+	for _, fault := range c.active {
+		if fault.timer != nil {
+			fault.timer.Stop()
+		}
+	}
+	if c.listener != nil {
+		c.listener.Close()
+		c.listener = nil
+	}
+	api := c.api
+	c.mu.Unlock()
+
+	c.closeConns()
+	if api != nil {
+		api.Close()
+	}
+}
+
+// listen opens the proxy listener on the endpoint port
+func (c *chaos) listen() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped || c.listener != nil {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", c.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", c.listenAddr, err)
+	}
+	c.listener = ln
+	go c.accept(ln)
+	return nil
+}
+
+// accept forwards the connections of a listener until it is closed
+func (c *chaos) accept(ln net.Listener) {
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go c.forward(client)
+	}
+}
+
+// forward connects a client to the server and pumps the messages in both directions
+func (c *chaos) forward(client net.Conn) {
+	upstream, err := net.DialTimeout("tcp", c.targetAddr, 5*time.Second)
+	if err != nil {
+		log.Printf("[CHAOS] Failed to connect %s to the server: %v", client.RemoteAddr(), err)
+		client.Close()
+		return
+	}
+	c.proxy(client, upstream)
+}
+
+// proxy pumps the messages of a client connection and its server connection in both directions
+func (c *chaos) proxy(client, upstream net.Conn) {
+	conn := &chaosConn{chaos: c, client: client, upstream: upstream, out: make(chan pendingMessage, 256),
+		tokens: make(map[ua.NodeID]bool)}
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		conn.close()
+		return
+	}
+	c.conns[conn] = true
+	c.mu.Unlock()
+
+	go conn.send()
+	go conn.deliver()
+	conn.receive()
+}
+
+// responseFault returns whether server messages are discarded and how long they are held back
+func (c *chaos) responseFault() (drop bool, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.active[config.FaultDropResponses]; ok {
+		return true, 0
+	}
+	if fault, ok := c.active[config.FaultDelayResponses]; ok {
+		return false, time.Duration(fault.DelayMs) * time.Millisecond
+	}
+	return false, 0
+}
+
+// tokenLifetime returns the security token lifetime while expireChannels is in effect, 0 otherwise
+func (c *chaos) tokenLifetime() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fault, ok := c.active[config.FaultExpireChannels]; ok {
+		return time.Duration(fault.LifetimeMs) * time.Millisecond
+	}
+	return 0
+}
+
+// connList returns the open client connections
+func (c *chaos) connList() []*chaosConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conns := make([]*chaosConn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// closeConns closes all client connections
+func (c *chaos) closeConns() int {
+	conns := c.connList()
+	for _, conn := range conns {
+		conn.close()
+	}
+	return len(conns)
+}
+
+// dropSessions deletes the sessions used on the proxied connections. Only requests on channels
+// with the None security policy can be read, sessions of secure channels are kept.
+func (c *chaos) dropSessions() int {
+	count := 0
+	for _, conn := range c.connList() {
+		for _, token := range conn.takeSessionTokens() {
+			if session, ok := c.srv.SessionManager().Get(token); ok {
+				c.srv.SessionManager().Delete(session)
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Inject starts a fault, a timed fault already in effect is restarted with the new parameters
+func (c *chaos) Inject(fault config.ChaosFault) error {
+	if err := fault.Validate(); err != nil {
+		return err
+	}
+
+	switch fault.Fault {
+	case config.FaultDropSessions:
+		log.Printf("[CHAOS] Dropped %d sessions", c.dropSessions())
+		return nil
+	case config.FaultDisconnect:
+		log.Printf("[CHAOS] Closed %d connections", c.closeConns())
+		return nil
+	}
+
+	var quality plc.Quality
+	var targets []string
+	if fault.Fault == config.FaultSensorQuality {
+		var err error
+		if quality, targets, err = c.qualityTargets(fault); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	old := c.active[fault.Fault]
+	if old != nil && old.timer != nil {
+		old.timer.Stop()
+	}
+	active := &activeFault{ChaosFault: fault}
+	if fault.Fault == config.FaultSensorQuality {
+		// A restarted fault keeps the qualities from before the first injection
+		active.restore = make(map[string]plc.Quality)
+		if old != nil {
+			for name, previous := range old.restore {
+				active.restore[name] = previous
+			}
+		}
+		for _, name := range targets {
+			if _, ok := active.restore[name]; !ok {
+				if previous, err := c.sensors.SensorQuality(name); err == nil {
+					active.restore[name] = previous
+				}
+			}
+		}
+	}
+	if fault.DurationMs > 0 {
+		duration := time.Duration(fault.DurationMs) * time.Millisecond
+		active.until = time.Now().Add(duration)
+		active.timer = time.AfterFunc(duration, func() { c.end(fault.Fault, active) })
+	}
+	c.active[fault.Fault] = active
+	var ln net.Listener
+	if fault.Fault == config.FaultCloseListener {
+		ln, c.listener = c.listener, nil
+	}
+	c.mu.Unlock()
+
+	if ln != nil {
+		ln.Close()
+		c.closeConns()
+	}
+	if fault.Fault == config.FaultExpireChannels {
+		// The current tokens of all channels count as issued now
+		lifetime := time.Duration(fault.LifetimeMs) * time.Millisecond
+		conns := c.connList()
+		for _, conn := range conns {
+			conn.expireToken(lifetime)
+		}
+		log.Printf("[CHAOS] Security tokens of %d secure channels expire in %v", len(conns), lifetime)
+	}
+	for _, name := range targets {
+		if err := c.sensors.SetSensorQuality(name, quality); err != nil {
+			log.Printf("[CHAOS] Failed to set quality of %s: %v", name, err)
+		}
+	}
+	log.Printf("[CHAOS] %s started (%s)", fault.Fault, faultDuration(fault))
+	return nil
+}
+
+// end ends a timed fault, unless it was restarted or cleared meanwhile (active = nil ends any)
+func (c *chaos) end(name string, active *activeFault) {
+	c.mu.Lock()
+	current, ok := c.active[name]
+	if !ok || (active != nil && current != active) {
+		c.mu.Unlock()
+		return
+	}
+	if current.timer != nil {
+		current.timer.Stop()
+	}
+	delete(c.active, name)
+	c.mu.Unlock()
+
+	if name == config.FaultCloseListener {
+		if err := c.listen(); err != nil {
+			log.Printf("[CHAOS] Failed to reopen listener: %v", err)
+		}
+	}
+	if name == config.FaultExpireChannels {
+		for _, conn := range c.connList() {
+			conn.expireToken(0)
+		}
+	}
+	for sensor, quality := range current.restore {
+		if err := c.sensors.SetSensorQuality(sensor, quality); err != nil {
+			log.Printf("[CHAOS] Failed to restore quality of %s: %v", sensor, err)
+		}
+	}
+	log.Printf("[CHAOS] %s ended", name)
+}
+
+// qualityTargets returns the quality and the sensors of a sensorQuality fault
+func (c *chaos) qualityTargets(fault config.ChaosFault) (plc.Quality, []string, error) {
+	if c.sensors == nil {
+		return 0, nil, fmt.Errorf("%s requires the sensor simulation", fault.Fault)
+	}
+	quality, err := plc.ParseQuality(fault.Quality)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(fault.Sensors) > 0 {
+		for _, name := range fault.Sensors {
+			if c.sensors.GetSensor(name) == nil {
+				return 0, nil, fmt.Errorf("sensor not found: %s", name)
+			}
+		}
+		return quality, fault.Sensors, nil
+	}
+
+	var targets []string
+	for _, sensor := range c.sensors.GetAllSensors() {
+		targets = append(targets, sensor.GetName())
+	}
+	return quality, targets, nil
+}
+
+// Clear ends all timed faults
+func (c *chaos) Clear() {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.active))
+	for name := range c.active {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	for _, name := range names {
+		c.end(name, nil)
+	}
+}
+
+// refusing reports whether new sessions are refused
+func (c *chaos) refusing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.active[config.FaultRefuseSessions]
+	return ok
+}
+
+// admit fails session activation while refuseSessions is in effect
+func (c *chaos) admit(applicationURI string) error {
+	if c.refusing() {
+		log.Printf("[CHAOS] Refused session of %s", applicationURI)
+		return ua.BadTooManySessions
+	}
+	return nil
+}
+
+// identityOptions wraps the identity authenticators with the refuseSessions fault
+func (c *chaos) identityOptions(anonymous bool, auth *userAuthenticator) []server.Option {
+	var opts []server.Option
+	if anonymous {
+		opts = append(opts, server.WithAuthenticateAnonymousIdentityFunc(
+			func(identity ua.AnonymousIdentity, applicationURI, endpointURL string) error {
+				return c.admit(applicationURI)
+			}))
+	}
+	if auth != nil {
+		opts = append(opts,
+			server.WithAuthenticateUserNameIdentityFunc(func(identity ua.UserNameIdentity, applicationURI, endpointURL string) error {
+				if err := c.admit(applicationURI); err != nil {
+					return err
+				}
+				return auth.authenticateUserName(identity, applicationURI, endpointURL)
+			}),
+			server.WithAuthenticateX509IdentityFunc(func(identity ua.X509Identity, applicationURI, endpointURL string) error {
+				if err := c.admit(applicationURI); err != nil {
+					return err
+				}
+				return auth.authenticateX509(identity, applicationURI, endpointURL)
+			}),
+		)
+	}
+	return opts
+}
+
+// runSchedule injects the faults of a schedule at their times after the start
+func (c *chaos) runSchedule(ctx context.Context, schedule *config.ChaosSchedule) {
+	steps := append([]config.ChaosStep(nil), schedule.Steps...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].AtMs < steps[j].AtMs })
+
+	start := time.Now()
+	for {
+		for _, step := range steps {
+			timer := time.NewTimer(time.Until(start.Add(time.Duration(step.AtMs) * time.Millisecond)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if err := c.Inject(step.ChaosFault); err != nil {
+				log.Printf("[CHAOS] Failed to inject %s: %v", step.Fault, err)
+			}
+		}
+		if schedule.RepeatMs == 0 {
+			return
+		}
+		start = start.Add(time.Duration(schedule.RepeatMs) * time.Millisecond)
+	}
+}
+
+// apiHandler serves the control API:
+//
+//	POST /chaos/{fault}?durationMs=&delayMs=&lifetimeMs=&quality=&sensors=a,b  inject a fault
+//	POST /chaos/clear                                                          end all timed faults
+//	GET  /chaos                                                                active timed faults (JSON)
+func (c *chaos) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /chaos", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.status())
+	})
+	mux.HandleFunc("POST /chaos/clear", func(w http.ResponseWriter, r *http.Request) {
+		c.Clear()
+		fmt.Fprintln(w, "cleared")
+	})
+	mux.HandleFunc("POST /chaos/{fault}", func(w http.ResponseWriter, r *http.Request) {
+		fault := config.ChaosFault{Fault: r.PathValue("fault"), Quality: r.URL.Query().Get("quality")}
+		if sensors := r.URL.Query().Get("sensors"); sensors != "" {
+			fault.Sensors = strings.Split(sensors, ",")
+		}
+		var err error
+		if fault.DurationMs, err = queryInt(r, "durationMs"); err == nil {
+			fault.DelayMs, err = queryInt(r, "delayMs")
+		}
+		if err == nil {
+			fault.LifetimeMs, err = queryInt(r, "lifetimeMs")
+		}
+		if err == nil {
+			err = c.Inject(fault)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// faultStatus is an active fault reported by the control API
+type faultStatus struct {
+	config.ChaosFault
+	RemainingMs int64 `json:"remainingMs,omitempty"` // 0 = until cleared
+}
+
+func (c *chaos) status() []faultStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	faults := make([]faultStatus, 0, len(c.active))
+	for _, fault := range c.active {
+		status := faultStatus{ChaosFault: fault.ChaosFault}
+		if !fault.until.IsZero() {
+			status.RemainingMs = max(time.Until(fault.until).Milliseconds(), 1)
+		}
+		faults = append(faults, status)
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Fault < faults[j].Fault })
+	return faults
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(r *http.Request, name string) (int, error) {
+	text := r.URL.Query().Get(name)
+	if text == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, text)
+	}
+	return value, nil
+}
+
+// faultDuration describes how long a timed fault lasts
+func faultDuration(fault config.ChaosFault) string {
+	text := "until cleared"
+	if fault.DurationMs > 0 {
+		text = fmt.Sprintf("%dms", fault.DurationMs)
+	}
+	if fault.DelayMs > 0 {
+		text += fmt.Sprintf(", delay %dms", fault.DelayMs)
+	}
+	if fault.LifetimeMs > 0 {
+		text += fmt.Sprintf(", token lifetime %dms", fault.LifetimeMs)
+	}
+	if fault.Quality != "" {
+		text += ", quality " + fault.Quality
+	}
+	return text
+}
+
+// chaosConn is a client connection forwarded to the server
+type chaosConn struct {
+	chaos     *chaos
+	client    net.Conn
+	upstream  net.Conn
+	out       chan pendingMessage
+	writeMu   sync.Mutex // whole messages to the client
+	closeOnce sync.Once
+	plain     bool // the secure channel uses the None security policy (send only)
+
+	mu     sync.Mutex
+	tokens map[ua.NodeID]bool // authentication tokens of the requests
+	expiry *time.Timer        // closes the connection when the security token expires
+}
+
+// pendingMessage is a server message held back until due
+type pendingMessage struct {
+	data []byte
+	due  time.Time
+}
+
+// send forwards the client messages to the server. It restarts the token expiry on every
+// OpenSecureChannel request and collects the session tokens of plain requests.
+func (conn *chaosConn) send() {
+	first := true
+	for {
+		msg, err := readMessage(conn.client)
+		if err != nil {
+			conn.close()
+			return
+		}
+		switch string(msg[:3]) {
+		case "OPN":
+			if policyURI, err := asymmetricPolicyURI(msg); err == nil {
+				conn.plain = policyURI == ua.SecurityPolicyURINone
+			}
+			if lifetime := conn.chaos.tokenLifetime(); lifetime > 0 {
+				conn.expireToken(lifetime)
+			}
+		case "MSG":
+			if first && conn.plain {
+				if token, err := requestSessionToken(msg); err == nil && token != nil {
+					conn.mu.Lock()
+					conn.tokens[token] = true
+					conn.mu.Unlock()
+				}
+			}
+		}
+		first = msg[3] != chunkTypeIntermediate
+		if _, err := conn.upstream.Write(msg); err != nil {
+			conn.close()
+			return
+		}
+	}
+}
+
+// expireToken closes the connection when no new token is requested within lifetime, 0 stops the expiry
+func (conn *chaosConn) expireToken(lifetime time.Duration) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.expiry != nil {
+		conn.expiry.Stop()
+		conn.expiry = nil
+	}
+	if lifetime > 0 {
+		conn.expiry = time.AfterFunc(lifetime, func() {
+			log.Printf("[CHAOS] Security token of %s expired", conn.client.RemoteAddr())
+			conn.close()
+		})
+	}
+}
+
+// takeSessionTokens returns and forgets the session tokens seen on the connection
+func (conn *chaosConn) takeSessionTokens() []ua.NodeID {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	tokens := make([]ua.NodeID, 0, len(conn.tokens))
+	for token := range conn.tokens {
+		tokens = append(tokens, token)
+	}
+	clear(conn.tokens)
+	return tokens
+}
+
+// receive reads the server messages and queues those not discarded by a fault.
+// The fault of a message is taken at its first chunk and applies to all chunks up to the final one,
+// so a multi-chunk response is dropped or delayed as a whole. A dropped response is not replaced,
+// the client sees the request time out and a gap in the sequence numbers of the later messages.
+func (conn *chaosConn) receive() {
+	defer close(conn.out)
+	var drop bool
+	var delay time.Duration
+	first := true
+	for {
+		msg, err := readMessage(conn.upstream)
+		if err != nil {
+			conn.close()
+			return
+		}
+		if lifetime := conn.chaos.tokenLifetime(); lifetime > 0 && string(msg[:3]) == "OPN" {
+			shortenTokenLifetime(msg, uint32(lifetime.Milliseconds()))
+		}
+		if first {
+			drop, delay = conn.chaos.responseFault()
+		}
+		first = msg[3] != chunkTypeIntermediate
+		if drop {
+			continue
+		}
+		conn.out <- pendingMessage{data: msg, due: time.Now().Add(delay)}
+	}
+}
+
+// deliver writes the queued server messages to the client in order, each when it is due
+func (conn *chaosConn) deliver() {
+	for msg := range conn.out {
+		time.Sleep(time.Until(msg.due))
+		conn.writeMu.Lock()
+		_, err := conn.client.Write(msg.data)
+		conn.writeMu.Unlock()
+		if err != nil {
+			conn.close()
+		}
+	}
+}
+
+func (conn *chaosConn) close() {
+	conn.closeOnce.Do(func() {
+		conn.expireToken(0)
+		conn.client.Close()
+		conn.upstream.Close()
+		conn.chaos.mu.Lock()
+		delete(conn.chaos.conns, conn)
+		conn.chaos.mu.Unlock()
+	})
+}
+
+// readMessage reads one OPC UA TCP message chunk (8 byte header with type and size)
+func readMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, messageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[4:])
+	if size < messageHeaderSize || size > maxMessageSize {
+		return nil, fmt.Errorf("invalid message size %d", size)
+	}
+	msg := make([]byte, size)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[messageHeaderSize:]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// asymmetricPolicyURI returns the security policy of an OpenSecureChannel message (never encrypted)
+func asymmetricPolicyURI(msg []byte) (string, error) {
+	if len(msg) < messageHeaderSize+4 {
+		return "", io.ErrUnexpectedEOF
+	}
+	var policyURI string
+	dec := ua.NewBinaryDecoder(bytes.NewReader(msg[messageHeaderSize+4:]), ua.NewEncodingContext())
+	err := dec.ReadString(&policyURI)
+	return policyURI, err
+}
+
+// requestSessionToken returns the authentication token in the request header of a plain message
+func requestSessionToken(msg []byte) (ua.NodeID, error) {
+	// header, SecureChannelId, TokenId, SequenceNumber, RequestId
+	const bodyOffset = messageHeaderSize + 16
+	if len(msg) < bodyOffset {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var typeID, token ua.NodeID
+	dec := ua.NewBinaryDecoder(bytes.NewReader(msg[bodyOffset:]), ua.NewEncodingContext())
+	if err := dec.ReadNodeID(&typeID); err != nil {
+		return nil, err
+	}
+	if err := dec.ReadNodeID(&token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// shortenTokenLifetime lowers the RevisedLifetime of an OpenSecureChannel response with the None
+// security policy to lifetimeMs. Encrypted and other responses are left unchanged.
+func shortenTokenLifetime(msg []byte, lifetimeMs uint32) {
+	if len(msg) < messageHeaderSize+4 {
+		return
+	}
+	r := bytes.NewReader(msg[messageHeaderSize+4:])
+	dec := ua.NewBinaryDecoder(r, ua.NewEncodingContext())
+	var policyURI string
+	var senderCertificate, receiverThumbprint ua.ByteString
+	var sequenceNumber, requestID uint32
+	var typeID ua.NodeID
+	var response ua.OpenSecureChannelResponse
+	if dec.ReadString(&policyURI) != nil || policyURI != ua.SecurityPolicyURINone ||
+		dec.ReadByteString(&senderCertificate) != nil || dec.ReadByteString(&receiverThumbprint) != nil ||
+		dec.ReadUInt32(&sequenceNumber) != nil || dec.ReadUInt32(&requestID) != nil ||
+		dec.ReadNodeID(&typeID) != nil || typeID != ua.ObjectIDOpenSecureChannelResponseEncodingDefaultBinary ||
+		dec.Decode(&response) != nil || response.SecurityToken.RevisedLifetime <= lifetimeMs {
+		return
+	}
+	// RevisedLifetime is the last field before the ServerNonce
+	offset := len(msg) - r.Len() - 4 - len(response.ServerNonce) - 4
+	binary.LittleEndian.PutUint32(msg[offset:], lifetimeMs)
+}
@@ -0,0 +1,254 @@
+package opcuaserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"go-opcua-sim/internal/config"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// chaosTestMessage builds an OPC UA TCP message chunk around a body
+func chaosTestMessage(msgType string, chunkType byte, body []byte) []byte {
+	msg := make([]byte, messageHeaderSize, messageHeaderSize+len(body))
+	copy(msg, msgType)
+	msg[3] = chunkType
+	binary.LittleEndian.PutUint32(msg[4:], uint32(messageHeaderSize+len(body)))
+	return append(msg, body...)
+}
+
+// chaosTestOpenChannel builds an OpenSecureChannel message with the asymmetric header of a policy
+func chaosTestOpenChannel(t *testing.T, policyURI string, typeID ua.NodeID, body any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := ua.NewBinaryEncoder(&buf, ua.NewEncodingContext())
+	enc.WriteUInt32(1) // SecureChannelId
+	enc.WriteString(policyURI)
+	enc.WriteByteString("")
+	enc.WriteByteString("")
+	enc.WriteUInt32(1) // SequenceNumber
+	enc.WriteUInt32(1) // RequestId
+	enc.WriteNodeID(typeID)
+	if err := enc.Encode(body); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return chaosTestMessage("OPN", 'F', buf.Bytes())
+}
+
+// chaosTestRequest builds a service request message with a session token
+func chaosTestRequest(t *testing.T, token ua.NodeID) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := ua.NewBinaryEncoder(&buf, ua.NewEncodingContext())
+	for _, field := range []uint32{1, 1, 2, 2} { // SecureChannelId, TokenId, SequenceNumber, RequestId
+		enc.WriteUInt32(field)
+	}
+	enc.WriteNodeID(ua.ObjectIDReadRequestEncodingDefaultBinary)
+	if err := enc.Encode(&ua.ReadRequest{RequestHeader: ua.RequestHeader{AuthenticationToken: token}}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return chaosTestMessage("MSG", 'F', buf.Bytes())
+}
+
+// chaosTestProxy starts a proxied connection over pipes and returns the client and server ends.
+// Pipe writes return once the proxy has read the data.
+func chaosTestProxy(t *testing.T) (*chaos, net.Conn, net.Conn) {
+	t.Helper()
+	c := &chaos{conns: make(map[*chaosConn]bool), active: make(map[string]*activeFault)}
+	client, proxyClient := net.Pipe()
+	proxyUpstream, upstream := net.Pipe()
+	go c.proxy(proxyClient, proxyUpstream)
+	t.Cleanup(func() {
+		c.stop()
+		client.Close()
+		upstream.Close()
+	})
+	waitFor(t, "proxied connection", func() bool { return len(c.connList()) == 1 })
+	return c, client, upstream
+}
+
+// waitFor waits up to a second for a condition
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// readChunk reads a message chunk from the client end within timeout
+func readChunk(t *testing.T, client net.Conn, timeout time.Duration) []byte {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(timeout))
+	defer client.SetReadDeadline(time.Time{})
+	msg, err := readMessage(client)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func injectFault(t *testing.T, c *chaos, fault config.ChaosFault) {
+	t.Helper()
+	if err := c.Inject(fault); err != nil {
+		t.Fatalf("inject %s: %v", fault.Fault, err)
+	}
+}
+
+func TestChaosDropsWholeMessages(t *testing.T) {
+	c, client, upstream := chaosTestProxy(t)
+
+	// The fault is taken at the first chunk: ending it mid-message still drops the rest
+	injectFault(t, c, config.ChaosFault{Fault: config.FaultDropResponses})
+	upstream.Write(chaosTestMessage("MSG", 'C', []byte("a1")))
+	upstream.Write(chaosTestMessage("MSG", 'C', []byte("a2")))
+	c.Clear()
+	upstream.Write(chaosTestMessage("MSG", 'F', []byte("a3")))
+
+	// A fault started mid-message lets the rest through and drops the next message
+	go upstream.Write(chaosTestMessage("MSG", 'C', []byte("b1")))
+	if got := readChunk(t, client, time.Second); string(got[messageHeaderSize:]) != "b1" {
+		t.Fatalf("received %q, want b1", got[messageHeaderSize:])
+	}
+	injectFault(t, c, config.ChaosFault{Fault: config.FaultDropResponses})
+	go func() {
+		upstream.Write(chaosTestMessage("MSG", 'F', []byte("b2")))
+		upstream.Write(chaosTestMessage("MSG", 'C', []byte("c1")))
+		upstream.Write(chaosTestMessage("MSG", 'F', []byte("c2")))
+		c.Clear()
+		upstream.Write(chaosTestMessage("MSG", 'F', []byte("d1")))
+	}()
+
+	// The client gets no part of a or c, it sees the gap in the sequence numbers
+	for _, want := range []string{"b2", "d1"} {
+		if got := readChunk(t, client, time.Second); string(got[messageHeaderSize:]) != want {
+			t.Fatalf("received %q, want %q", got[messageHeaderSize:], want)
+		}
+	}
+}
+
+func TestChaosDelaysWholeMessages(t *testing.T) {
+	c, client, upstream := chaosTestProxy(t)
+
+	const delay = 200 * time.Millisecond
+	injectFault(t, c, config.ChaosFault{Fault: config.FaultDelayResponses, DelayMs: int(delay.Milliseconds())})
+	start := time.Now()
+	upstream.Write(chaosTestMessage("MSG", 'C', []byte("a1")))
+	upstream.Write(chaosTestMessage("MSG", 'C', []byte("a2")))
+	c.Clear()
+	go upstream.Write(chaosTestMessage("MSG", 'F', []byte("a3")))
+
+	for _, want := range []string{"a1", "a2", "a3"} {
+		got := readChunk(t, client, time.Second)
+		if string(got[messageHeaderSize:]) != want {
+			t.Fatalf("received %q, want %q", got[messageHeaderSize:], want)
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("chunk %s after %v, want at least %v", want, elapsed, delay)
+		}
+	}
+}
+
+func TestChaosSessionTokens(t *testing.T) {
+	c, client, upstream := chaosTestProxy(t)
+	token := ua.NewNodeIDOpaque(0, ua.ByteString("session-1"))
+
+	// Requests pass unchanged, the tokens of plain channels are collected
+	for _, msg := range [][]byte{
+		chaosTestOpenChannel(t, ua.SecurityPolicyURINone, ua.ObjectIDOpenSecureChannelRequestEncodingDefaultBinary, &ua.OpenSecureChannelRequest{}),
+		chaosTestRequest(t, token),
+	} {
+		go client.Write(msg)
+		if got, err := readMessage(upstream); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("forwarded %x (%v), want %x", got, err, msg)
+		}
+	}
+	conn := c.connList()[0]
+	if tokens := conn.takeSessionTokens(); len(tokens) != 1 || tokens[0] != token {
+		t.Errorf("session tokens = %v, want [%v]", tokens, token)
+	}
+
+	// Secure channels are not read
+	go client.Write(chaosTestOpenChannel(t, ua.SecurityPolicyURIBasic256Sha256, ua.ObjectIDOpenSecureChannelRequestEncodingDefaultBinary, &ua.OpenSecureChannelRequest{}))
+	readMessage(upstream)
+	go client.Write(chaosTestRequest(t, token))
+	readMessage(upstream)
+	if tokens := conn.takeSessionTokens(); len(tokens) != 0 {
+		t.Errorf("session tokens of a secure channel = %v, want none", tokens)
+	}
+}
+
+func TestShortenTokenLifetime(t *testing.T) {
+	response := func(lifetime uint32) *ua.OpenSecureChannelResponse {
+		return &ua.OpenSecureChannelResponse{
+			SecurityToken: ua.ChannelSecurityToken{ChannelID: 1, TokenID: 2, CreatedAt: historyTestStart, RevisedLifetime: lifetime},
+			ServerNonce:   ua.ByteString("nonce"),
+		}
+	}
+	tests := []struct {
+		name      string
+		policyURI string
+		typeID    ua.NodeID
+		lifetime  uint32
+		want      uint32
+	}{
+		{"None", ua.SecurityPolicyURINone, ua.ObjectIDOpenSecureChannelResponseEncodingDefaultBinary, 3600000, 2000},
+		{"already shorter", ua.SecurityPolicyURINone, ua.ObjectIDOpenSecureChannelResponseEncodingDefaultBinary, 1000, 1000},
+		{"secure policy", ua.SecurityPolicyURIBasic256Sha256, ua.ObjectIDOpenSecureChannelResponseEncodingDefaultBinary, 3600000, 3600000},
+		{"other response", ua.SecurityPolicyURINone, ua.ObjectIDReadResponseEncodingDefaultBinary, 3600000, 3600000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := chaosTestOpenChannel(t, tt.policyURI, tt.typeID, response(tt.lifetime))
+			shortenTokenLifetime(msg, 2000)
+
+			// Decode past the asymmetric and sequence headers
+			dec := ua.NewBinaryDecoder(bytes.NewReader(msg[messageHeaderSize+4:]), ua.NewEncodingContext())
+			var policyURI string
+			var certificate, thumbprint ua.ByteString
+			var sequenceNumber, requestID uint32
+			var typeID ua.NodeID
+			var got ua.OpenSecureChannelResponse
+			dec.ReadString(&policyURI)
+			dec.ReadByteString(&certificate)
+			dec.ReadByteString(&thumbprint)
+			dec.ReadUInt32(&sequenceNumber)
+			dec.ReadUInt32(&requestID)
+			dec.ReadNodeID(&typeID)
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.SecurityToken.RevisedLifetime != tt.want || got.ServerNonce != "nonce" || got.SecurityToken.TokenID != 2 {
+				t.Errorf("token = %+v, nonce %q, want lifetime %d", got.SecurityToken, got.ServerNonce, tt.want)
+			}
+		})
+	}
+}
+
+func TestChaosTokenExpiry(t *testing.T) {
+	c, client, upstream := chaosTestProxy(t)
+	go io.Copy(io.Discard, upstream)
+	renew := chaosTestOpenChannel(t, ua.SecurityPolicyURINone, ua.ObjectIDOpenSecureChannelRequestEncodingDefaultBinary, &ua.OpenSecureChannelRequest{})
+
+	const lifetime = 300 * time.Millisecond
+	injectFault(t, c, config.ChaosFault{Fault: config.FaultExpireChannels, LifetimeMs: int(lifetime.Milliseconds())})
+
+	// A renewal before the token expires starts a new lifetime on the same connection
+	time.Sleep(lifetime / 2)
+	client.Write(renew)
+	time.Sleep(lifetime * 3 / 4)
+	if len(c.connList()) != 1 {
+		t.Fatal("connection closed although the token was renewed")
+	}
+
+	// Without a renewal the channel is closed
+	waitFor(t, "expired channel", func() bool { return len(c.connList()) == 0 })
+	if _, err := readMessage(client); err == nil {
+		t.Error("client connection still open")
+	}
+}
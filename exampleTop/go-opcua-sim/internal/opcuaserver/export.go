@@ -0,0 +1,592 @@
+package opcuaserver
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+const (
+	nodeSetXMLNamespace = "http://opcfoundation.org/UA/2011/03/UANodeSet.xsd"
+	typesXMLNamespace   = "http://opcfoundation.org/UA/2008/02/Types.xsd"
+)
+
+// nodeSetDocument is the UANodeSet element of a NodeSet2 XML file
+type nodeSetDocument struct {
+	XMLName       xml.Name       `xml:"UANodeSet"`
+	Xmlns         string         `xml:"xmlns,attr"`
+	LastModified  string         `xml:"LastModified,attr"`
+	NamespaceUris []string       `xml:"NamespaceUris>Uri,omitempty"`
+	Aliases       []nodeSetAlias `xml:"Aliases>Alias,omitempty"`
+	Nodes         []nodeSetNode
+}
+
+type nodeSetAlias struct {
+	Alias  string `xml:"Alias,attr"`
+	NodeID string `xml:",chardata"`
+}
+
+// nodeSetNode is a UAObject, UAVariable, UAMethod, ... element, attributes not used by its node class are omitted
+type nodeSetNode struct {
+	XMLName                 xml.Name
+	NodeID                  string `xml:"NodeId,attr"`
+	BrowseName              string `xml:"BrowseName,attr"`
+	DataType                string `xml:"DataType,attr,omitempty"`
+	ValueRank               string `xml:"ValueRank,attr,omitempty"`
+	ArrayDimensions         string `xml:"ArrayDimensions,attr,omitempty"`
+	AccessLevel             string `xml:"AccessLevel,attr,omitempty"`
+	MinimumSamplingInterval string `xml:"MinimumSamplingInterval,attr,omitempty"`
+	Historizing             bool   `xml:"Historizing,attr,omitempty"`
+	EventNotifier           string `xml:"EventNotifier,attr,omitempty"`
+	Executable              string `xml:"Executable,attr,omitempty"`
+	IsAbstract              bool   `xml:"IsAbstract,attr,omitempty"`
+	Symmetric               bool   `xml:"Symmetric,attr,omitempty"`
+	ContainsNoLoops         bool   `xml:"ContainsNoLoops,attr,omitempty"`
+
+	DisplayName     []nodeSetText           `xml:"DisplayName"`
+	Description     []nodeSetText           `xml:"Description,omitempty"`
+	References      []nodeSetReference      `xml:"References>Reference"`
+	RolePermissions *nodeSetRolePermissions `xml:"RolePermissions,omitempty"`
+	InverseName     []nodeSetText           `xml:"InverseName,omitempty"`
+	Definition      *nodeSetDefinition      `xml:"Definition,omitempty"`
+	Value           *nodeSetValue           `xml:"Value,omitempty"`
+}
+
+type nodeSetText struct {
+	Locale string `xml:"Locale,attr,omitempty"`
+	Text   string `xml:",chardata"`
+}
+
+type nodeSetReference struct {
+	ReferenceType string `xml:"ReferenceType,attr"`
+	IsForward     string `xml:"IsForward,attr,omitempty"`
+	Target        string `xml:",chardata"`
+}
+
+type nodeSetRolePermissions struct {
+	List []nodeSetRolePermission `xml:"RolePermission"`
+}
+
+type nodeSetRolePermission struct {
+	Permissions uint32 `xml:"Permissions,attr"`
+	RoleID      string `xml:",chardata"`
+}
+
+type nodeSetDefinition struct {
+	Name   string         `xml:"Name,attr"`
+	Fields []nodeSetField `xml:"Field"`
+}
+
+type nodeSetField struct {
+	Name        string        `xml:"Name,attr"`
+	DataType    string        `xml:"DataType,attr,omitempty"`
+	ValueRank   string        `xml:"ValueRank,attr,omitempty"`
+	Value       string        `xml:"Value,attr,omitempty"`
+	IsOptional  bool          `xml:"IsOptional,attr,omitempty"`
+	Description []nodeSetText `xml:"Description,omitempty"`
+}
+
+// nodeSetValue holds the value of a variable in the UA Types XML encoding
+type nodeSetValue struct {
+	Inner string `xml:",innerxml"`
+}
+
+// ExportNodeSet writes the nodes of the simulator, type and NodeSet namespaces with their attributes,
+// references and current values as NodeSet2 XML. Namespace indexes are the ones of the running server.
+func (s *OPCUAServer) ExportNodeSet(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.server == nil {
+		return fmt.Errorf("server not started")
+	}
+	nm := s.server.NamespaceManager()
+	uris := nm.NamespaceUris()
+	exporter := &nodeSetExporter{nm: nm, uris: uris, aliases: make(map[string]string), localized: s.localizedNodeTexts()}
+
+	doc := nodeSetDocument{
+		Xmlns:         nodeSetXMLNamespace,
+		LastModified:  time.Now().UTC().Format(time.RFC3339),
+		NamespaceUris: uris[1:],
+	}
+	for _, node := range s.exportedNodes() {
+		doc.Nodes = append(doc.Nodes, exporter.node(node))
+	}
+	doc.Aliases = exporter.aliasList()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode NodeSet: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// exportNodeSetFile writes the NodeSet export to the configured file, if any
+func (s *OPCUAServer) exportNodeSetFile() {
+	if s.exportPath == "" {
+		return
+	}
+	f, err := os.Create(s.exportPath)
+	if err != nil {
+		log.Printf("[OPCUA] Failed to export NodeSet: %v", err)
+		return
+	}
+	err = s.ExportNodeSet(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("[OPCUA] Failed to export NodeSet: %v", err)
+		return
+	}
+	log.Printf("[OPCUA] Exported address space to %s", s.exportPath)
+}
+
+// exportedNodes returns the nodes outside namespace 0 reachable from the Root folder, parents first,
+// followed by the alias variables which are only referenced by their type definition (caller holds the lock)
+func (s *OPCUAServer) exportedNodes() []server.Node {
+	nm := s.server.NamespaceManager()
+	uris := nm.NamespaceUris()
+
+	seen := make(map[ua.NodeID]bool)
+	var nodes []server.Node
+	walk := func(queue []ua.NodeID) {
+		for len(queue) > 0 {
+			nodeID := queue[0]
+			queue = queue[1:]
+			if seen[nodeID] {
+				continue
+			}
+			seen[nodeID] = true
+			node, ok := nm.FindNode(nodeID)
+			if !ok {
+				continue
+			}
+			if namespaceIndex(nodeID) != 0 {
+				nodes = append(nodes, node)
+			}
+			for _, ref := range node.References() {
+				if target := ua.ToNodeID(ref.TargetID, uris); !seen[target] {
+					queue = append(queue, target)
+				}
+			}
+		}
+	}
+
+	walk([]ua.NodeID{ua.ObjectIDRootFolder})
+	var aliases []ua.NodeID
+	for _, varNodes := range s.tagNodes {
+		for _, node := range varNodes {
+			aliases = append(aliases, node.NodeID())
+		}
+	}
+	sortNodeIDs(aliases)
+	walk(aliases)
+	return nodes
+}
+
+// nodeSetExporter converts nodes to NodeSet elements and collects the aliases of the namespace 0 types they use
+type nodeSetExporter struct {
+	nm        *server.NamespaceManager
+	uris      []string
+	aliases   map[string]string            // alias -> NodeId
+	localized map[ua.NodeID]localizedTexts // tag node -> texts in all locales
+}
+
+func (e *nodeSetExporter) node(node server.Node) nodeSetNode {
+	n := nodeSetNode{
+		NodeID:      nodeIDString(node.NodeID()),
+		BrowseName:  qualifiedNameString(node.BrowseName()),
+		DisplayName: nodeSetTexts(node.DisplayName()),
+		Description: nodeSetTexts(node.Description()),
+	}
+	if texts, ok := e.localized[node.NodeID()]; ok {
+		if texts.displayName != nil {
+			n.DisplayName = texts.displayName
+		}
+		if texts.description != nil {
+			n.Description = texts.description
+		}
+	}
+	if len(n.DisplayName) == 0 {
+		n.DisplayName = []nodeSetText{{Text: node.BrowseName().Name}}
+	}
+
+	for _, ref := range node.References() {
+		r := nodeSetReference{
+			ReferenceType: e.alias(ref.ReferenceTypeID),
+			Target:        nodeIDString(ua.ToNodeID(ref.TargetID, e.uris)),
+		}
+		if ref.IsInverse {
+			r.IsForward = "false"
+		}
+		n.References = append(n.References, r)
+	}
+	if rps := node.RolePermissions(); len(rps) > 0 {
+		n.RolePermissions = &nodeSetRolePermissions{}
+		for _, rp := range rps {
+			n.RolePermissions.List = append(n.RolePermissions.List, nodeSetRolePermission{
+				Permissions: uint32(rp.Permissions),
+				RoleID:      nodeIDString(rp.RoleID),
+			})
+		}
+	}
+
+	switch t := node.(type) {
+	case *server.ObjectNode:
+		n.XMLName.Local = "UAObject"
+		n.EventNotifier = uintAttribute(uint64(t.EventNotifier()))
+	case *server.VariableNode:
+		n.XMLName.Local = "UAVariable"
+		n.DataType = e.alias(t.DataType())
+		n.ValueRank = valueRankAttribute(t.ValueRank())
+		n.ArrayDimensions = arrayDimensionsAttribute(t.ArrayDimensions())
+		if t.AccessLevel() != ua.AccessLevelsCurrentRead {
+			n.AccessLevel = strconv.Itoa(int(t.AccessLevel()))
+		}
+		if t.MinimumSamplingInterval() != 0 {
+			n.MinimumSamplingInterval = strconv.FormatFloat(t.MinimumSamplingInterval(), 'f', -1, 64)
+		}
+		n.Historizing = t.Historizing()
+		n.Value = e.value(t.Value().Value)
+	case *server.MethodNode:
+		n.XMLName.Local = "UAMethod"
+		if !t.Executable() {
+			n.Executable = "false"
+		}
+	case *server.ObjectTypeNode:
+		n.XMLName.Local = "UAObjectType"
+		n.IsAbstract = t.IsAbstract()
+	case *server.VariableTypeNode:
+		n.XMLName.Local = "UAVariableType"
+		n.IsAbstract = t.IsAbstract()
+		n.DataType = e.alias(t.DataType())
+		n.ValueRank = valueRankAttribute(t.ValueRank())
+		n.ArrayDimensions = arrayDimensionsAttribute(t.ArrayDimensions())
+		n.Value = e.value(t.Value().Value)
+	case *server.DataTypeNode:
+		n.XMLName.Local = "UADataType"
+		n.IsAbstract = t.IsAbstract()
+		n.Definition = e.definition(node.BrowseName(), t.DataTypeDefinition())
+	case *server.ReferenceTypeNode:
+		n.XMLName.Local = "UAReferenceType"
+		n.IsAbstract = t.IsAbstract()
+		n.Symmetric = t.Symmetric()
+		n.InverseName = nodeSetTexts(t.InverseName())
+	case *server.ViewNode:
+		n.XMLName.Local = "UAView"
+		n.ContainsNoLoops = t.ContainsNoLoops()
+		n.EventNotifier = uintAttribute(uint64(t.EventNotifier()))
+	}
+	return n
+}
+
+// definition converts the structure or enumeration definition of a DataType
+func (e *nodeSetExporter) definition(name ua.QualifiedName, definition any) *nodeSetDefinition {
+	def := &nodeSetDefinition{Name: qualifiedNameString(name)}
+	switch d := definition.(type) {
+	case ua.StructureDefinition:
+		for _, field := range d.Fields {
+			def.Fields = append(def.Fields, nodeSetField{
+				Name:        field.Name,
+				DataType:    e.alias(field.DataType),
+				ValueRank:   valueRankAttribute(field.ValueRank),
+				IsOptional:  field.IsOptional,
+				Description: nodeSetTexts(field.Description),
+			})
+		}
+	case ua.EnumDefinition:
+		for _, field := range d.Fields {
+			def.Fields = append(def.Fields, nodeSetField{
+				Name:        field.Name,
+				Value:       strconv.FormatInt(field.Value, 10),
+				Description: nodeSetTexts(field.Description),
+			})
+		}
+	default:
+		return nil
+	}
+	return def
+}
+
+// nodeIDString formats a NodeId with the namespace index of the server, which is also the NodeSet index
+func nodeIDString(nodeID ua.NodeID) string {
+	if nodeID == nil {
+		return ""
+	}
+	return fmt.Sprint(nodeID)
+}
+
+// alias returns the alias of a namespace 0 DataType or ReferenceType (its BrowseName), other NodeIds as they are
+func (e *nodeSetExporter) alias(nodeID ua.NodeID) string {
+	text := nodeIDString(nodeID)
+	if namespaceIndex(nodeID) != 0 {
+		return text
+	}
+	node, ok := e.nm.FindNode(nodeID)
+	if !ok {
+		return text
+	}
+	name := node.BrowseName().Name
+	if existing, ok := e.aliases[name]; ok && existing != text {
+		return text
+	}
+	e.aliases[name] = text
+	return name
+}
+
+func (e *nodeSetExporter) aliasList() []nodeSetAlias {
+	names := make([]string, 0, len(e.aliases))
+	for name := range e.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	aliases := make([]nodeSetAlias, len(names))
+	for i, name := range names {
+		aliases[i] = nodeSetAlias{Alias: name, NodeID: e.aliases[name]}
+	}
+	return aliases
+}
+
+// value encodes a variable value, values without an XML encoding (custom structures) are omitted
+func (e *nodeSetExporter) value(v ua.Variant) *nodeSetValue {
+	if v == nil {
+		return nil
+	}
+	b := &strings.Builder{}
+	if !e.writeVariant(b, v) {
+		return nil
+	}
+	return &nodeSetValue{Inner: b.String()}
+}
+
+// writeVariant writes a scalar as <Type> or an array as <ListOfType> element of the UA Types XML schema
+func (e *nodeSetExporter) writeVariant(b *strings.Builder, v ua.Variant) bool {
+	xmlns := fmt.Sprintf(` xmlns="%s"`, typesXMLNamespace)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		name, ok := variantElementName(v)
+		return ok && e.writeElement(b, name, xmlns, v)
+	}
+
+	var name string
+	var ok bool
+	switch elemType := rv.Type().Elem(); {
+	case rv.Len() > 0:
+		name, ok = variantElementName(rv.Index(0).Interface())
+	case elemType == reflect.TypeOf((*ua.NodeID)(nil)).Elem():
+		name, ok = "NodeId", true
+	case elemType.Kind() == reflect.Interface:
+		name, ok = "ExtensionObject", true
+	default:
+		name, ok = variantElementName(reflect.Zero(elemType).Interface())
+	}
+	if !ok {
+		return false
+	}
+	list := &strings.Builder{}
+	for i := 0; i < rv.Len(); i++ {
+		if !e.writeElement(list, name, "", rv.Index(i).Interface()) {
+			return false
+		}
+	}
+	fmt.Fprintf(b, "<ListOf%s%s>%s</ListOf%s>", name, xmlns, list.String(), name)
+	return true
+}
+
+// variantElementName is the UA Types XML element name of a scalar value
+func variantElementName(v any) (string, bool) {
+	switch v.(type) {
+	case bool:
+		return "Boolean", true
+	case int8:
+		return "SByte", true
+	case uint8:
+		return "Byte", true
+	case int16:
+		return "Int16", true
+	case uint16:
+		return "UInt16", true
+	case int32:
+		return "Int32", true
+	case uint32:
+		return "UInt32", true
+	case int64:
+		return "Int64", true
+	case uint64:
+		return "UInt64", true
+	case float32:
+		return "Float", true
+	case float64:
+		return "Double", true
+	case string:
+		return "String", true
+	case time.Time:
+		return "DateTime", true
+	case ua.ByteString:
+		return "ByteString", true
+	case ua.LocalizedText:
+		return "LocalizedText", true
+	case ua.QualifiedName:
+		return "QualifiedName", true
+	case ua.NodeIDNumeric, ua.NodeIDString, ua.NodeIDGUID, ua.NodeIDOpaque:
+		return "NodeId", true
+	case ua.Argument, ua.Range, ua.EUInformation, ua.EnumValueType:
+		return "ExtensionObject", true
+	default:
+		return "", false
+	}
+}
+
+// writeElement writes one scalar value as element with the given attributes
+func (e *nodeSetExporter) writeElement(b *strings.Builder, name, attrs string, v any) bool {
+	inner := &strings.Builder{}
+	switch val := v.(type) {
+	case bool:
+		inner.WriteString(strconv.FormatBool(val))
+	case float32:
+		inner.WriteString(strconv.FormatFloat(float64(val), 'g', -1, 32))
+	case float64:
+		inner.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+	case string:
+		xml.EscapeText(inner, []byte(val))
+	case time.Time:
+		inner.WriteString(val.UTC().Format(time.RFC3339Nano))
+	case ua.ByteString:
+		inner.WriteString(base64.StdEncoding.EncodeToString([]byte(val)))
+	case ua.LocalizedText:
+		writeTextElement(inner, "Locale", val.Locale)
+		writeTextElement(inner, "Text", val.Text)
+	case ua.QualifiedName:
+		writeTextElement(inner, "NamespaceIndex", strconv.Itoa(int(val.NamespaceIndex)))
+		writeTextElement(inner, "Name", val.Name)
+	case ua.NodeID:
+		writeTextElement(inner, "Identifier", nodeIDString(val))
+	case ua.Argument:
+		e.writeExtensionObject(inner, ua.ObjectIDArgumentEncodingDefaultXML, "Argument", func(body *strings.Builder) {
+			writeTextElement(body, "Name", val.Name)
+			body.WriteString("<DataType>")
+			writeTextElement(body, "Identifier", nodeIDString(val.DataType))
+			body.WriteString("</DataType>")
+			writeTextElement(body, "ValueRank", strconv.Itoa(int(val.ValueRank)))
+			writeTextElement(body, "ArrayDimensions", arrayDimensionsAttribute(val.ArrayDimensions))
+			body.WriteString("<Description>")
+			writeTextElement(body, "Locale", val.Description.Locale)
+			writeTextElement(body, "Text", val.Description.Text)
+			body.WriteString("</Description>")
+		})
+	case ua.Range:
+		e.writeExtensionObject(inner, ua.ObjectIDRangeEncodingDefaultXML, "Range", func(body *strings.Builder) {
+			writeTextElement(body, "Low", strconv.FormatFloat(val.Low, 'g', -1, 64))
+			writeTextElement(body, "High", strconv.FormatFloat(val.High, 'g', -1, 64))
+		})
+	case ua.EUInformation:
+		e.writeExtensionObject(inner, ua.ObjectIDEUInformationEncodingDefaultXML, "EUInformation", func(body *strings.Builder) {
+			writeTextElement(body, "NamespaceUri", val.NamespaceURI)
+			writeTextElement(body, "UnitId", strconv.Itoa(int(val.UnitID)))
+			body.WriteString("<DisplayName>")
+			writeTextElement(body, "Locale", val.DisplayName.Locale)
+			writeTextElement(body, "Text", val.DisplayName.Text)
+			body.WriteString("</DisplayName><Description>")
+			writeTextElement(body, "Locale", val.Description.Locale)
+			writeTextElement(body, "Text", val.Description.Text)
+			body.WriteString("</Description>")
+		})
+	case ua.EnumValueType:
+		e.writeExtensionObject(inner, ua.ObjectIDEnumValueTypeEncodingDefaultXML, "EnumValueType", func(body *strings.Builder) {
+			writeTextElement(body, "Value", strconv.FormatInt(val.Value, 10))
+			body.WriteString("<DisplayName>")
+			writeTextElement(body, "Locale", val.DisplayName.Locale)
+			writeTextElement(body, "Text", val.DisplayName.Text)
+			body.WriteString("</DisplayName><Description>")
+			writeTextElement(body, "Locale", val.Description.Locale)
+			writeTextElement(body, "Text", val.Description.Text)
+			body.WriteString("</Description>")
+		})
+	default:
+		if elemName, ok := variantElementName(v); !ok || elemName != name {
+			return false
+		}
+		fmt.Fprint(inner, v) // integers
+	}
+
+	fmt.Fprintf(b, "<%s%s>%s</%s>", name, attrs, inner.String(), name)
+	return true
+}
+
+// writeExtensionObject writes the TypeId and Body of a structure with an XML encoding
+func (e *nodeSetExporter) writeExtensionObject(b *strings.Builder, typeID ua.NodeID, name string, body func(*strings.Builder)) {
+	b.WriteString("<TypeId>")
+	writeTextElement(b, "Identifier", nodeIDString(typeID))
+	fmt.Fprintf(b, "</TypeId><Body><%s>", name)
+	body(b)
+	fmt.Fprintf(b, "</%s></Body>", name)
+}
+
+func writeTextElement(b *strings.Builder, name, text string) {
+	fmt.Fprintf(b, "<%s>", name)
+	xml.EscapeText(b, []byte(text))
+	fmt.Fprintf(b, "</%s>", name)
+}
+
+// nodeSetTexts returns a LocalizedText as element list, empty texts are omitted
+func nodeSetTexts(text ua.LocalizedText) []nodeSetText {
+	if text.Text == "" {
+		return nil
+	}
+	return []nodeSetText{{Locale: text.Locale, Text: text.Text}}
+}
+
+// qualifiedNameString formats a BrowseName as "<index>:<name>", without prefix in namespace 0
+func qualifiedNameString(name ua.QualifiedName) string {
+	if name.NamespaceIndex == 0 {
+		return name.Name
+	}
+	return fmt.Sprintf("%d:%s", name.NamespaceIndex, name.Name)
+}
+
+// valueRankAttribute omits the default ValueRank (scalar)
+func valueRankAttribute(valueRank int32) string {
+	if valueRank == ua.ValueRankScalar {
+		return ""
+	}
+	return strconv.Itoa(int(valueRank))
+}
+
+func arrayDimensionsAttribute(dims []uint32) string {
+	parts := make([]string, 0, len(dims))
+	for _, dim := range dims {
+		parts = append(parts, strconv.FormatUint(uint64(dim), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// uintAttribute omits zero attribute values
+func uintAttribute(value uint64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// sortNodeIDs orders NodeIds by their text form so that the export is stable
+func sortNodeIDs(ids []ua.NodeID) {
+	sort.Slice(ids, func(i, j int) bool { return fmt.Sprint(ids[i]) < fmt.Sprint(ids[j]) })
+}
@@ -0,0 +1,133 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/plc"
+	"sort"
+	"strings"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// objectsFolderID is the standard Objects folder (namespace 0, id 85)
+var objectsFolderID = ua.ParseNodeID("i=85")
+
+// container is a folder or device object created from tag browse paths
+type container struct {
+	path     string // full browse path, also used as string node ID
+	name     string // last path segment
+	parent   string // parent container path, empty = Objects folder
+	isDevice bool   // directly holds variables -> BaseObjectType, otherwise FolderType
+}
+
+// addressSpace holds the folder/object hierarchy built from tag browse paths
+type addressSpace struct {
+	containers map[string]*container
+	namespace  func(path string) uint16 // namespace index of a container path
+}
+
+// newAddressSpace collects the containers needed by the browse paths of the given tags
+func newAddressSpace(tags []*plc.Tag, namespace func(path string) uint16) *addressSpace {
+	as := &addressSpace{containers: make(map[string]*container), namespace: namespace}
+
+	for _, tag := range tags {
+		if tag.BrowsePath == "" {
+			continue
+		}
+		segments := strings.Split(tag.BrowsePath, "/")
+		for i := 1; i < len(segments); i++ {
+			path := strings.Join(segments[:i], "/")
+			if _, exists := as.containers[path]; !exists {
+				as.containers[path] = &container{
+					path:   path,
+					name:   segments[i-1],
+					parent: strings.Join(segments[:i-1], "/"),
+				}
+			}
+		}
+		if len(segments) > 1 {
+			as.containers[strings.Join(segments[:len(segments)-1], "/")].isDevice = true
+		}
+	}
+
+	return as
+}
+
+// containerNodeID returns the node ID of a container path (empty = Objects folder)
+func (as *addressSpace) containerNodeID(path string) ua.NodeID {
+	if path == "" {
+		return objectsFolderID
+	}
+	return ua.NodeIDString{NamespaceIndex: as.namespace(path), ID: path}
+}
+
+// childReferenceType returns the reference type from a container to its children:
+// folders organize, device objects have components
+func (as *addressSpace) childReferenceType(path string) ua.NodeID {
+	if c, ok := as.containers[path]; ok && c.isDevice {
+		return ua.ReferenceTypeIDHasComponent
+	}
+	return ua.ReferenceTypeIDOrganizes
+}
+
+// nodes creates the folder and object nodes, parents before children
+func (as *addressSpace) nodes(srv *server.Server) []server.Node {
+	paths := make([]string, 0, len(as.containers))
+	for path := range as.containers {
+		paths = append(paths, path)
+	}
+	return as.containerNodes(srv, paths)
+}
+
+// containerNodes creates the folder and object nodes of the given container paths, parents before children
+func (as *addressSpace) containerNodes(srv *server.Server, paths []string) []server.Node {
+	sort.Strings(paths)
+
+	nodes := make([]server.Node, 0, len(paths))
+	for _, path := range paths {
+		c := as.containers[path]
+
+		typeDefinition := ua.ObjectTypeIDFolderType
+		if c.isDevice {
+			typeDefinition = ua.ObjectTypeIDBaseObjectType
+		}
+
+		nodes = append(nodes, server.NewObjectNode(
+			srv,
+			as.containerNodeID(c.path),
+			ua.QualifiedName{NamespaceIndex: as.namespace(c.path), Name: c.name},
+			ua.LocalizedText{Text: c.name},
+			ua.LocalizedText{},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: typeDefinition},
+				},
+				{
+					ReferenceTypeID: as.childReferenceType(c.parent),
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: as.containerNodeID(c.parent)},
+				},
+			},
+			0,
+		))
+	}
+
+	return nodes
+}
+
+// tagPlacement returns the browse name, parent node ID and parent reference type of a tag node
+func (as *addressSpace) tagPlacement(tag *plc.Tag) (string, ua.NodeID, ua.NodeID) {
+	if tag.BrowsePath == "" {
+		return tag.Name, objectsFolderID, ua.ReferenceTypeIDOrganizes
+	}
+
+	idx := strings.LastIndex(tag.BrowsePath, "/")
+	if idx < 0 {
+		return tag.BrowsePath, objectsFolderID, ua.ReferenceTypeIDOrganizes
+	}
+
+	parent := tag.BrowsePath[:idx]
+	return tag.BrowsePath[idx+1:], as.containerNodeID(parent), as.childReferenceType(parent)
+}
@@ -0,0 +1,367 @@
+package opcuaserver
+
+import (
+	"context"
+	"crypto/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+const (
+	maxHistoryValuesPerRead = 1000            // values per node and call before a continuation point is returned
+	maxContinuationPoints   = 100             // open continuation points
+	continuationPointTTL    = 5 * time.Minute // unused continuation points are dropped after this time
+)
+
+// opcuaEpoch is the minimum OPC UA DateTime, used by clients for "not specified"
+var opcuaEpoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// historyBuffer keeps the latest values of a node in a fixed-size ring, ordered by arrival
+type historyBuffer struct {
+	values []ua.DataValue
+	next   int
+	full   bool
+}
+
+// add appends a value, overwriting the oldest one when the buffer is full
+func (b *historyBuffer) add(value ua.DataValue, depth int) {
+	if !b.full && len(b.values) < depth {
+		b.values = append(b.values, value)
+		if len(b.values) == depth {
+			b.full = true
+		}
+		return
+	}
+	b.values[b.next] = value
+	b.next = (b.next + 1) % depth
+}
+
+// last returns the most recent value
+func (b *historyBuffer) last() (ua.DataValue, bool) {
+	if len(b.values) == 0 {
+		return ua.DataValue{}, false
+	}
+	if !b.full {
+		return b.values[len(b.values)-1], true
+	}
+	return b.values[(b.next+len(b.values)-1)%len(b.values)], true
+}
+
+// snapshot returns a copy of the values, oldest first
+func (b *historyBuffer) snapshot() []ua.DataValue {
+	values := make([]ua.DataValue, 0, len(b.values))
+	if b.full {
+		values = append(values, b.values[b.next:]...)
+		values = append(values, b.values[:b.next]...)
+	} else {
+		values = append(values, b.values...)
+	}
+	return values
+}
+
+// historyCursor holds the values still to be returned for a continuation point.
+// The library calls the historian without the session of the request, so a cursor cannot be bound to
+// its session; it is bound to the node it was created for and identified by a random 128-bit point.
+type historyCursor struct {
+	nodeID  ua.NodeID
+	values  []ua.DataValue
+	limit   int
+	created time.Time
+}
+
+// historian records the value changes of historizing nodes in ring buffers and serves HistoryRead.
+// It implements server.HistoryReadWriter.
+type historian struct {
+	depth   int
+	mu      sync.RWMutex
+	buffers map[ua.NodeID]*historyBuffer
+	cursors map[ua.ByteString]*historyCursor
+}
+
+// newHistorian creates a historian keeping depth values per node
+func newHistorian(depth int) *historian {
+	return &historian{
+		depth:   depth,
+		buffers: make(map[ua.NodeID]*historyBuffer),
+		cursors: make(map[ua.ByteString]*historyCursor),
+	}
+}
+
+// register starts recording a node
+func (h *historian) register(nodeID ua.NodeID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.buffers[nodeID]; !ok {
+		h.buffers[nodeID] = &historyBuffer{}
+	}
+}
+
+// unregister stops recording a node and drops its history
+func (h *historian) unregister(nodeID ua.NodeID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.buffers, nodeID)
+}
+
+// WriteValue records a node value if it differs from the last recorded value
+func (h *historian) WriteValue(ctx context.Context, nodeID ua.NodeID, value ua.DataValue) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buffer, ok := h.buffers[nodeID]
+	if !ok {
+		return ua.BadHistoryOperationUnsupported
+	}
+	if last, ok := buffer.last(); ok && last.StatusCode == value.StatusCode && reflect.DeepEqual(last.Value, value.Value) {
+		return nil
+	}
+	buffer.add(value, h.depth)
+	return nil
+}
+
+// WriteEvent is not supported, events are not historized
+func (h *historian) WriteEvent(ctx context.Context, nodeID ua.NodeID, eventFields []ua.Variant) error {
+	return ua.BadHistoryOperationUnsupported
+}
+
+// ReadRawModified returns the recorded values of each node within the requested time range
+func (h *historian) ReadRawModified(ctx context.Context, nodesToRead []ua.HistoryReadValueID, details ua.ReadRawModifiedDetails,
+	timestampsToReturn ua.TimestampsToReturn, releaseContinuationPoints bool) ([]ua.HistoryReadResult, ua.StatusCode) {
+	if timestampsToReturn < ua.TimestampsToReturnSource || timestampsToReturn >= ua.TimestampsToReturnNeither {
+		return nil, ua.BadTimestampsToReturnInvalid
+	}
+
+	results := make([]ua.HistoryReadResult, len(nodesToRead))
+	for i, node := range nodesToRead {
+		results[i] = h.readRaw(node, details, timestampsToReturn, releaseContinuationPoints)
+	}
+	return results, ua.Good
+}
+
+// readRaw reads the raw history of one node
+func (h *historian) readRaw(node ua.HistoryReadValueID, details ua.ReadRawModifiedDetails,
+	timestampsToReturn ua.TimestampsToReturn, release bool) ua.HistoryReadResult {
+	// Continue or release a previous read
+	if node.ContinuationPoint != "" {
+		cursor, ok := h.takeCursor(node.ContinuationPoint, node.NodeID)
+		if !ok {
+			return ua.HistoryReadResult{StatusCode: ua.BadContinuationPointInvalid}
+		}
+		if release {
+			return ua.HistoryReadResult{StatusCode: ua.Good}
+		}
+		return h.page(cursor.nodeID, cursor.values, cursor.limit, timestampsToReturn)
+	}
+	if release {
+		return ua.HistoryReadResult{StatusCode: ua.Good}
+	}
+
+	if details.IsReadModified {
+		// Values are never modified, so there is no modified history
+		return ua.HistoryReadResult{StatusCode: ua.BadHistoryOperationUnsupported}
+	}
+	if node.IndexRange != "" {
+		return ua.HistoryReadResult{StatusCode: ua.BadIndexRangeInvalid}
+	}
+
+	// Two of StartTime, EndTime and NumValuesPerNode must be given
+	details.StartTime, details.EndTime = specifiedTime(details.StartTime), specifiedTime(details.EndTime)
+	specified := 0
+	if !details.StartTime.IsZero() {
+		specified++
+	}
+	if !details.EndTime.IsZero() {
+		specified++
+	}
+	if details.NumValuesPerNode > 0 {
+		specified++
+	}
+	if specified < 2 {
+		return ua.HistoryReadResult{StatusCode: ua.BadInvalidTimestampArgument}
+	}
+
+	h.mu.RLock()
+	buffer, ok := h.buffers[node.NodeID]
+	var values []ua.DataValue
+	if ok {
+		values = buffer.snapshot()
+	}
+	h.mu.RUnlock()
+	if !ok {
+		return ua.HistoryReadResult{StatusCode: ua.BadHistoryOperationUnsupported}
+	}
+
+	return h.page(node.NodeID, selectRaw(values, details), int(details.NumValuesPerNode), timestampsToReturn)
+}
+
+// page returns the first values of a read of a node and a continuation point for the rest
+func (h *historian) page(nodeID ua.NodeID, values []ua.DataValue, limit int, timestampsToReturn ua.TimestampsToReturn) ua.HistoryReadResult {
+	if len(values) == 0 {
+		return ua.HistoryReadResult{StatusCode: ua.GoodNoData, HistoryData: ua.HistoryData{DataValues: []ua.DataValue{}}}
+	}
+
+	n := limit
+	if n <= 0 || n > maxHistoryValuesPerRead {
+		n = maxHistoryValuesPerRead
+	}
+
+	result := ua.HistoryReadResult{StatusCode: ua.Good}
+	if len(values) > n {
+		cp, status := h.newCursor(nodeID, values[n:], limit)
+		if status != ua.Good {
+			return ua.HistoryReadResult{StatusCode: status}
+		}
+		result.ContinuationPoint = cp
+		values = values[:n]
+	}
+
+	dataValues := make([]ua.DataValue, len(values))
+	for i, value := range values {
+		dataValues[i] = filterTimestamps(value, timestampsToReturn)
+	}
+	result.HistoryData = ua.HistoryData{DataValues: dataValues}
+	return result
+}
+
+// newCursor stores the remaining values of a read of a node and returns its continuation point
+func (h *historian) newCursor(nodeID ua.NodeID, values []ua.DataValue, limit int) (ua.ByteString, ua.StatusCode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for cp, cursor := range h.cursors {
+		if now.Sub(cursor.created) > continuationPointTTL {
+			delete(h.cursors, cp)
+		}
+	}
+	if len(h.cursors) >= maxContinuationPoints {
+		return "", ua.BadNoContinuationPoints
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", ua.BadInternalError
+	}
+	cp := ua.ByteString(id)
+	h.cursors[cp] = &historyCursor{nodeID: nodeID, values: values, limit: limit, created: now}
+	return cp, ua.Good
+}
+
+// takeCursor removes and returns the cursor of a continuation point. A point passed with another node
+// is invalid and left for the read it belongs to.
+func (h *historian) takeCursor(cp ua.ByteString, nodeID ua.NodeID) (*historyCursor, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cursor, ok := h.cursors[cp]
+	if !ok || cursor.nodeID != nodeID {
+		return nil, false
+	}
+	delete(h.cursors, cp)
+	return cursor, true
+}
+
+// ReadEvent is not supported, events are not historized
+func (h *historian) ReadEvent(ctx context.Context, nodesToRead []ua.HistoryReadValueID, details ua.ReadEventDetails,
+	timestampsToReturn ua.TimestampsToReturn, releaseContinuationPoints bool) ([]ua.HistoryReadResult, ua.StatusCode) {
+	return unsupportedHistoryResults(nodesToRead), ua.Good
+}
+
+// ReadAtTime is not supported
+func (h *historian) ReadAtTime(ctx context.Context, nodesToRead []ua.HistoryReadValueID, details ua.ReadAtTimeDetails,
+	timestampsToReturn ua.TimestampsToReturn, releaseContinuationPoints bool) ([]ua.HistoryReadResult, ua.StatusCode) {
+	return unsupportedHistoryResults(nodesToRead), ua.Good
+}
+
+// unsupportedHistoryResults returns BadHistoryOperationUnsupported for every node
+func unsupportedHistoryResults(nodesToRead []ua.HistoryReadValueID) []ua.HistoryReadResult {
+	results := make([]ua.HistoryReadResult, len(nodesToRead))
+	for i := range results {
+		results[i].StatusCode = ua.BadHistoryOperationUnsupported
+	}
+	return results
+}
+
+// selectRaw selects the values of a raw read from values ordered by source timestamp.
+// The first time in read direction is included, the last one excluded; StartTime after
+// EndTime or a missing StartTime reads backwards.
+func selectRaw(values []ua.DataValue, details ua.ReadRawModifiedDetails) []ua.DataValue {
+	start, end := details.StartTime, details.EndTime
+	// first index with a timestamp after (or at, when inclusive) t
+	after := func(t time.Time, inclusive bool) int {
+		return sort.Search(len(values), func(i int) bool {
+			ts := values[i].SourceTimestamp
+			return ts.After(t) || (inclusive && ts.Equal(t))
+		})
+	}
+
+	var selected []ua.DataValue
+	if !start.IsZero() && (end.IsZero() || !end.Before(start)) {
+		// Forward: [start, end)
+		i0 := after(start, true)
+		i1 := len(values)
+		if !end.IsZero() {
+			i1 = after(end, true)
+		}
+		if details.ReturnBounds && !(i0 < len(values) && values[i0].SourceTimestamp.Equal(start)) {
+			selected = append(selected, boundValue(values, i0-1, start))
+		}
+		selected = append(selected, values[i0:i1]...)
+		if details.ReturnBounds && !end.IsZero() {
+			selected = append(selected, boundValue(values, i1, end))
+		}
+		return selected
+	}
+
+	// Backward: (other, anchor]
+	anchor, other := start, end
+	if start.IsZero() {
+		anchor, other = end, time.Time{}
+	}
+	j1 := after(anchor, false)
+	j0 := 0
+	if !other.IsZero() {
+		j0 = after(other, false)
+	}
+	if details.ReturnBounds && !(j1 > 0 && values[j1-1].SourceTimestamp.Equal(anchor)) {
+		selected = append(selected, boundValue(values, j1, anchor))
+	}
+	for i := j1 - 1; i >= j0; i-- {
+		selected = append(selected, values[i])
+	}
+	if details.ReturnBounds && !other.IsZero() {
+		selected = append(selected, boundValue(values, j0-1, other))
+	}
+	return selected
+}
+
+// boundValue returns the value at index i as a bounding value, or BadBoundNotFound at time t
+func boundValue(values []ua.DataValue, i int, t time.Time) ua.DataValue {
+	if i < 0 || i >= len(values) {
+		return ua.DataValue{StatusCode: ua.BadBoundNotFound, SourceTimestamp: t, ServerTimestamp: t}
+	}
+	return values[i]
+}
+
+// specifiedTime returns the zero time for an unspecified DateTime (decoded as 1601-01-01)
+func specifiedTime(t time.Time) time.Time {
+	if !t.After(opcuaEpoch) {
+		return time.Time{}
+	}
+	return t
+}
+
+// filterTimestamps clears the timestamps the client did not ask for
+func filterTimestamps(value ua.DataValue, timestampsToReturn ua.TimestampsToReturn) ua.DataValue {
+	switch timestampsToReturn {
+	case ua.TimestampsToReturnSource:
+		value.ServerTimestamp, value.ServerPicoseconds = time.Time{}, 0
+	case ua.TimestampsToReturnServer:
+		value.SourceTimestamp, value.SourcePicoseconds = time.Time{}, 0
+	}
+	return value
+}
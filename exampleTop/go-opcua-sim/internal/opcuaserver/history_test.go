@@ -0,0 +1,172 @@
+package opcuaserver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+var historyTestStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// historyTestTime returns the source timestamp of the i-th test value
+func historyTestTime(i int) time.Time {
+	return historyTestStart.Add(time.Duration(i) * time.Second)
+}
+
+// historyTestValues returns n values 0..n-1, one per second
+func historyTestValues(n int) []ua.DataValue {
+	values := make([]ua.DataValue, n)
+	for i := range values {
+		values[i] = ua.NewDataValue(float64(i), ua.Good, historyTestTime(i), 0, historyTestTime(i), 0)
+	}
+	return values
+}
+
+// historyValueList returns the values of a read, -1 for a BadBoundNotFound bound
+func historyValueList(values []ua.DataValue) []float64 {
+	list := make([]float64, len(values))
+	for i, value := range values {
+		if value.StatusCode == ua.BadBoundNotFound {
+			list[i] = -1
+			continue
+		}
+		list[i] = value.Value.(float64)
+	}
+	return list
+}
+
+func TestSelectRaw(t *testing.T) {
+	values := historyTestValues(10)
+	tests := []struct {
+		name    string
+		details ua.ReadRawModifiedDetails
+		want    []float64
+	}{
+		{"forward excludes end", ua.ReadRawModifiedDetails{StartTime: historyTestTime(2), EndTime: historyTestTime(5)}, []float64{2, 3, 4}},
+		{"forward open end", ua.ReadRawModifiedDetails{StartTime: historyTestTime(7), NumValuesPerNode: 5}, []float64{7, 8, 9}},
+		{"forward between values", ua.ReadRawModifiedDetails{StartTime: historyTestTime(2).Add(500 * time.Millisecond), EndTime: historyTestTime(5)}, []float64{3, 4}},
+		{"start equals end", ua.ReadRawModifiedDetails{StartTime: historyTestTime(3), EndTime: historyTestTime(3)}, nil},
+		{"backward", ua.ReadRawModifiedDetails{StartTime: historyTestTime(5), EndTime: historyTestTime(2)}, []float64{5, 4, 3}},
+		{"backward from end", ua.ReadRawModifiedDetails{EndTime: historyTestTime(2), NumValuesPerNode: 5}, []float64{2, 1, 0}},
+		{"bounds at values", ua.ReadRawModifiedDetails{StartTime: historyTestTime(2), EndTime: historyTestTime(5), ReturnBounds: true}, []float64{2, 3, 4, 5}},
+		{"bounds between values", ua.ReadRawModifiedDetails{StartTime: historyTestTime(2).Add(time.Millisecond), EndTime: historyTestTime(4).Add(time.Millisecond), ReturnBounds: true}, []float64{2, 3, 4, 5}},
+		{"bounds not found", ua.ReadRawModifiedDetails{StartTime: historyTestTime(-5), EndTime: historyTestTime(20), ReturnBounds: true}, []float64{-1, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, -1}},
+		{"backward bounds", ua.ReadRawModifiedDetails{StartTime: historyTestTime(8).Add(time.Millisecond), EndTime: historyTestTime(7).Add(time.Millisecond), ReturnBounds: true}, []float64{9, 8, 7}},
+		{"before history", ua.ReadRawModifiedDetails{StartTime: historyTestTime(-5), EndTime: historyTestTime(-1)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := historyValueList(selectRaw(values, tt.details))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectRaw = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryBufferWraps(t *testing.T) {
+	var buffer historyBuffer
+	for i, value := range historyTestValues(7) {
+		buffer.add(value, 5)
+		if last, _ := buffer.last(); last.Value.(float64) != float64(i) {
+			t.Fatalf("last = %v, want %d", last.Value, i)
+		}
+	}
+	if got, want := historyValueList(buffer.snapshot()), []float64{2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot = %v, want %v", got, want)
+	}
+}
+
+func TestHistoryContinuationPoints(t *testing.T) {
+	nodeID := ua.NodeIDString{NamespaceIndex: 2, ID: "Tag"}
+	h := newHistorian(100)
+	h.register(nodeID)
+	for _, value := range historyTestValues(25) {
+		h.WriteValue(context.Background(), nodeID, value)
+	}
+
+	details := ua.ReadRawModifiedDetails{StartTime: historyTestTime(0), EndTime: historyTestTime(100), NumValuesPerNode: 10}
+	read := func(cp ua.ByteString, release bool) ua.HistoryReadResult {
+		node := ua.HistoryReadValueID{NodeID: nodeID, ContinuationPoint: cp}
+		return h.readRaw(node, details, ua.TimestampsToReturnBoth, release)
+	}
+
+	var got []float64
+	var pages []ua.ByteString
+	result := read("", false)
+	for {
+		if result.StatusCode != ua.Good {
+			t.Fatalf("page %d: status %v", len(pages), result.StatusCode)
+		}
+		data := result.HistoryData.(ua.HistoryData)
+		if len(data.DataValues) > 10 {
+			t.Fatalf("page %d: %d values, want at most NumValuesPerNode", len(pages), len(data.DataValues))
+		}
+		got = append(got, historyValueList(data.DataValues)...)
+		if result.ContinuationPoint == "" {
+			break
+		}
+		pages = append(pages, result.ContinuationPoint)
+		result = read(result.ContinuationPoint, false)
+	}
+	if len(pages) != 2 || len(got) != 25 || got[0] != 0 || got[24] != 24 {
+		t.Fatalf("read %v in %d continuations, want 0..24 in 2", got, len(pages))
+	}
+
+	// A continuation point is used up by its read
+	if result := read(pages[0], false); result.StatusCode != ua.BadContinuationPointInvalid {
+		t.Errorf("reused continuation point: status %v, want BadContinuationPointInvalid", result.StatusCode)
+	}
+
+	// A continuation point is bound to its node, passing it with another node leaves it to its read
+	first := read("", false)
+	other := ua.HistoryReadValueID{NodeID: ua.NodeIDString{NamespaceIndex: 2, ID: "Other"}, ContinuationPoint: first.ContinuationPoint}
+	if result := h.readRaw(other, details, ua.TimestampsToReturnBoth, false); result.StatusCode != ua.BadContinuationPointInvalid {
+		t.Errorf("continuation point of another node: status %v, want BadContinuationPointInvalid", result.StatusCode)
+	}
+	if result := h.readRaw(other, details, ua.TimestampsToReturnBoth, true); result.StatusCode != ua.BadContinuationPointInvalid {
+		t.Errorf("release with another node: status %v, want BadContinuationPointInvalid", result.StatusCode)
+	}
+
+	// Releasing frees the continuation point without returning values
+	if result := read(first.ContinuationPoint, true); result.StatusCode != ua.Good || result.HistoryData != nil {
+		t.Errorf("release: status %v, data %v", result.StatusCode, result.HistoryData)
+	}
+	if result := read(first.ContinuationPoint, false); result.StatusCode != ua.BadContinuationPointInvalid {
+		t.Errorf("released continuation point: status %v, want BadContinuationPointInvalid", result.StatusCode)
+	}
+	if len(h.cursors) != 0 {
+		t.Errorf("%d continuation points left open", len(h.cursors))
+	}
+}
+
+func TestHistoryContinuationPointLimit(t *testing.T) {
+	nodeID := ua.NodeIDString{NamespaceIndex: 2, ID: "Tag"}
+	h := newHistorian(10)
+	values := historyTestValues(3)
+	for i := 0; i < maxContinuationPoints; i++ {
+		if result := h.page(nodeID, values, 1, ua.TimestampsToReturnBoth); result.ContinuationPoint == "" {
+			t.Fatalf("read %d: no continuation point (status %v)", i, result.StatusCode)
+		}
+	}
+	if result := h.page(nodeID, values, 1, ua.TimestampsToReturnBoth); result.StatusCode != ua.BadNoContinuationPoints {
+		t.Errorf("status %v, want BadNoContinuationPoints", result.StatusCode)
+	}
+
+	// Expired continuation points are dropped to make room
+	for _, cursor := range h.cursors {
+		cursor.created = time.Now().Add(-continuationPointTTL - time.Second)
+	}
+	if result := h.page(nodeID, values, 1, ua.TimestampsToReturnBoth); result.ContinuationPoint == "" {
+		t.Errorf("no continuation point after expiry (status %v)", result.StatusCode)
+	}
+	if len(h.cursors) != 1 {
+		t.Errorf("%d continuation points, want 1", len(h.cursors))
+	}
+}
@@ -0,0 +1,136 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/config"
+	"log"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// Transport limits of the library, used for the limits not set in the configuration
+const (
+	defaultMaxChunkSize   = 64 * 1024
+	defaultMaxMessageSize = 64 * 1024 * 1024
+	defaultMaxChunkCount  = 4 * 1024
+)
+
+// ServerCapabilities properties added in OPC UA 1.05 (Part 5), missing in the namespace 0 of the library
+var (
+	variableIDMaxSessions      = ua.NewNodeIDNumeric(0, 24095)
+	variableIDMaxSubscriptions = ua.NewNodeIDNumeric(0, 24096)
+)
+
+// limitOptions returns the server options for the configured limits and locales. The library enforces the session
+// and subscription counts, the operation limits, the sample rate and the message sizes itself.
+func (s *OPCUAServer) limitOptions() []server.Option {
+	limits := s.limits
+
+	capabilities := ua.NewServerCapabilities()
+	capabilities.LocaleIDArray = s.locales
+	if limits.MinSupportedSampleRate > 0 {
+		capabilities.MinSupportedSampleRate = limits.MinSupportedSampleRate
+	}
+	operations := capabilities.OperationLimits
+	for _, limit := range []struct {
+		value  int
+		target *uint32
+	}{
+		{limits.MaxNodesPerRead, &operations.MaxNodesPerRead},
+		{limits.MaxNodesPerWrite, &operations.MaxNodesPerWrite},
+		{limits.MaxNodesPerBrowse, &operations.MaxNodesPerBrowse},
+		{limits.MaxNodesPerMethodCall, &operations.MaxNodesPerMethodCall},
+		{limits.MaxMonitoredItemsPerCall, &operations.MaxMonitoredItemsPerCall},
+	} {
+		if limit.value > 0 {
+			*limit.target = uint32(limit.value)
+		}
+	}
+
+	opts := []server.Option{
+		server.WithMaxSessionCount(uint32(limits.MaxSessions)),
+		server.WithMaxSubscriptionCount(uint32(limits.MaxSubscriptions)),
+		server.WithServerCapabilities(capabilities),
+	}
+	if limits.MaxChunkSize > 0 || limits.MaxMessageSize > 0 || limits.MaxChunkCount > 0 {
+		opts = append(opts, server.WithTransportLimits(
+			limitOrDefault(limits.MaxChunkSize, defaultMaxChunkSize),
+			limitOrDefault(limits.MaxMessageSize, defaultMaxMessageSize),
+			limitOrDefault(limits.MaxChunkCount, defaultMaxChunkCount),
+		))
+	}
+	return opts
+}
+
+// logLimits logs the limits that differ from the defaults
+func (s *OPCUAServer) logLimits() {
+	limits := s.limits
+	if limits == (config.ServerLimits{}) {
+		return
+	}
+	caps := s.server.ServerCapabilities()
+	log.Printf("[OPCUA] Limits: sessions %d, subscriptions %d (0 = unlimited)", limits.MaxSessions, limits.MaxSubscriptions)
+	log.Printf("[OPCUA] Limits: nodes per read %d, write %d, browse %d, call %d, monitored items per call %d, min sample rate %gms",
+		caps.OperationLimits.MaxNodesPerRead, caps.OperationLimits.MaxNodesPerWrite, caps.OperationLimits.MaxNodesPerBrowse,
+		caps.OperationLimits.MaxNodesPerMethodCall, caps.OperationLimits.MaxMonitoredItemsPerCall, caps.MinSupportedSampleRate)
+	log.Printf("[OPCUA] Limits: chunk size %d, message size %d, chunk count %d",
+		limitOrDefault(limits.MaxChunkSize, defaultMaxChunkSize),
+		limitOrDefault(limits.MaxMessageSize, defaultMaxMessageSize),
+		limitOrDefault(limits.MaxChunkCount, defaultMaxChunkCount))
+}
+
+// advertiseLimits publishes the session and subscription limits in Server/ServerCapabilities
+// (0 = no limit). The library sets the operation limits and MinSupportedSampleRate itself.
+func (s *OPCUAServer) advertiseLimits() {
+	nm := s.server.NamespaceManager()
+
+	for _, capability := range []struct {
+		nodeID ua.NodeID
+		name   string
+		value  int
+	}{
+		{variableIDMaxSessions, "MaxSessions", s.limits.MaxSessions},
+		{variableIDMaxSubscriptions, "MaxSubscriptions", s.limits.MaxSubscriptions},
+	} {
+		value := ua.NewDataValue(uint32(capability.value), 0, time.Now(), 0, time.Now(), 0)
+		if n, ok := nm.FindVariable(capability.nodeID); ok {
+			n.SetValue(value)
+			continue
+		}
+		nm.AddNode(server.NewVariableNode(
+			s.server,
+			capability.nodeID,
+			ua.QualifiedName{Name: capability.name},
+			ua.LocalizedText{Text: capability.name},
+			ua.LocalizedText{},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.VariableTypeIDPropertyType},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasProperty,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectIDServerServerCapabilities},
+				},
+			},
+			value,
+			ua.DataTypeIDUInt32,
+			ua.ValueRankScalar,
+			[]uint32{},
+			ua.AccessLevelsCurrentRead,
+			0,
+			false,
+			nil,
+		))
+	}
+}
+
+func limitOrDefault(value int, defaultValue uint32) uint32 {
+	if value > 0 {
+		return uint32(value)
+	}
+	return defaultValue
+}
@@ -0,0 +1,103 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"sort"
+	"strings"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// defaultLocale is the LocaleIdArray of the server when the configuration sets no locales
+const defaultLocale = "en"
+
+// localizedText returns the text of the most preferred server locale: the first locale of the LocaleIdArray
+// with a text, matched exactly or by language ("ko" and "ko-KR"), else the text of the first locale in
+// alphabetical order. Without localized texts the fallback is returned without a locale.
+func (s *OPCUAServer) localizedText(texts map[string]string, fallback string) ua.LocalizedText {
+	locales := sortedLocales(texts)
+	if len(locales) == 0 {
+		return ua.LocalizedText{Text: fallback}
+	}
+
+	for _, preferred := range s.locales {
+		for _, locale := range locales {
+			if strings.EqualFold(locale, preferred) {
+				return ua.LocalizedText{Text: texts[locale], Locale: locale}
+			}
+		}
+		for _, locale := range locales {
+			if strings.EqualFold(language(locale), language(preferred)) {
+				return ua.LocalizedText{Text: texts[locale], Locale: locale}
+			}
+		}
+	}
+	return ua.LocalizedText{Text: texts[locales[0]], Locale: locales[0]}
+}
+
+// tagDisplayName returns the DisplayName of a tag variable
+func (s *OPCUAServer) tagDisplayName(tag *plc.Tag, browseName string) ua.LocalizedText {
+	return s.localizedText(tag.DisplayNames, browseName)
+}
+
+// tagDescription returns the Description of a tag variable and its control object
+func (s *OPCUAServer) tagDescription(tag *plc.Tag) ua.LocalizedText {
+	return s.localizedText(tag.Descriptions, tag.Description)
+}
+
+// localizedNodeTexts returns the DisplayName and Description of the tag nodes in all locales for the NodeSet export,
+// the published text first (caller holds the lock)
+func (s *OPCUAServer) localizedNodeTexts() map[ua.NodeID]localizedTexts {
+	texts := make(map[ua.NodeID]localizedTexts)
+	for name, varNodes := range s.tagNodes {
+		tag, err := s.tagManager.GetTag(name)
+		if err != nil || (len(tag.DisplayNames) == 0 && len(tag.Descriptions) == 0) {
+			continue
+		}
+		for _, node := range varNodes {
+			texts[node.NodeID()] = localizedTexts{
+				displayName: allLocales(node.DisplayName(), tag.DisplayNames),
+				description: allLocales(node.Description(), tag.Descriptions),
+			}
+		}
+		controlID := ua.NodeIDString{NamespaceIndex: s.namespace, ID: config.ControlFolder + "/" + tag.Name}
+		texts[controlID] = localizedTexts{description: allLocales(s.tagDescription(tag), tag.Descriptions)}
+	}
+	return texts
+}
+
+// localizedTexts holds the texts of a node in all locales, nil = the attribute of the node
+type localizedTexts struct {
+	displayName []nodeSetText
+	description []nodeSetText
+}
+
+// allLocales returns the published text followed by the texts of the other locales, nil without localized texts
+func allLocales(published ua.LocalizedText, texts map[string]string) []nodeSetText {
+	if len(texts) == 0 || published.Locale == "" {
+		return nil
+	}
+	list := []nodeSetText{{Locale: published.Locale, Text: published.Text}}
+	for _, locale := range sortedLocales(texts) {
+		if locale != published.Locale {
+			list = append(list, nodeSetText{Locale: locale, Text: texts[locale]})
+		}
+	}
+	return list
+}
+
+func sortedLocales(texts map[string]string) []string {
+	locales := make([]string, 0, len(texts))
+	for locale := range texts {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// language returns the language part of a locale ID ("ko-KR" -> "ko")
+func language(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
@@ -0,0 +1,330 @@
+package opcuaserver
+
+import (
+	"errors"
+	"fmt"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim"
+	"go-opcua-sim/internal/sim/sensors"
+	"log"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// controlFolderID is the folder holding one control object per sensor
+func (s *OPCUAServer) controlFolderID() ua.NodeIDString {
+	return ua.NodeIDString{NamespaceIndex: s.namespace, ID: config.ControlFolder}
+}
+
+// sensorMethod is a control operation exposed as a Method node on the sensor object
+type sensorMethod struct {
+	name        string
+	description string
+	inputs      []ua.Argument
+	outputs     []ua.Argument
+	call        func(inputs []ua.Variant) ([]ua.Variant, error)
+}
+
+// argumentError is returned by a sensor method for an input argument with an invalid value
+type argumentError struct {
+	index  int
+	status ua.StatusCode
+	err    error
+}
+
+func (e *argumentError) Error() string {
+	return fmt.Sprintf("input argument %d: %v", e.index, e.err)
+}
+
+// sensorMethods returns the control methods supported by a sensor
+func sensorMethods(sm *sim.SensorManager, tag *plc.Tag) []sensorMethod {
+	sensor := sm.GetSensor(tag.Name)
+	if sensor == nil {
+		return nil
+	}
+	name := tag.Name
+
+	methods := []sensorMethod{
+		{
+			name:        "Reset",
+			description: "Reset the sensor to its initial state",
+			call: func([]ua.Variant) ([]ua.Variant, error) {
+				return nil, sm.ResetSensor(name)
+			},
+		},
+		{
+			name:        "Enable",
+			description: "Start the sensor simulation",
+			call: func([]ua.Variant) ([]ua.Variant, error) {
+				return nil, sm.SetSensorEnabled(name, true)
+			},
+		},
+		{
+			name:        "Disable",
+			description: "Stop the sensor simulation (the value is held)",
+			call: func([]ua.Variant) ([]ua.Variant, error) {
+				return nil, sm.SetSensorEnabled(name, false)
+			},
+		},
+		{
+			name:        "SetQuality",
+			description: "Set the quality (StatusCode) of the sensor values, e.g. Bad_SensorFailure",
+			inputs:      []ua.Argument{newArgument("Quality", ua.DataTypeIDString, "Quality name (Good, Uncertain_LastUsableValue, Bad_SensorFailure, ...)")},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				quality, err := plc.ParseQuality(inputs[0].(string))
+				if err != nil {
+					return nil, &argumentError{index: 0, status: ua.BadOutOfRange, err: err}
+				}
+				return nil, sm.SetSensorQuality(name, quality)
+			},
+		},
+	}
+
+	if _, ok := sensor.(sensors.Actuator); ok {
+		dataType := tagDataType(tag.Type)
+		methods = append(methods, sensorMethod{
+			name:        "SetTarget",
+			description: "Command the actuator target and leave its automatic pattern",
+			inputs:      []ua.Argument{newArgument("Target", dataType, "Commanded value")},
+			outputs:     []ua.Argument{newArgument("Applied", dataType, "Target after limits are applied")},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				applied, err := sm.CommandSensor(name, variantFloat64(inputs[0]))
+				if err != nil {
+					return nil, err
+				}
+				return []ua.Variant{float64ToVariant(applied, tag.Type)}, nil
+			},
+		})
+	}
+
+	if _, ok := sensor.(sensors.LoadController); ok {
+		methods = append(methods, sensorMethod{
+			name:        "SetLoad",
+			description: "Set the external load torque of the motor",
+			inputs:      []ua.Argument{newArgument("Torque", ua.DataTypeIDDouble, "Load torque (Nm)")},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				return nil, sm.SetSensorLoad(name, inputs[0].(float64))
+			},
+		})
+	}
+
+	return methods
+}
+
+// controlNodes creates the control folder and one object per sensor with its method nodes and limit alarm
+func (s *OPCUAServer) controlNodes(tags []*plc.Tag) []server.Node {
+	nodes := []server.Node{
+		server.NewObjectNode(
+			s.server,
+			s.controlFolderID(),
+			ua.QualifiedName{NamespaceIndex: s.namespace, Name: config.ControlFolder},
+			ua.LocalizedText{Text: config.ControlFolder},
+			ua.LocalizedText{Text: "Sensor control methods"},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectTypeIDFolderType},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDOrganizes,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: objectsFolderID},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasNotifier,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectIDServer},
+				},
+			},
+			ua.EventNotifierSubscribeToEvents,
+		),
+	}
+
+	// Runtime sensor add/remove and configuration reload
+	for _, method := range s.modelMethods() {
+		nodes = append(nodes, s.methodNodes(s.controlFolderID(), method)...)
+	}
+
+	methodCount := 0
+	for _, tag := range tags {
+		objectNodes, count := s.controlObjectNodes(tag)
+		nodes = append(nodes, objectNodes...)
+		methodCount += count
+	}
+
+	fmt.Printf("\n%d control methods and %d limit alarms created under %s\n", methodCount, len(s.alarms), config.ControlFolder)
+	return nodes
+}
+
+// controlObjectNodes creates the control object of a sensor with its method nodes and limit alarm.
+// It returns the nodes and the number of methods.
+func (s *OPCUAServer) controlObjectNodes(tag *plc.Tag) ([]server.Node, int) {
+	methods := sensorMethods(s.sensorManager, tag)
+	if len(methods) == 0 {
+		return nil, 0
+	}
+
+	objectID := ua.NodeIDString{NamespaceIndex: s.namespace, ID: config.ControlFolder + "/" + tag.Name}
+	nodes := []server.Node{server.NewObjectNode(
+		s.server,
+		objectID,
+		ua.QualifiedName{NamespaceIndex: s.namespace, Name: tag.Name},
+		ua.LocalizedText{Text: tag.Name},
+		s.tagDescription(tag),
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+				TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectTypeIDBaseObjectType},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDOrganizes,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: s.controlFolderID()},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasNotifier,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: s.controlFolderID()},
+			},
+		},
+		ua.EventNotifierSubscribeToEvents,
+	)}
+
+	for _, method := range methods {
+		nodes = append(nodes, s.methodNodes(objectID, method)...)
+	}
+
+	// Limit alarm condition for analog tags
+	if tag.Alarms != nil && tag.Type.IsNumeric() {
+		nodes = append(nodes, s.alarmNodes(tag, objectID, s.nodeMapping[tag.Name])...)
+	}
+
+	s.trackNodes(tag.Name, nodes)
+	return nodes, len(methods)
+}
+
+// methodNodes creates a method node and its argument properties
+func (s *OPCUAServer) methodNodes(objectID ua.NodeIDString, method sensorMethod) []server.Node {
+	methodID := ua.NodeIDString{NamespaceIndex: s.namespace, ID: objectID.ID + "/" + method.name}
+
+	methodNode := server.NewMethodNode(
+		s.server,
+		methodID,
+		ua.QualifiedName{NamespaceIndex: s.namespace, Name: method.name},
+		ua.LocalizedText{Text: method.name},
+		ua.LocalizedText{Text: method.description},
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasComponent,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: objectID},
+			},
+		},
+		true,
+	)
+	methodNode.SetCallMethodHandler(newCallHandler(objectID.ID, method))
+
+	nodes := []server.Node{methodNode}
+	if len(method.inputs) > 0 {
+		nodes = append(nodes, s.argumentsNode(methodID, "InputArguments", method.inputs))
+	}
+	if len(method.outputs) > 0 {
+		nodes = append(nodes, s.argumentsNode(methodID, "OutputArguments", method.outputs))
+	}
+	return nodes
+}
+
+// argumentsNode creates the InputArguments/OutputArguments property of a method
+func (s *OPCUAServer) argumentsNode(methodID ua.NodeIDString, name string, args []ua.Argument) server.Node {
+	value := make([]ua.ExtensionObject, len(args))
+	for i, arg := range args {
+		value[i] = arg
+	}
+
+	return server.NewVariableNode(
+		s.server,
+		ua.NodeIDString{NamespaceIndex: s.namespace, ID: methodID.ID + "/" + name},
+		ua.QualifiedName{NamespaceIndex: 0, Name: name},
+		ua.LocalizedText{Text: name},
+		ua.LocalizedText{},
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+				TargetID:        ua.ExpandedNodeID{NodeID: ua.VariableTypeIDPropertyType},
+			},
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasProperty,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: methodID},
+			},
+		},
+		ua.NewDataValue(value, 0, time.Now(), 0, time.Now(), 0),
+		ua.DataTypeIDArgument,
+		ua.ValueRankOneDimension,
+		[]uint32{uint32(len(args))},
+		ua.AccessLevelsCurrentRead,
+		0,
+		false,
+		nil,
+	)
+}
+
+// newCallHandler checks the input arguments and calls the sensor method
+func newCallHandler(objectPath string, method sensorMethod) func(*server.Session, ua.CallMethodRequest) ua.CallMethodResult {
+	return func(session *server.Session, req ua.CallMethodRequest) ua.CallMethodResult {
+		if len(req.InputArguments) < len(method.inputs) {
+			return ua.CallMethodResult{StatusCode: ua.BadArgumentsMissing}
+		}
+		if len(req.InputArguments) > len(method.inputs) {
+			return ua.CallMethodResult{StatusCode: ua.BadTooManyArguments}
+		}
+
+		argResults := make([]ua.StatusCode, len(method.inputs))
+		status := ua.Good
+		for i, arg := range method.inputs {
+			if !variantMatches(req.InputArguments[i], arg.DataType) {
+				argResults[i] = ua.BadTypeMismatch
+				status = ua.BadInvalidArgument
+			}
+		}
+		if status != ua.Good {
+			return ua.CallMethodResult{StatusCode: status, InputArgumentResults: argResults}
+		}
+
+		outputs, err := method.call(req.InputArguments)
+		if err != nil {
+			log.Printf("[OPCUA] %s/%s failed: %v", objectPath, method.name, err)
+			var argErr *argumentError
+			if errors.As(err, &argErr) {
+				argResults[argErr.index] = argErr.status
+				return ua.CallMethodResult{StatusCode: ua.BadInvalidArgument, InputArgumentResults: argResults}
+			}
+			return ua.CallMethodResult{StatusCode: ua.BadInvalidState}
+		}
+
+		log.Printf("[OPCUA] Called %s/%s", objectPath, method.name)
+		return ua.CallMethodResult{
+			StatusCode:           ua.Good,
+			InputArgumentResults: argResults,
+			OutputArguments:      outputs,
+		}
+	}
+}
+
+// newArgument creates a scalar method argument description
+func newArgument(name string, dataType ua.NodeID, description string) ua.Argument {
+	return ua.Argument{
+		Name:            name,
+		DataType:        dataType,
+		ValueRank:       ua.ValueRankScalar,
+		ArrayDimensions: []uint32{},
+		Description:     ua.LocalizedText{Text: description},
+	}
+}
@@ -0,0 +1,423 @@
+package opcuaserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// trackNodes records the nodes created for a tag, they are deleted when the tag is removed or replaced
+func (s *OPCUAServer) trackNodes(name string, nodes []server.Node) {
+	for _, node := range nodes {
+		s.modelNodes[name] = append(s.modelNodes[name], node.NodeID())
+	}
+}
+
+// modelMethods returns the methods of the control folder that add and remove sensors at runtime
+func (s *OPCUAServer) modelMethods() []sensorMethod {
+	sm := s.sensorManager
+	if sm == nil {
+		return nil
+	}
+
+	definition := newArgument("Definition", ua.DataTypeIDString, "Sensor definition as in the configuration file (JSON object)")
+	methods := []sensorMethod{
+		{
+			name:        "AddSensor",
+			description: "Add a sensor and its tag",
+			inputs:      []ua.Argument{definition},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				def, err := parseSensorDefinition(inputs[0].(string))
+				if err != nil {
+					return nil, err
+				}
+				return nil, sm.AddSensor(def)
+			},
+		},
+		{
+			name:        "ReplaceSensor",
+			description: "Replace a sensor and its tag with a new definition of the same name (e.g. a new data type)",
+			inputs:      []ua.Argument{definition},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				def, err := parseSensorDefinition(inputs[0].(string))
+				if err != nil {
+					return nil, err
+				}
+				return nil, sm.ReplaceSensor(def)
+			},
+		},
+		{
+			name:        "RemoveSensor",
+			description: "Remove a sensor and its tag",
+			inputs:      []ua.Argument{newArgument("Name", ua.DataTypeIDString, "Sensor name")},
+			call: func(inputs []ua.Variant) ([]ua.Variant, error) {
+				return nil, sm.RemoveSensor(inputs[0].(string))
+			},
+		},
+		{
+			name:        "ExportNodeSet",
+			description: "Export the current address space as NodeSet2 XML",
+			outputs:     []ua.Argument{newArgument("NodeSet", ua.DataTypeIDString, "NodeSet2 XML document")},
+			call: func([]ua.Variant) ([]ua.Variant, error) {
+				var buf strings.Builder
+				if err := s.ExportNodeSet(&buf); err != nil {
+					return nil, err
+				}
+				return []ua.Variant{buf.String()}, nil
+			},
+		},
+	}
+
+	if s.reload != nil {
+		methods = append(methods, sensorMethod{
+			name:        "ReloadConfig",
+			description: "Reload the sensor configuration file and apply the changed sensors",
+			call: func([]ua.Variant) ([]ua.Variant, error) {
+				return nil, s.reload()
+			},
+		})
+	}
+	return methods
+}
+
+// parseSensorDefinition decodes a sensor definition passed as JSON
+func parseSensorDefinition(text string) (config.SensorDefinition, error) {
+	var def config.SensorDefinition
+	if err := json.Unmarshal([]byte(text), &def); err != nil {
+		return def, fmt.Errorf("invalid sensor definition JSON: %w", err)
+	}
+	return def, nil
+}
+
+// handleModelChange creates and deletes the nodes of a tag added, removed or replaced at runtime
+// and reports the change with a GeneralModelChangeEvent. Sessions and subscriptions are kept:
+// monitored items of a deleted node report Bad_NodeIdUnknown, items of a replaced node continue.
+func (s *OPCUAServer) handleModelChange(tag *plc.Tag, change plc.ModelChange) {
+	changes := newModelChanges()
+
+	s.mu.Lock()
+	err := s.syncTagNodes(tag, change, changes)
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("[OPCUA] Failed to create nodes of tag %s: %v", tag.Name, err)
+	}
+	if change != plc.TagRemoved && err == nil {
+		s.publishTag(tag)
+	}
+	if changes.len() > 0 {
+		s.fireModelChange(changes, fmt.Sprintf("Tag %s %s", tag.Name, change))
+	}
+	log.Printf("[OPCUA] Tag %s %s (%d nodes affected)", tag.Name, change, changes.len())
+	s.exportNodeSetFile()
+}
+
+// syncTagNodes deletes the old nodes of a tag, creates its new nodes and adds or deletes
+// the folders and device objects of its browse path (caller holds the lock)
+func (s *OPCUAServer) syncTagNodes(tag *plc.Tag, change plc.ModelChange, changes *modelChanges) error {
+	nm := s.server.NamespaceManager()
+
+	oldTypes := s.removeTagNodes(tag.Name, changes)
+
+	tags := s.tagManager.GetAllTags()
+	next := s.nextAddressSpace(tags)
+
+	var tagNodes []server.Node
+	var err error
+	if change != plc.TagRemoved {
+		if tagNodes, err = s.newTagNodes(tag, next); err == nil {
+			err = s.checkNodeIDs(tagNodes)
+		}
+		if err != nil {
+			// Keep the tag without nodes, the browse path is not created
+			s.removeTagNodes(tag.Name, nil)
+			tagNodes = nil
+			remaining := make([]*plc.Tag, 0, len(tags))
+			for _, t := range tags {
+				if t.Name != tag.Name {
+					remaining = append(remaining, t)
+				}
+			}
+			next = s.nextAddressSpace(remaining)
+		}
+	}
+
+	// New folders and device objects first, then the tag nodes
+	var added, removed []string
+	for path := range next.containers {
+		if _, exists := s.addrSpace.containers[path]; !exists {
+			added = append(added, path)
+		}
+	}
+	for path := range s.addrSpace.containers {
+		if _, exists := next.containers[path]; !exists {
+			removed = append(removed, path)
+		}
+	}
+
+	nodes := append(next.containerNodes(s.server, added), tagNodes...)
+	nm.AddNodes(nodes...)
+	changes.nodesAdded(s.structuralNodes(nodes), oldTypes)
+
+	// Emptied folders and device objects, deepest first
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	for _, path := range removed {
+		if node, ok := nm.FindNode(s.addrSpace.containerNodeID(path)); ok {
+			changes.nodesDeleted([]server.Node{node})
+			nm.DeleteNode(node, false)
+		}
+	}
+
+	s.addrSpace = next
+	return err
+}
+
+// structuralNodes drops the tag variables that replace NodeSet variables, they do not change the address space
+func (s *OPCUAServer) structuralNodes(nodes []server.Node) []server.Node {
+	result := make([]server.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if !s.isBound(node) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// nextAddressSpace builds the containers of the given tags. Existing containers keep their type.
+func (s *OPCUAServer) nextAddressSpace(tags []*plc.Tag) *addressSpace {
+	next := newAddressSpace(s.placedTags(tags), s.pathNamespace)
+	for path, c := range next.containers {
+		if current, exists := s.addrSpace.containers[path]; exists {
+			c.isDevice = current.isDevice
+		}
+	}
+	return next
+}
+
+// newTagNodes creates the variable, property and control nodes of a tag (caller holds the lock)
+func (s *OPCUAServer) newTagNodes(tag *plc.Tag, addrSpace *addressSpace) ([]server.Node, error) {
+	nodes, err := s.tagNodeSet(tag, addrSpace)
+	if err != nil {
+		return nil, err
+	}
+	if s.sensorManager != nil {
+		controlNodes, _ := s.controlObjectNodes(tag)
+		nodes = append(nodes, controlNodes...)
+	}
+	return nodes, nil
+}
+
+// removeTagNodes deletes the nodes created for a tag and forgets the tag (caller holds the lock).
+// It returns the DataType and ValueRank of the deleted variables, to detect changed data types.
+func (s *OPCUAServer) removeTagNodes(name string, changes *modelChanges) map[ua.NodeID]variableType {
+	nm := s.server.NamespaceManager()
+	ids := s.modelNodes[name]
+
+	var nodes []server.Node
+	for _, id := range ids {
+		if s.unbindVariable(id) {
+			continue // the NodeSet variable is put back
+		}
+		if node, ok := nm.FindNode(id); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	oldTypes := variableTypes(nodes)
+	if changes != nil {
+		changes.nodesDeleted(nodes)
+	}
+
+	// Children before parents, nodes already deleted with their parent are skipped
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if _, ok := nm.FindNode(nodes[i].NodeID()); ok {
+			nm.DeleteNode(nodes[i], false)
+		}
+	}
+
+	for _, id := range ids {
+		if s.historian != nil {
+			s.historian.unregister(id)
+		}
+	}
+	delete(s.modelNodes, name)
+	delete(s.nodeMapping, name)
+	delete(s.stateNodes, name)
+
+	s.publishMu.Lock()
+	delete(s.tagNodes, name)
+	delete(s.deadbands, name)
+	delete(s.alarms, name)
+	s.publishMu.Unlock()
+
+	return oldTypes
+}
+
+// variableType is the DataType and ValueRank of a variable node
+type variableType struct {
+	dataType  ua.NodeID
+	valueRank int32
+}
+
+// variableTypes returns the DataType and ValueRank of the variable nodes
+func variableTypes(nodes []server.Node) map[ua.NodeID]variableType {
+	types := make(map[ua.NodeID]variableType)
+	for _, node := range nodes {
+		if v, ok := node.(*server.VariableNode); ok {
+			types[v.NodeID()] = variableType{dataType: v.DataType(), valueRank: v.ValueRank()}
+		}
+	}
+	return types
+}
+
+// modelChanges collects the nodes affected by a model change, with the verbs merged per node
+type modelChanges struct {
+	order []ua.NodeID
+	verbs map[ua.NodeID]ua.ModelChangeStructureVerbMask
+	types map[ua.NodeID]ua.NodeID
+}
+
+// newModelChanges creates an empty change set
+func newModelChanges() *modelChanges {
+	return &modelChanges{verbs: make(map[ua.NodeID]ua.ModelChangeStructureVerbMask), types: make(map[ua.NodeID]ua.NodeID)}
+}
+
+// len returns the number of affected nodes
+func (mc *modelChanges) len() int {
+	return len(mc.order)
+}
+
+// add records a verb for a node
+func (mc *modelChanges) add(nodeID, affectedType ua.NodeID, verb ua.ModelChangeStructureVerbMask) {
+	if _, exists := mc.verbs[nodeID]; !exists {
+		mc.order = append(mc.order, nodeID)
+	}
+	mc.verbs[nodeID] |= verb
+	if affectedType != nil {
+		mc.types[nodeID] = affectedType
+	}
+}
+
+// nodesAdded records added nodes, references added to their parents outside the set
+// and changed data types of variables that replace deleted ones
+func (mc *modelChanges) nodesAdded(nodes []server.Node, oldTypes map[ua.NodeID]variableType) {
+	mc.record(nodes, ua.ModelChangeStructureVerbMaskNodeAdded, ua.ModelChangeStructureVerbMaskReferenceAdded)
+	for id, current := range variableTypes(nodes) {
+		if old, ok := oldTypes[id]; ok && (old.dataType != current.dataType || old.valueRank != current.valueRank) {
+			mc.add(id, nil, ua.ModelChangeStructureVerbMaskDataTypeChanged)
+		}
+	}
+}
+
+// nodesDeleted records deleted nodes and references deleted from their parents outside the set
+func (mc *modelChanges) nodesDeleted(nodes []server.Node) {
+	mc.record(nodes, ua.ModelChangeStructureVerbMaskNodeDeleted, ua.ModelChangeStructureVerbMaskReferenceDeleted)
+}
+
+// record adds a node verb for the nodes and a reference verb for their parents
+func (mc *modelChanges) record(nodes []server.Node, nodeVerb, referenceVerb ua.ModelChangeStructureVerbMask) {
+	inSet := make(map[ua.NodeID]bool, len(nodes))
+	for _, node := range nodes {
+		inSet[node.NodeID()] = true
+	}
+
+	for _, node := range nodes {
+		mc.add(node.NodeID(), typeDefinition(node), nodeVerb)
+		for _, ref := range node.References() {
+			if !ref.IsInverse || ref.ReferenceTypeID == ua.ReferenceTypeIDHasTypeDefinition {
+				continue
+			}
+			if parent := ref.TargetID.NodeID; parent != nil && !inSet[parent] {
+				mc.add(parent, nil, referenceVerb)
+			}
+		}
+	}
+}
+
+// structures returns the changes as ModelChangeStructureDataType values
+func (mc *modelChanges) structures() []ua.ExtensionObject {
+	result := make([]ua.ExtensionObject, len(mc.order))
+	for i, id := range mc.order {
+		result[i] = ua.ModelChangeStructureDataType{Affected: id, AffectedType: mc.types[id], Verb: uint8(mc.verbs[id])}
+	}
+	return result
+}
+
+// typeDefinition returns the type definition of an object or variable node
+func typeDefinition(node server.Node) ua.NodeID {
+	for _, ref := range node.References() {
+		if !ref.IsInverse && ref.ReferenceTypeID == ua.ReferenceTypeIDHasTypeDefinition {
+			return ref.TargetID.NodeID
+		}
+	}
+	return nil
+}
+
+// modelChangeEvent is a GeneralModelChangeEventType event raised by the Server object
+type modelChangeEvent struct {
+	EventID     ua.ByteString
+	Time        time.Time
+	ReceiveTime time.Time
+	Message     ua.LocalizedText
+	Changes     []ua.ExtensionObject
+}
+
+// GetAttribute returns an event field selected by browse path
+func (e *modelChangeEvent) GetAttribute(clause ua.SimpleAttributeOperand) ua.Variant {
+	if clause.AttributeID != ua.AttributeIDValue {
+		return nil
+	}
+
+	names := make([]string, len(clause.BrowsePath))
+	for i, name := range clause.BrowsePath {
+		names[i] = name.Name
+	}
+
+	switch strings.Join(names, "/") {
+	case "EventId":
+		return e.EventID
+	case "EventType":
+		return ua.ObjectTypeIDGeneralModelChangeEventType
+	case "SourceNode":
+		return ua.ObjectIDServer
+	case "SourceName":
+		return "Server"
+	case "Time":
+		return e.Time
+	case "ReceiveTime":
+		return e.ReceiveTime
+	case "Message":
+		return e.Message
+	case "Severity":
+		return uint16(100)
+	case "Changes":
+		return e.Changes
+	default:
+		return nil
+	}
+}
+
+// fireModelChange raises a GeneralModelChangeEvent on the Server object
+func (s *OPCUAServer) fireModelChange(changes *modelChanges, message string) {
+	nm := s.server.NamespaceManager()
+	serverObject, ok := nm.FindObject(ua.ObjectIDServer)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	nm.OnEvent(serverObject, &modelChangeEvent{
+		EventID:     newEventID(),
+		Time:        now,
+		ReceiveTime: now,
+		Message:     ua.LocalizedText{Text: message, Locale: "en"},
+		Changes:     changes.structures(),
+	})
+}
@@ -0,0 +1,211 @@
+package opcuaserver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// writeTestCertificate writes a self-signed server certificate and key to the PKI directory
+func writeTestCertificate(t *testing.T, security SecurityConfig) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-opcua-sim test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := security.certificatePaths()
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newLibraryServer creates a server of the library with a test certificate, it is not started
+func newLibraryServer(t *testing.T, security SecurityConfig) *server.Server {
+	t.Helper()
+	writeTestCertificate(t, security)
+	certPath, keyPath := security.certificatePaths()
+	srv, err := server.New(ua.ApplicationDescription{ApplicationURI: "urn:go-opcua-sim:test"},
+		certPath, keyPath, "opc.tcp://localhost:4840", security.serverOptions()...)
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+	return srv
+}
+
+// modelTestServer builds the address space of a server over the sensors without listening.
+// Model changes are applied like at runtime, the change sets are sent to the returned channel.
+func modelTestServer(t *testing.T, cfg Config, defs ...config.SensorDefinition) (*OPCUAServer, <-chan *modelChanges) {
+	t.Helper()
+	s := analogTestServer(t, defs...)
+	s.namespaceURI = config.DefaultNamespaceURI
+	s.nodeSets = cfg.NodeSets
+	s.security = SecurityConfig{AllowNone: true, PKIDir: t.TempDir()}
+	s.server = newLibraryServer(t, s.security)
+	s.registerNamespaces()
+	if err := s.registerNodes(); err != nil {
+		t.Fatalf("register nodes: %v", err)
+	}
+
+	changes := make(chan *modelChanges, 1)
+	s.tagManager.AddModelListener(func(tag *plc.Tag, change plc.ModelChange) {
+		mc := newModelChanges()
+		s.mu.Lock()
+		err := s.syncTagNodes(tag, change, mc)
+		s.mu.Unlock()
+		if err != nil {
+			t.Errorf("sync nodes of %s: %v", tag.Name, err)
+		}
+		changes <- mc
+	})
+	return s, changes
+}
+
+// callModelMethod calls a method of the control folder
+func callModelMethod(t *testing.T, s *OPCUAServer, name string, inputs ...ua.Variant) {
+	t.Helper()
+	for _, method := range s.modelMethods() {
+		if method.name == name {
+			if _, err := method.call(inputs); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			return
+		}
+	}
+	t.Fatalf("method %s not found", name)
+}
+
+// checkVerbs checks the verbs recorded for nodes, other nodes must not be affected
+func checkVerbs(t *testing.T, mc *modelChanges, want map[ua.NodeID]ua.ModelChangeStructureVerbMask) {
+	t.Helper()
+	for nodeID, verb := range want {
+		if got := mc.verbs[nodeID]; got != verb {
+			t.Errorf("verb of %s = %#x, want %#x", nodeID, uint8(got), uint8(verb))
+		}
+	}
+	for nodeID, verb := range mc.verbs {
+		if _, expected := want[nodeID]; !expected && verb&(ua.ModelChangeStructureVerbMaskNodeAdded|ua.ModelChangeStructureVerbMaskNodeDeleted) == 0 {
+			t.Errorf("unexpected verb %#x on %s", uint8(verb), nodeID)
+		}
+	}
+}
+
+func TestModelChanges(t *testing.T) {
+	s, changes := modelTestServer(t, Config{}, config.SensorDefinition{
+		Name: "Level", Type: "memory", Enabled: true, Address: "%DW0", BrowsePath: "Plant/Tank1/Level", UpdateIntervalMs: 100,
+	})
+	nm := s.server.NamespaceManager()
+	ns := s.namespace
+	tank1 := s.addrSpace.containerNodeID("Plant/Tank1")
+	mixer := ua.NodeIDString{NamespaceIndex: ns, ID: "Plant/Mixer"}
+	speed := ua.NodeIDString{NamespaceIndex: ns, ID: "Speed"}
+	controls := ua.NodeIDString{NamespaceIndex: ns, ID: config.ControlFolder}
+
+	// Adding a sensor creates its variable, control object and device object, the existing parents get a reference
+	callModelMethod(t, s, "AddSensor", `{"name": "Speed", "type": "memory", "enabled": true, "address": "%DW2", "updateIntervalMs": 100, "browsePath": "Plant/Mixer/Speed"}`)
+	added := <-changes
+	if s.addrSpace.containerNodeID("Plant/Mixer") != mixer {
+		t.Fatalf("Mixer folder = %v, want %v", s.addrSpace.containerNodeID("Plant/Mixer"), mixer)
+	}
+	checkVerbs(t, added, map[ua.NodeID]ua.ModelChangeStructureVerbMask{
+		mixer:                                ua.ModelChangeStructureVerbMaskNodeAdded,
+		speed:                                ua.ModelChangeStructureVerbMaskNodeAdded,
+		s.addrSpace.containerNodeID("Plant"): ua.ModelChangeStructureVerbMaskReferenceAdded,
+		controls:                             ua.ModelChangeStructureVerbMaskReferenceAdded,
+	})
+	if added.types[mixer] != ua.ObjectTypeIDBaseObjectType || added.types[speed] != ua.VariableTypeIDBaseDataVariableType {
+		t.Errorf("affected types = %v, %v, want BaseObjectType, BaseDataVariableType", added.types[mixer], added.types[speed])
+	}
+	variable, ok := nm.FindVariable(speed)
+	if !ok {
+		t.Fatal("Speed variable not created")
+	}
+	if variable.DataType() != ua.DataTypeIDInt32 {
+		t.Errorf("Speed DataType = %v, want Int32", variable.DataType())
+	}
+
+	// Replacing it with another data type deletes and adds the variable as one change
+	callModelMethod(t, s, "ReplaceSensor", `{"name": "Speed", "type": "memory", "enabled": true, "address": "%DW2", "updateIntervalMs": 100, "dataType": "Double", "browsePath": "Plant/Mixer/Speed"}`)
+	replaced := <-changes
+	checkVerbs(t, replaced, map[ua.NodeID]ua.ModelChangeStructureVerbMask{
+		speed:    ua.ModelChangeStructureVerbMaskNodeDeleted | ua.ModelChangeStructureVerbMaskNodeAdded | ua.ModelChangeStructureVerbMaskDataTypeChanged,
+		mixer:    ua.ModelChangeStructureVerbMaskReferenceDeleted | ua.ModelChangeStructureVerbMaskReferenceAdded,
+		controls: ua.ModelChangeStructureVerbMaskReferenceDeleted | ua.ModelChangeStructureVerbMaskReferenceAdded,
+	})
+	if variable, _ := nm.FindVariable(speed); variable.DataType() != ua.DataTypeIDDouble {
+		t.Errorf("replaced Speed DataType = %v, want Double", variable.DataType())
+	}
+
+	// Removing the last sensor of a folder deletes the emptied folder
+	callModelMethod(t, s, "RemoveSensor", "Speed")
+	removed := <-changes
+	checkVerbs(t, removed, map[ua.NodeID]ua.ModelChangeStructureVerbMask{
+		speed:                                ua.ModelChangeStructureVerbMaskNodeDeleted,
+		mixer:                                ua.ModelChangeStructureVerbMaskNodeDeleted | ua.ModelChangeStructureVerbMaskReferenceDeleted,
+		s.addrSpace.containerNodeID("Plant"): ua.ModelChangeStructureVerbMaskReferenceDeleted,
+		controls:                             ua.ModelChangeStructureVerbMaskReferenceDeleted,
+	})
+	for _, nodeID := range []ua.NodeID{speed, mixer} {
+		if _, exists := nm.FindNode(nodeID); exists {
+			t.Errorf("%s not deleted", nodeID)
+		}
+	}
+	if _, exists := nm.FindNode(tank1); !exists {
+		t.Error("folder of the remaining sensor deleted")
+	}
+	if _, exists := s.addrSpace.containers["Plant/Mixer"]; exists {
+		t.Error("Mixer folder still in the address space")
+	}
+}
+
+func TestModelChangesMerge(t *testing.T) {
+	parent := ua.NodeIDString{NamespaceIndex: 2, ID: "Folder"}
+	child := server.NewVariableNode(nil, ua.NodeIDString{NamespaceIndex: 2, ID: "Child"}, ua.QualifiedName{Name: "Child"},
+		ua.LocalizedText{}, ua.LocalizedText{}, nil,
+		[]ua.Reference{{ReferenceTypeID: ua.ReferenceTypeIDHasComponent, IsInverse: true, TargetID: ua.ExpandedNodeID{NodeID: parent}}},
+		ua.DataValue{}, ua.DataTypeIDDouble, ua.ValueRankScalar, nil, ua.AccessLevelsCurrentRead, 0, false, nil)
+	moved := server.NewVariableNode(nil, ua.NodeIDString{NamespaceIndex: 2, ID: "Child"}, ua.QualifiedName{Name: "Child"},
+		ua.LocalizedText{}, ua.LocalizedText{}, nil,
+		[]ua.Reference{{ReferenceTypeID: ua.ReferenceTypeIDHasComponent, IsInverse: true, TargetID: ua.ExpandedNodeID{NodeID: parent}}},
+		ua.DataValue{}, ua.DataTypeIDDouble, ua.ValueRankOneDimension, []uint32{0}, ua.AccessLevelsCurrentRead, 0, false, nil)
+
+	mc := newModelChanges()
+	mc.nodesDeleted([]server.Node{child})
+	mc.nodesAdded([]server.Node{moved}, variableTypes([]server.Node{child}))
+	if mc.len() != 2 || mc.order[0] != child.NodeID() || mc.order[1] != parent {
+		t.Fatalf("affected nodes = %v, want the child then its parent", mc.order)
+	}
+	// A changed ValueRank is a changed data type too
+	want := ua.ModelChangeStructureVerbMaskNodeDeleted | ua.ModelChangeStructureVerbMaskNodeAdded | ua.ModelChangeStructureVerbMaskDataTypeChanged
+	if got := mc.verbs[child.NodeID()]; got != want {
+		t.Errorf("child verb = %#x, want %#x", got, want)
+	}
+	structures := mc.structures()
+	if got := structures[1].(ua.ModelChangeStructureDataType); got.Affected != parent ||
+		got.Verb != uint8(ua.ModelChangeStructureVerbMaskReferenceDeleted|ua.ModelChangeStructureVerbMaskReferenceAdded) {
+		t.Errorf("parent change = %+v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package opcuaserver
+
+import (
+	"fmt"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// typesNamespaceURI is the namespace of the structured DataTypes (fixed, so encodings can be registered at init)
+const typesNamespaceURI = "urn:go-opcua-sim:types"
+
+// registerNamespaces registers the simulator, types and device namespaces in the server NamespaceArray.
+// Namespace indexes are looked up by URI and never assumed.
+func (s *OPCUAServer) registerNamespaces() {
+	nm := s.server.NamespaceManager()
+	s.namespace = nm.Add(s.namespaceURI)
+	s.typesNamespace = nm.Add(typesNamespaceURI)
+
+	paths := make([]string, 0, len(s.deviceNamespaces))
+	for path := range s.deviceNamespaces {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		nm.Add(s.deviceNamespaces[path])
+	}
+
+	for i, uri := range nm.NamespaceUris() {
+		log.Printf("[OPCUA] Namespace %d: %s", i, uri)
+	}
+}
+
+// pathNamespace returns the namespace index of a browse path: the namespace of the closest
+// folder/device with a device namespace, or the simulator namespace
+func (s *OPCUAServer) pathNamespace(path string) uint16 {
+	best := ""
+	for device := range s.deviceNamespaces {
+		if (path == device || strings.HasPrefix(path, device+"/")) && len(device) > len(best) {
+			best = device
+		}
+	}
+	if best == "" {
+		return s.namespace
+	}
+	return s.server.NamespaceManager().Add(s.deviceNamespaces[best])
+}
+
+// tagNamespace returns the namespace index of a tag node and its properties
+func (s *OPCUAServer) tagNamespace(tag *plc.Tag) uint16 {
+	if tag.Namespace != "" {
+		return s.server.NamespaceManager().Add(tag.Namespace)
+	}
+	return s.pathNamespace(tag.BrowsePath)
+}
+
+// tagNodeID returns the NodeId of a tag: its explicit NodeId or s=<TagName> in the tag namespace
+func (s *OPCUAServer) tagNodeID(tag *plc.Tag) (ua.NodeID, error) {
+	ns := s.tagNamespace(tag)
+	if tag.NodeID == "" {
+		return ua.NodeIDString{NamespaceIndex: ns, ID: tag.Name}, nil
+	}
+
+	nodeID := ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", ns, tag.NodeID))
+	if nodeID == nil {
+		return nil, fmt.Errorf("tag '%s' has invalid NodeId %s", tag.Name, tag.NodeID)
+	}
+	return nodeID, nil
+}
+
+// aliasNodeID resolves an alias of a tag. Aliases without namespace are in the simulator namespace,
+// namespace URIs (nsu=) are registered in the NamespaceArray.
+func (s *OPCUAServer) aliasNodeID(tag *plc.Tag, alias string) (ua.NodeID, error) {
+	nm := s.server.NamespaceManager()
+
+	var nodeID ua.NodeID
+	switch {
+	case strings.HasPrefix(alias, "nsu="):
+		expanded := ua.ParseExpandedNodeID(alias)
+		nm.Add(expanded.NamespaceURI)
+		nodeID = ua.ToNodeID(expanded, nm.NamespaceUris())
+	case strings.HasPrefix(alias, "ns="):
+		nodeID = ua.ParseNodeID(alias)
+	default:
+		nodeID = ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", s.namespace, alias))
+	}
+
+	if nodeID == nil {
+		return nil, fmt.Errorf("tag '%s' has invalid alias %s", tag.Name, alias)
+	}
+	if ns := namespaceIndex(nodeID); int(ns) >= nm.Len() {
+		return nil, fmt.Errorf("tag '%s' has alias %s in unknown namespace %d", tag.Name, alias, ns)
+	}
+	return nodeID, nil
+}
+
+// namespaceIndex returns the namespace index of a NodeId
+func namespaceIndex(nodeID ua.NodeID) uint16 {
+	switch id := nodeID.(type) {
+	case ua.NodeIDNumeric:
+		return id.NamespaceIndex
+	case ua.NodeIDString:
+		return id.NamespaceIndex
+	case ua.NodeIDGUID:
+		return id.NamespaceIndex
+	case ua.NodeIDOpaque:
+		return id.NamespaceIndex
+	}
+	return 0
+}
+
+// nodeIdentifier returns the identifier of a NodeId without namespace (the tag name for string NodeIds)
+func nodeIdentifier(nodeID ua.NodeID) string {
+	if id, ok := nodeID.(ua.NodeIDString); ok {
+		return id.ID
+	}
+	text := fmt.Sprint(nodeID)
+	if i := strings.Index(text, ";"); i >= 0 && strings.HasPrefix(text, "ns=") {
+		return text[i+1:]
+	}
+	return text
+}
+
+// checkNodeIDs reports NodeIds that are used twice or already exist in the server,
+// e.g. an explicit NodeId or alias that collides with another tag. Tag variables bound to NodeSet variables replace them.
+func (s *OPCUAServer) checkNodeIDs(nodes []server.Node) error {
+	nm := s.server.NamespaceManager()
+	seen := make(map[ua.NodeID]string, len(nodes))
+	for _, node := range nodes {
+		id := node.NodeID()
+		if other, exists := seen[id]; exists {
+			return fmt.Errorf("duplicate NodeId %s (%s and %s)", id, other, node.BrowseName().Name)
+		}
+		if _, exists := nm.FindNode(id); exists && !s.isBound(node) {
+			return fmt.Errorf("NodeId %s of %s already exists in the server", id, node.BrowseName().Name)
+		}
+		seen[id] = node.BrowseName().Name
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package opcuaserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go-opcua-sim/internal/plc"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// loadNodeSets imports the NodeSet2 XML files into the address space and records their variables,
+// which sensors with the same NodeId are bound to
+func (s *OPCUAServer) loadNodeSets() error {
+	nm := s.server.NamespaceManager()
+
+	var loaded []ua.NodeID
+	for _, path := range s.nodeSets {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read NodeSet: %w", err)
+		}
+		set := &ua.UANodeSet{}
+		if err := xml.Unmarshal(buf, set); err != nil {
+			return fmt.Errorf("failed to parse NodeSet %s: %w", path, err)
+		}
+		if err := nm.LoadNodeSetFromBuffer(buf); err != nil {
+			return fmt.Errorf("failed to load NodeSet %s: %w", path, err)
+		}
+
+		aliases := make(map[string]string, len(set.Aliases))
+		for _, alias := range set.Aliases {
+			aliases[alias.Alias] = strings.TrimSpace(alias.NodeID)
+		}
+
+		variables := 0
+		for _, node := range set.Nodes {
+			nodeID := nodeSetNodeID(nm, set.NamespaceUris, aliases, node.NodeID)
+			loaded = append(loaded, nodeID)
+			if node.XMLName.Local != "UAVariable" {
+				continue
+			}
+			if variable, ok := nm.FindVariable(nodeID); ok {
+				s.imported[nodeID] = variable
+				variables++
+			}
+		}
+		log.Printf("[OPCUA] Loaded NodeSet %s: %d nodes, %d variables", path, len(set.Nodes), variables)
+	}
+
+	dropDanglingReferences(nm, loaded)
+	return nil
+}
+
+// dropDanglingReferences removes references to nodes missing in all NodeSets,
+// otherwise browsing the source node fails with Bad_NodeIdUnknown
+func dropDanglingReferences(nm *server.NamespaceManager, nodeIDs []ua.NodeID) {
+	for _, nodeID := range nodeIDs {
+		node, ok := nm.FindNode(nodeID)
+		if !ok {
+			continue
+		}
+		refs := node.References()
+		kept := make([]ua.Reference, 0, len(refs))
+		for _, ref := range refs {
+			if _, exists := nm.FindNode(ua.ToNodeID(ref.TargetID, nm.NamespaceUris())); !exists {
+				log.Printf("[OPCUA] NodeSet node %s references missing node %s, reference dropped", nodeID, ref.TargetID)
+				continue
+			}
+			kept = append(kept, ref)
+		}
+		if len(kept) != len(refs) {
+			node.SetReferences(kept)
+		}
+	}
+}
+
+// nodeSetNodeID resolves a NodeId of a NodeSet, whose namespace indexes refer to the NodeSet NamespaceUris
+func nodeSetNodeID(nm *server.NamespaceManager, uris []string, aliases map[string]string, text string) ua.NodeID {
+	if alias, ok := aliases[text]; ok {
+		text = alias
+	}
+	if !strings.HasPrefix(text, "ns=") {
+		return ua.ParseNodeID(text)
+	}
+
+	i := strings.Index(text, ";")
+	if i < 0 {
+		return nil
+	}
+	index, err := strconv.Atoi(text[3:i])
+	if err != nil || index < 1 || index > len(uris) {
+		return ua.ParseNodeID(text)
+	}
+	return ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", nm.Add(uris[index-1]), text[i+1:]))
+}
+
+// placedTags returns the tags placed by their browse path, i.e. all tags not bound to a NodeSet variable
+func (s *OPCUAServer) placedTags(tags []*plc.Tag) []*plc.Tag {
+	if len(s.imported) == 0 {
+		return tags
+	}
+
+	placed := make([]*plc.Tag, 0, len(tags))
+	for _, tag := range tags {
+		if nodeID, err := s.tagNodeID(tag); err == nil && s.imported[nodeID] != nil {
+			continue
+		}
+		placed = append(placed, tag)
+	}
+	return placed
+}
+
+// bindVariable creates the variable of a tag that replaces a variable imported from a NodeSet.
+// The NodeId, names, references and access level of the NodeSet are kept (without CurrentWrite for read-only
+// tags, localized names of the tag replace the NodeSet ones), the sampling interval is raised to the sensor
+// update interval.
+func (s *OPCUAServer) bindVariable(tag *plc.Tag, imported *server.VariableNode, value ua.DataValue,
+	valueRank int32, arrayDimensions []uint32, historian server.HistoryReadWriter) (*server.VariableNode, error) {
+	dataType := tagDataType(tag.Type)
+	if imported.DataType() != dataType || imported.ValueRank() != valueRank {
+		return nil, fmt.Errorf("tag '%s' (%s, ValueRank %d) does not match NodeSet variable %s (DataType %s, ValueRank %d), set dataType/arrayLength",
+			tag.Name, tag.Type.DataTypeName(), valueRank, imported.NodeID(), imported.DataType(), imported.ValueRank())
+	}
+
+	rolePermissions := s.tagRolePermissions(tag)
+	if rolePermissions == nil {
+		rolePermissions = imported.RolePermissions()
+	}
+	displayName, description := imported.DisplayName(), imported.Description()
+	if len(tag.DisplayNames) > 0 {
+		displayName = s.tagDisplayName(tag, "")
+	}
+	if len(tag.Descriptions) > 0 {
+		description = s.tagDescription(tag)
+	}
+	accessLevel := imported.AccessLevel()
+	if tag.ReadOnly {
+		accessLevel &^= ua.AccessLevelsCurrentWrite
+	}
+	if historian != nil {
+		accessLevel |= ua.AccessLevelsHistoryRead
+	}
+
+	varNode := server.NewVariableNode(
+		s.server,
+		imported.NodeID(),
+		imported.BrowseName(),
+		displayName,
+		description,
+		rolePermissions,
+		append([]ua.Reference(nil), imported.References()...),
+		value,
+		dataType,
+		valueRank,
+		arrayDimensions,
+		accessLevel,
+		max(imported.MinimumSamplingInterval(), minimumSamplingInterval(tag)),
+		historian != nil,
+		historian,
+	)
+	s.bound[imported.NodeID()] = varNode
+	return varNode, nil
+}
+
+// unbindVariable puts back the NodeSet variable replaced by a tag variable (caller holds the lock)
+func (s *OPCUAServer) unbindVariable(nodeID ua.NodeID) bool {
+	if _, bound := s.bound[nodeID]; !bound {
+		return false
+	}
+	delete(s.bound, nodeID)
+	s.server.NamespaceManager().AddNode(s.imported[nodeID])
+	return true
+}
+
+// isBound reports whether a node is the tag variable bound to a NodeSet variable
+func (s *OPCUAServer) isBound(node server.Node) bool {
+	varNode, ok := node.(*server.VariableNode)
+	return ok && s.bound[node.NodeID()] == varNode
+}
@@ -0,0 +1,137 @@
+package opcuaserver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go-opcua-sim/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// testNodeSetPath writes a NodeSet with one Double variable under the Objects folder
+func testNodeSetPath(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.NodeSet2.xml")
+	err := os.WriteFile(path, []byte(`<?xml version="1.0" encoding="utf-8"?>
+<UANodeSet xmlns="http://opcfoundation.org/UA/2011/03/UANodeSet.xsd">
+  <NamespaceUris><Uri>urn:test:vendor</Uri></NamespaceUris>
+  <Aliases><Alias Alias="Double">i=11</Alias><Alias Alias="Organizes">i=35</Alias><Alias Alias="HasTypeDefinition">i=40</Alias></Aliases>
+  <UAVariable NodeId="ns=1;s=Pump.Flow" BrowseName="1:Flow" DataType="Double" AccessLevel="3">
+    <DisplayName>Vendor Flow</DisplayName>
+    <References>
+      <Reference ReferenceType="Organizes" IsForward="false">i=85</Reference>
+      <Reference ReferenceType="HasTypeDefinition">i=63</Reference>
+    </References>
+  </UAVariable>
+</UANodeSet>
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// boundFlow is a sensor bound to the variable of the test NodeSet
+var boundFlow = config.SensorDefinition{
+	Name: "Flow", Type: "memory", Enabled: true, Address: "%DF0", UpdateIntervalMs: 100,
+	NamespaceURI: "urn:test:vendor", NodeID: "s=Pump.Flow",
+	Parameters: map[string]interface{}{"initialValue": 12.5},
+}
+
+func TestNodeSetBinding(t *testing.T) {
+	s, changes := modelTestServer(t, Config{NodeSets: []string{testNodeSetPath(t)}}, boundFlow)
+	nm := s.server.NamespaceManager()
+	flow := ua.NodeIDString{NamespaceIndex: nm.Add("urn:test:vendor"), ID: "Pump.Flow"}
+	imported := s.imported[flow]
+	if imported == nil {
+		t.Fatal("NodeSet variable not imported")
+	}
+
+	// The tag variable replaces the NodeSet variable with its NodeId, names and references
+	bound, ok := nm.FindVariable(flow)
+	if !ok || bound == imported || bound != s.bound[flow] {
+		t.Fatal("NodeSet variable not bound to the tag")
+	}
+	if bound.DisplayName().Text != "Vendor Flow" || len(bound.References()) != len(imported.References()) {
+		t.Errorf("bound variable %q with %d references, want the NodeSet names and references", bound.DisplayName().Text, len(bound.References()))
+	}
+	if len(s.addrSpace.containers) != 0 {
+		t.Errorf("bound tag placed in folders %v", s.addrSpace.containers)
+	}
+
+	// Removing the sensor puts the NodeSet variable back
+	callModelMethod(t, s, "RemoveSensor", "Flow")
+	removed := <-changes
+	if restored, _ := nm.FindVariable(flow); restored != imported {
+		t.Error("NodeSet variable not restored")
+	}
+	if _, affected := removed.verbs[flow]; affected {
+		t.Error("restored NodeSet variable reported as a model change")
+	}
+
+	// A tag of another data type cannot be bound
+	tag := analogTestServer(t, config.SensorDefinition{Name: "Count", Type: "memory", Enabled: true, Address: "%DW0", UpdateIntervalMs: 100})
+	count, _ := tag.tagManager.GetTag("Count")
+	if _, err := s.bindVariable(count, imported, ua.DataValue{}, ua.ValueRankScalar, nil, nil); err == nil {
+		t.Error("Int32 tag bound to a Double variable")
+	}
+}
+
+func TestExportNodeSet(t *testing.T) {
+	level := config.SensorDefinition{
+		Name: "Level", Type: "memory", Enabled: true, Address: "%DF2", UpdateIntervalMs: 100, BrowsePath: "Plant/Tank1/Level",
+		NodeID: "i=1000", Aliases: []string{"s=Tank1.Level"}, EURange: &config.Range{Low: 0, High: 10},
+	}
+	s, _ := modelTestServer(t, Config{NodeSets: []string{testNodeSetPath(t)}}, level, boundFlow)
+	for _, tag := range s.tagManager.GetAllTags() {
+		s.publishTag(tag)
+	}
+
+	var buf bytes.Buffer
+	if err := s.ExportNodeSet(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var set ua.UANodeSet
+	if err := xml.Unmarshal(buf.Bytes(), &set); err != nil {
+		t.Fatalf("parse export: %v", err)
+	}
+	ns := s.namespace
+	exported := make(map[string]string)
+	for _, node := range set.Nodes {
+		exported[node.NodeID] = node.XMLName.Local
+	}
+	vendor := ua.NodeIDString{NamespaceIndex: s.server.NamespaceManager().Add("urn:test:vendor"), ID: "Pump.Flow"}
+	for nodeID, class := range map[ua.NodeID]string{
+		vendor: "UAVariable",
+		ua.NodeIDNumeric{NamespaceIndex: ns, ID: 1000}:                "UAVariable",
+		ua.NodeIDString{NamespaceIndex: ns, ID: "Tank1.Level"}:        "UAVariable",
+		ua.NodeIDString{NamespaceIndex: ns, ID: "Level.EURange"}:      "UAVariable",
+		s.addrSpace.containerNodeID("Plant"):                          "UAObject",
+		ua.NodeIDString{NamespaceIndex: ns, ID: config.ControlFolder}: "UAObject",
+	} {
+		if exported[nodeIDString(nodeID)] != class {
+			t.Errorf("%s exported as %q, want %s", nodeID, exported[nodeIDString(nodeID)], class)
+		}
+	}
+
+	// Another server loads the export with the current values
+	security := SecurityConfig{PKIDir: t.TempDir()}
+	other := newLibraryServer(t, security)
+	if err := other.NamespaceManager().LoadNodeSetFromBuffer(buf.Bytes()); err != nil {
+		t.Fatalf("load export: %v", err)
+	}
+	otherNS := other.NamespaceManager().Add(config.DefaultNamespaceURI)
+	variable, ok := other.NamespaceManager().FindVariable(ua.NodeIDNumeric{NamespaceIndex: otherNS, ID: 1000})
+	if !ok {
+		t.Fatal("tag variable missing in the loaded export")
+	}
+	if variable.DataType() != ua.DataTypeIDDouble {
+		t.Errorf("loaded DataType = %v, want Double", variable.DataType())
+	}
+	if flow, ok := other.NamespaceManager().FindVariable(ua.NodeIDString{NamespaceIndex: other.NamespaceManager().Add("urn:test:vendor"), ID: "Pump.Flow"}); !ok || flow.Value().Value != 12.5 {
+		t.Errorf("loaded bound variable value = %v, want 12.5", flow)
+	}
+}
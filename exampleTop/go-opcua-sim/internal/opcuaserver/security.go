@@ -0,0 +1,73 @@
+package opcuaserver
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// SecurityConfig configures the None endpoint and the certificate store.
+// The library always offers Basic128Rsa15, Basic256, Basic256Sha256, Aes128_Sha256_RsaOaep and
+// Aes256_Sha256_RsaPss in Sign and SignAndEncrypt mode, only the None policy can be turned off.
+type SecurityConfig struct {
+	AllowNone bool   // Offer the None security policy besides the secure endpoints
+	PKIDir    string // Certificate store root (server.crt/key, trusted/, issuers/, rejected/)
+	Strict    bool   // Reject client certificates that are not trusted
+}
+
+// DefaultSecurityConfig returns the default security settings
+// (all endpoints offered, every client certificate accepted)
+func DefaultSecurityConfig() SecurityConfig {
+	return SecurityConfig{
+		AllowNone: true,
+		PKIDir:    "./pki",
+		Strict:    false,
+	}
+}
+
+// certificatePaths returns the server certificate and key locations
+func (c SecurityConfig) certificatePaths() (string, string) {
+	return filepath.Join(c.PKIDir, "server.crt"), filepath.Join(c.PKIDir, "server.key")
+}
+
+// serverOptions returns the endpoint and certificate store options for the server.
+// The store uses the same layout as devOpcua: trusted/{certs,crl}, issuers/{certs,crl}, rejected.
+func (c SecurityConfig) serverOptions() []server.Option {
+	opts := []server.Option{
+		server.WithSecurityPolicyNone(c.AllowNone),
+		server.WithTrustedCertificatesPaths(
+			filepath.Join(c.PKIDir, "trusted", "certs"),
+			filepath.Join(c.PKIDir, "trusted", "crl"),
+		),
+		server.WithIssuerCertificatesPaths(
+			filepath.Join(c.PKIDir, "issuers", "certs"),
+			filepath.Join(c.PKIDir, "issuers", "crl"),
+		),
+		server.WithRejectedCertificatesPath(filepath.Join(c.PKIDir, "rejected")),
+	}
+	if !c.Strict {
+		opts = append(opts, server.WithInsecureSkipVerify())
+	}
+	return opts
+}
+
+// String returns a short description for logging
+func (c SecurityConfig) String() string {
+	return fmt.Sprintf("none=%t pki=%s strict=%t", c.AllowNone, c.PKIDir, c.Strict)
+}
+
+// securityModeName returns a readable name for a message security mode
+func securityModeName(mode ua.MessageSecurityMode) string {
+	switch mode {
+	case ua.MessageSecurityModeNone:
+		return "None"
+	case ua.MessageSecurityModeSign:
+		return "Sign"
+	case ua.MessageSecurityModeSignAndEncrypt:
+		return "SignAndEncrypt"
+	default:
+		return "Invalid"
+	}
+}
@@ -3,8 +3,14 @@ package opcuaserver
 import (
 	"context"
 	"fmt"
+	"go-opcua-sim/internal/config"
 	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim"
+	"go-opcua-sim/internal/sim/sensors"
 	"log"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,25 +18,104 @@ import (
 	"github.com/awcullen/opcua/ua"
 )
 
+// Config holds the OPC UA server settings
+type Config struct {
+	Endpoint       string               // Endpoint URL (e.g. opc.tcp://0.0.0.0:4840)
+	Security       SecurityConfig       // None endpoint and certificate store
+	Users          *config.UserConfig   // User credentials, nil = anonymous access only
+	Anonymous      bool                 // Allow anonymous sessions
+	AnonymousWrite bool                 // Grant anonymous sessions write and call access, default browse and read only
+	History        int                  // Values kept per tag for HistoryRead, 0 = history disabled
+	Reload         func() error         // Reloads the sensor configuration (ReloadConfig method), nil = not offered
+	NodeSets       []string             // NodeSet2 XML files imported into the address space
+	ExportTo       string               // NodeSet2 XML file the address space is exported to at startup and after model changes
+	Chaos          *ChaosConfig         // Fault injection between clients and server, nil = disabled
+	Limits         *config.ServerLimits // Session, subscription, operation and message limits, nil = server defaults
+	Locales        []string             // Locales of the localized names in order of preference, empty = "en"
+
+	NamespaceURI     string            // Namespace of the simulator nodes, empty = config.DefaultNamespaceURI
+	DeviceNamespaces map[string]string // Folder/device browse path -> namespace of it and all nodes below
+}
+
 // OPCUAServer wraps the awcullen OPC UA server
 type OPCUAServer struct {
-	endpoint    string
-	tagManager  *plc.TagManager
-	ctx         context.Context
-	cancel      context.CancelFunc
-	nodeMapping map[string]string // tag name -> node ID string
-	server      *server.Server
-	mu          sync.RWMutex
-	running     bool
+	endpoint      string
+	security      SecurityConfig
+	users         *config.UserConfig
+	anonymous     bool
+	permissions   []ua.RolePermissionType // server default role permissions
+	tagManager    *plc.TagManager
+	sensorManager *sim.SensorManager
+	ctx           context.Context
+	cancel        context.CancelFunc
+	nodeMapping   map[string]ua.NodeID              // tag name -> node ID
+	tagNodes      map[string][]*server.VariableNode // tag name -> variable node and aliases, guarded by publishMu
+	alarms        map[string]*limitAlarm            // tag name -> limit alarm condition
+	stateNodes    map[string]ua.NodeID              // motor tag name -> MotorState variable
+	deadbands     map[string]float64                // analog tag name -> absolute publish deadband, guarded by publishMu
+	historian     *historian                        // nil when history is disabled
+	modelNodes    map[string][]ua.NodeID            // tag name -> nodes created for the tag, deleted with it
+	addrSpace     *addressSpace                     // folders and device objects of the current tags
+	reload        func() error
+	nodeSets      []string
+	exportPath    string
+	chaosConfig   *ChaosConfig
+	chaos         *chaos                             // nil when fault injection is disabled
+	limits        config.ServerLimits                // zero fields = server defaults
+	locales       []string                           // LocaleIdArray, preferred locale first
+	imported      map[ua.NodeID]*server.VariableNode // variables loaded from the NodeSets
+	bound         map[ua.NodeID]*server.VariableNode // NodeSet variable -> tag variable that replaces it
+	server        *server.Server
+	mu            sync.RWMutex
+	publishMu     sync.Mutex // orders tag changes pushed to the variable nodes
+	running       bool
+
+	namespaceURI     string            // namespace of the simulator nodes
+	deviceNamespaces map[string]string // browse path -> namespace URI
+	namespace        uint16            // namespace index of namespaceURI
+	typesNamespace   uint16            // namespace index of typesNamespaceURI
 }
 
 // NewOPCUAServer creates a new OPC UA server
-func NewOPCUAServer(endpoint string, tagManager *plc.TagManager) *OPCUAServer {
-	return &OPCUAServer{
-		endpoint:    endpoint,
-		tagManager:  tagManager,
-		nodeMapping: make(map[string]string),
+func NewOPCUAServer(cfg Config, tagManager *plc.TagManager, sensorManager *sim.SensorManager) *OPCUAServer {
+	s := &OPCUAServer{
+		endpoint:      cfg.Endpoint,
+		security:      cfg.Security,
+		users:         cfg.Users,
+		anonymous:     cfg.Anonymous,
+		permissions:   serverRolePermissions(cfg.AnonymousWrite),
+		tagManager:    tagManager,
+		sensorManager: sensorManager,
+		nodeMapping:   make(map[string]ua.NodeID),
+		tagNodes:      make(map[string][]*server.VariableNode),
+		alarms:        make(map[string]*limitAlarm),
+		stateNodes:    make(map[string]ua.NodeID),
+		deadbands:     make(map[string]float64),
+		modelNodes:    make(map[string][]ua.NodeID),
+		reload:        cfg.Reload,
+		nodeSets:      cfg.NodeSets,
+		exportPath:    cfg.ExportTo,
+		chaosConfig:   cfg.Chaos,
+		locales:       cfg.Locales,
+		imported:      make(map[ua.NodeID]*server.VariableNode),
+		bound:         make(map[ua.NodeID]*server.VariableNode),
+
+		namespaceURI:     cfg.NamespaceURI,
+		deviceNamespaces: cfg.DeviceNamespaces,
+	}
+	if s.namespaceURI == "" {
+		s.namespaceURI = config.DefaultNamespaceURI
+	}
+	if cfg.Limits != nil {
+		s.limits = *cfg.Limits
+	}
+	if len(s.locales) == 0 {
+		s.locales = []string{defaultLocale}
 	}
+	if cfg.History > 0 {
+		s.historian = newHistorian(cfg.History)
+	}
+	return s
 }
 
 // Start starts the OPC UA server
@@ -40,7 +125,45 @@ func (s *OPCUAServer) Start(ctx context.Context) error {
 	log.Printf("[OPCUA] Starting OPC UA server at %s", s.endpoint)
 	log.Printf("[OPCUA] Available tags: %d", s.tagManager.GetTagCount())
 
+	log.Printf("[OPCUA] Security: %s", s.security)
+
+	opts := []server.Option{
+		server.WithBuildInfo(ua.BuildInfo{
+			ProductName:      "Go OPC UA Simulator",
+			SoftwareVersion:  "1.0.0",
+			ManufacturerName: "go-opcua-sim",
+		}),
+		server.WithAnonymousIdentity(s.anonymous),
+		server.WithRolePermissions(s.permissions),
+	}
+	opts = append(opts, s.limitOptions()...)
+	opts = append(opts, s.security.serverOptions()...)
+	var auth *userAuthenticator
+	if s.users != nil {
+		auth = newUserAuthenticator(s.users)
+		opts = append(opts, auth.serverOptions()...)
+		log.Printf("[OPCUA] User authentication: %d users, %d certificates (anonymous: %t)",
+			len(s.users.Users), len(s.users.Certificates), s.anonymous)
+	}
+	if s.historian != nil {
+		opts = append(opts, server.WithHistorian(s.historian))
+		log.Printf("[OPCUA] History: %d values per tag", s.historian.depth)
+	}
+
+	// With fault injection the server listens on an internal port behind the chaos proxy.
+	// Its endpoint descriptions carry the internal URL, clients keep using the configured endpoint.
+	listenEndpoint := s.endpoint
+	if s.chaosConfig != nil {
+		c, internal, err := newChaos(*s.chaosConfig, s.endpoint, s.sensorManager)
+		if err != nil {
+			return fmt.Errorf("failed to set up fault injection: %v", err)
+		}
+		s.chaos, listenEndpoint = c, internal
+		opts = append(opts, c.identityOptions(s.anonymous, auth)...)
+	}
+
 	// Create server instance
+	certPath, keyPath := s.security.certificatePaths()
 	srv, err := server.New(
 		ua.ApplicationDescription{
 			ApplicationURI: "urn:go-opcua-sim",
@@ -50,30 +173,48 @@ func (s *OPCUAServer) Start(ctx context.Context) error {
 				Locale: "en",
 			},
 			ApplicationType: ua.ApplicationTypeServer,
+			DiscoveryURLs:   []string{s.endpoint},
 		},
-		"./pki/server.crt",
-		"./pki/server.key",
-		s.endpoint,
-		server.WithBuildInfo(ua.BuildInfo{
-			ProductName:      "Go OPC UA Simulator",
-			SoftwareVersion:  "1.0.0",
-			ManufacturerName: "go-opcua-sim",
-		}),
-		server.WithAnonymousIdentity(true),
-		server.WithSecurityPolicyNone(true),
-		server.WithInsecureSkipVerify(),
+		certPath,
+		keyPath,
+		listenEndpoint,
+		opts...,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create OPC UA server: %v", err)
 	}
 	s.server = srv
 
+	if s.historian != nil {
+		s.advertiseAggregates()
+	}
+	s.advertiseLimits()
+	s.logLimits()
+
+	// Log the offered endpoints
+	for _, ep := range srv.Endpoints() {
+		log.Printf("[OPCUA] Endpoint: %s [%s]", ep.SecurityPolicyURI, securityModeName(ep.SecurityMode))
+	}
+
+	s.registerNamespaces()
+
 	// Register all tag nodes
 	if err := s.registerNodes(); err != nil {
 		return fmt.Errorf("failed to register nodes: %v", err)
 	}
 
-	// Start update goroutine to sync tag values to OPC UA nodes
+	// Alarm acknowledge/confirm and condition refresh
+	s.registerConditionMethods()
+
+	// Push tag changes to the variable nodes as they happen, create and delete nodes of tags changed at runtime
+	s.tagManager.AddChangeListener(s.publishTag)
+	s.tagManager.AddModelListener(s.handleModelChange)
+	for _, tag := range s.tagManager.GetAllTags() {
+		s.publishTag(tag) // changes made while the nodes were built
+	}
+	s.exportNodeSetFile()
+
+	// Start update goroutine for motor states and deadbands
 	go s.updateNodeValues()
 
 	// Start the server
@@ -89,6 +230,12 @@ func (s *OPCUAServer) Start(ctx context.Context) error {
 		}
 	}()
 
+	if s.chaos != nil {
+		if err := s.chaos.start(s.ctx, s.server); err != nil {
+			return fmt.Errorf("failed to start fault injection: %v", err)
+		}
+	}
+
 	// Wait for context cancellation
 	<-s.ctx.Done()
 	return nil
@@ -103,107 +250,335 @@ func (s *OPCUAServer) registerNodes() error {
 	fmt.Printf("%-40s %-50s %s\n", "Tag Name", "NodeID", "Data Type")
 	fmt.Println("---------------------------------------------------------------------------------------------------")
 
-	// Find or get the Objects folder (standard namespace 0, id 85)
-	objectsFolderNodeID := ua.ParseNodeID("i=85")
+	// Vendor address spaces, their variables can be bound to sensors
+	if err := s.loadNodeSets(); err != nil {
+		return err
+	}
 
-	var nodesToAdd []server.Node
+	// Build folders and device objects from the browse paths of the tags not bound to NodeSet variables
+	addrSpace := newAddressSpace(s.placedTags(tags), s.pathNamespace)
+	nodesToAdd := append(s.dataTypeNodes(), addrSpace.nodes(s.server)...)
+	s.addrSpace = addrSpace
 
 	for _, tag := range tags {
-		// Use tag name as string identifier
-		nodeIDString := tag.Name
-		s.nodeMapping[tag.Name] = nodeIDString
-
-		// Determine OPC UA data type and initial value
-		var dataType ua.NodeID
-		var initialValue ua.DataValue
-
-		switch tag.Type {
-		case plc.TagTypeBool:
-			dataType = ua.DataTypeIDBoolean
-			v, _ := s.tagManager.GetTagValue(tag.Name)
-			if boolVal, ok := v.(bool); ok {
-				initialValue = ua.NewDataValue(boolVal, 0, time.Now(), 0, time.Now(), 0)
-			} else {
-				initialValue = ua.NewDataValue(false, 0, time.Now(), 0, time.Now(), 0)
-			}
+		tagNodes, err := s.tagNodeSet(tag, addrSpace)
+		if err != nil {
+			return err
+		}
+		nodesToAdd = append(nodesToAdd, tagNodes...)
+	}
 
-		case plc.TagTypeInt32:
-			dataType = ua.DataTypeIDInt32
-			v, _ := s.tagManager.GetTagValue(tag.Name)
-			if intVal, ok := v.(int32); ok {
-				initialValue = ua.NewDataValue(intVal, 0, time.Now(), 0, time.Now(), 0)
-			} else {
-				initialValue = ua.NewDataValue(int32(0), 0, time.Now(), 0, time.Now(), 0)
-			}
+	// Control objects with Method nodes for each sensor
+	if s.sensorManager != nil {
+		nodesToAdd = append(nodesToAdd, s.controlNodes(tags)...)
+	}
 
-		case plc.TagTypeFloat64:
-			dataType = ua.DataTypeIDDouble
-			v, _ := s.tagManager.GetTagValue(tag.Name)
-			if floatVal, ok := v.(float64); ok {
-				initialValue = ua.NewDataValue(floatVal, 0, time.Now(), 0, time.Now(), 0)
-			} else {
-				initialValue = ua.NewDataValue(float64(0.0), 0, time.Now(), 0, time.Now(), 0)
-			}
+	// Add all nodes at once
+	if err := s.checkNodeIDs(nodesToAdd); err != nil {
+		return err
+	}
+	nm.AddNodes(nodesToAdd...)
+
+	if n := len(addrSpace.containers); n > 0 {
+		fmt.Printf("\n%d folders/objects created from browse paths\n", n)
+	}
+	fmt.Println()
+	return nil
+}
+
+// tagNodeSet creates the variable node of a tag with its aliases, analog properties and motor state node
+func (s *OPCUAServer) tagNodeSet(tag *plc.Tag, addrSpace *addressSpace) ([]server.Node, error) {
+	var nodesToAdd []server.Node
+
+	// Tag name as string identifier unless the tag has an explicit NodeId
+	nodeID, err := s.tagNodeID(tag)
+	if err != nil {
+		return nil, err
+	}
+	s.nodeMapping[tag.Name] = nodeID
+
+	// Determine OPC UA data type and initial value
+	dataType := tagDataType(tag.Type)
+	v, quality, timestamp := tag.Sample()
+	initialValue := ua.NewDataValue(tagVariant(v), ua.StatusCode(quality), timestamp, 0, time.Now(), 0)
+
+	// Array tags are one-dimensional arrays of the element type
+	valueRank := ua.ValueRankScalar
+	arrayDimensions := []uint32{}
+	if tag.IsArray() {
+		valueRank = ua.ValueRankOneDimension
+		arrayDimensions = []uint32{uint32(tag.Length)}
+	}
+
+	// Place the node under its folder/device (or the Objects folder)
+	browseName, parentNodeID, parentRefType := addrSpace.tagPlacement(tag)
+	ns := s.tagNamespace(tag)
+
+	// Scalar tag value changes are recorded when history is enabled
+	accessLevel := tagAccessLevel(tag)
+	var historian server.HistoryReadWriter
+	if s.historian != nil && !tag.IsArray() {
+		accessLevel |= ua.AccessLevelsHistoryRead
+		historian = s.historian
+		s.historian.register(nodeID)
+	}
 
-		default:
-			dataType = ua.DataTypeIDDouble
-			initialValue = ua.NewDataValue(float64(0.0), 0, time.Now(), 0, time.Now(), 0)
+	// Variables imported from a NodeSet are replaced in place by the tag variable
+	var deadband float64
+	imported, bound := s.imported[nodeID]
+	var varNode *server.VariableNode
+	if bound {
+		if varNode, err = s.bindVariable(tag, imported, initialValue, valueRank, arrayDimensions, historian); err != nil {
+			return nil, err
+		}
+	} else {
+		// Analog tags with a known range are AnalogItemType nodes with range and unit properties
+		typeDefinition := ua.VariableTypeIDBaseDataVariableType
+		if euRange, instrumentRange, ok := s.analogRanges(tag); ok {
+			typeDefinition = ua.VariableTypeIDAnalogItemType
+			nodesToAdd = append(nodesToAdd, s.analogProperties(tag, nodeID, euRange, instrumentRange)...)
+			deadband = tag.Deadband / 100 * (euRange.High - euRange.Low)
 		}
 
-		// Create variable node with string identifier
-		varNode := server.NewVariableNode(
+		varNode = server.NewVariableNode(
 			s.server,
-			ua.NodeIDString{NamespaceIndex: 2, ID: nodeIDString},
+			nodeID,
 			ua.QualifiedName{
-				NamespaceIndex: 2,
-				Name:           tag.Name,
-			},
-			ua.LocalizedText{
-				Text: tag.Name,
+				NamespaceIndex: ns,
+				Name:           browseName,
 			},
-			ua.LocalizedText{
-				Text: tag.Description,
-			},
-			nil,
+			s.tagDisplayName(tag, browseName),
+			s.tagDescription(tag),
+			s.tagRolePermissions(tag),
 			[]ua.Reference{
 				{
-					ReferenceTypeID: ua.ReferenceTypeIDOrganizes,
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: typeDefinition},
+				},
+				{
+					ReferenceTypeID: parentRefType,
 					IsInverse:       true,
-					TargetID:        ua.ExpandedNodeID{NodeID: objectsFolderNodeID},
+					TargetID:        ua.ExpandedNodeID{NodeID: parentNodeID},
+				},
+			},
+			initialValue,
+			dataType,
+			valueRank,
+			arrayDimensions,
+			accessLevel,
+			minimumSamplingInterval(tag),
+			historian != nil,
+			historian,
+		)
+	}
+	varNode.SetWriteValueHandler(s.newWriteHandler(tag.Name))
+
+	nodesToAdd = append(nodesToAdd, varNode)
+	varNodes := []*server.VariableNode{varNode}
+
+	dataTypeStr := tag.Type.DataTypeName()
+	if tag.IsArray() {
+		dataTypeStr += fmt.Sprintf("[%d]", tag.Length)
+	}
+
+	fmt.Printf("%-40s %-50s %s\n", tag.Name, nodeID, dataTypeStr)
+
+	// Aliases (e.g. legacy numeric NodeIds) are unreferenced copies of the node that read and write the same tag
+	for _, alias := range tag.Aliases {
+		aliasID, err := s.aliasNodeID(tag, alias)
+		if err != nil {
+			return nil, err
+		}
+		aliasNode := server.NewVariableNode(
+			s.server,
+			aliasID,
+			varNode.BrowseName(),
+			varNode.DisplayName(),
+			varNode.Description(),
+			varNode.RolePermissions(),
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: typeDefinition(varNode)},
 				},
 			},
 			initialValue,
 			dataType,
-			ua.ValueRankScalar,
-			[]uint32{},
-			ua.AccessLevelsCurrentRead|ua.AccessLevelsCurrentWrite,
-			250.0,
+			valueRank,
+			arrayDimensions,
+			accessLevel&^ua.AccessLevelsHistoryRead,
+			varNode.MinimumSamplingInterval(),
 			false,
 			nil,
 		)
+		aliasNode.SetWriteValueHandler(s.newWriteHandler(tag.Name))
+		nodesToAdd = append(nodesToAdd, aliasNode)
+		varNodes = append(varNodes, aliasNode)
+		fmt.Printf("%-40s %-50s %s\n", "", aliasID, "alias")
+	}
+
+	s.publishMu.Lock()
+	s.tagNodes[tag.Name] = varNodes
+	if deadband > 0 {
+		s.deadbands[tag.Name] = deadband
+	}
+	s.publishMu.Unlock()
+
+	// Motors also expose their complete state as a MotorState structure next to the variable
+	if !bound {
+		if stateNode := s.motorStateNode(tag, browseName, parentNodeID, parentRefType); stateNode != nil {
+			nodesToAdd = append(nodesToAdd, stateNode)
+			fmt.Printf("%-40s %-50s %s\n", "", stateNode.NodeID(), "MotorState")
+		}
+	}
+
+	s.trackNodes(tag.Name, nodesToAdd)
+	return nodesToAdd, nil
+}
+
+// newWriteHandler returns a write handler that pushes client writes into the tag manager
+func (s *OPCUAServer) newWriteHandler(tagName string) func(*server.Session, ua.WriteValue) (ua.DataValue, ua.StatusCode) {
+	return func(session *server.Session, writeValue ua.WriteValue) (ua.DataValue, ua.StatusCode) {
+		if writeValue.Value.Value == nil {
+			return ua.DataValue{}, ua.BadTypeMismatch
+		}
 
-		nodesToAdd = append(nodesToAdd, varNode)
+		newValue := variantValue(writeValue.Value.Value)
+		if status := s.checkWriteRange(tagName, newValue); status != ua.Good {
+			return ua.DataValue{}, status
+		}
+		if writeValue.IndexRange != "" {
+			var status ua.StatusCode
+			if newValue, status = s.writeIndexRange(tagName, writeValue.IndexRange, newValue); status != ua.Good {
+				return ua.DataValue{}, status
+			}
+		}
 
-		dataTypeStr := "Double"
-		if tag.Type == plc.TagTypeBool {
-			dataTypeStr = "Boolean"
-		} else if tag.Type == plc.TagTypeInt32 {
-			dataTypeStr = "Int32"
+		// The StatusCode written to a memory variable becomes its quality (Good if omitted),
+		// simulated sensors keep the quality of the simulation
+		var err error
+		if s.holdsWrittenQuality(tagName) {
+			err = s.tagManager.WriteTagSample(tagName, newValue, plc.Quality(writeValue.Value.StatusCode))
+		} else {
+			err = s.tagManager.WriteTagValue(tagName, newValue)
+		}
+		if err != nil {
+			log.Printf("[OPCUA] Write to %s rejected: %v", tagName, err)
+			return ua.DataValue{}, ua.BadTypeMismatch
 		}
 
-		fmt.Printf("%-40s %-50s %s\n", tag.Name, fmt.Sprintf("ns=2;s=%s", nodeIDString), dataTypeStr)
+		tag, err := s.tagManager.GetTag(tagName)
+		if err != nil {
+			return ua.DataValue{}, ua.BadNodeIDUnknown
+		}
+
+		value, quality, timestamp := tag.Sample()
+		log.Printf("[OPCUA] Client write: %s = %v (%s)", tagName, value, quality)
+		return ua.NewDataValue(tagVariant(value), ua.StatusCode(quality), timestamp, 0, time.Now(), 0), ua.Good
 	}
+}
 
-	// Add all nodes at once
-	nm.AddNodes(nodesToAdd...)
+// holdsWrittenQuality reports whether a tag takes the quality of client writes: memory variables
+// and tags without a simulated sensor
+func (s *OPCUAServer) holdsWrittenQuality(tagName string) bool {
+	if s.sensorManager == nil {
+		return true
+	}
+	sensor := s.sensorManager.GetSensor(tagName)
+	if sensor == nil {
+		return true
+	}
+	_, memory := sensor.(sensors.MemoryVariable)
+	return memory
+}
 
-	fmt.Println()
-	return nil
+// writeIndexRange merges a client write of the elements "i" or "i:j" into the current array value
+func (s *OPCUAServer) writeIndexRange(tagName, indexRange string, value interface{}) (interface{}, ua.StatusCode) {
+	tag, err := s.tagManager.GetTag(tagName)
+	if err != nil {
+		return nil, ua.BadNodeIDUnknown
+	}
+	if !tag.IsArray() {
+		return nil, ua.BadIndexRangeInvalid
+	}
+
+	bounds := strings.Split(indexRange, ":")
+	if len(bounds) > 2 {
+		return nil, ua.BadIndexRangeInvalid
+	}
+	first, err := strconv.Atoi(bounds[0])
+	if err != nil || first < 0 {
+		return nil, ua.BadIndexRangeInvalid
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, err = strconv.Atoi(bounds[1]); err != nil || last <= first {
+			return nil, ua.BadIndexRangeInvalid
+		}
+	}
+
+	current := reflect.ValueOf(tag.GetValue())
+	if last >= current.Len() {
+		return nil, ua.BadIndexRangeNoData
+	}
+	elements := reflect.ValueOf(value)
+	if elements.Kind() != reflect.Slice || elements.Type() != current.Type() {
+		return nil, ua.BadTypeMismatch
+	}
+	if elements.Len() != last-first+1 {
+		return nil, ua.BadIndexRangeInvalid
+	}
+
+	merged := reflect.MakeSlice(current.Type(), current.Len(), current.Len())
+	reflect.Copy(merged, current)
+	reflect.Copy(merged.Slice(first, last+1), elements)
+	return merged.Interface(), ua.Good
+}
+
+// publishTag pushes a tag change to its variable node, with the tag timestamp as SourceTimestamp.
+// Notifications that arrive after a newer sample of the same tag has been published are dropped.
+func (s *OPCUAServer) publishTag(tag *plc.Tag) {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	varNodes, ok := s.tagNodes[tag.Name]
+	if !ok {
+		return
+	}
+	if current, err := s.tagManager.GetTag(tag.Name); err != nil || current != tag {
+		return // tag replaced or removed at runtime
+	}
+	value, quality, timestamp := tag.Sample()
+	if value == nil || !timestamp.After(varNodes[0].Value().SourceTimestamp) {
+		return
+	}
+
+	s.evaluateAlarm(tag.Name, value)
+	if withinDeadband(varNodes[0].Value(), value, quality, s.deadbands[tag.Name]) {
+		return
+	}
+	dataValue := ua.NewDataValue(tagVariant(value), ua.StatusCode(quality), timestamp, 0, time.Now(), 0)
+	for _, varNode := range varNodes {
+		varNode.SetValue(dataValue)
+	}
+}
+
+// nodeUpdateIntervalMs is the period of updateNodeValues
+const nodeUpdateIntervalMs = 100
+
+// minimumSamplingInterval returns the MinimumSamplingInterval of a tag variable, the update interval of its sensor
+// (at least the update cycle of the sensor manager). The server revises faster sampling intervals of monitored
+// items to it, as sampling faster returns no new values.
+func minimumSamplingInterval(tag *plc.Tag) float64 {
+	if tag.UpdateMs <= 0 {
+		return 0
+	}
+	return max(float64(tag.UpdateMs), float64(sim.UpdateCycle.Milliseconds()))
 }
 
-// updateNodeValues continuously updates OPC UA node values from tag manager
+// updateNodeValues periodically updates the motor state structures
 func (s *OPCUAServer) updateNodeValues() {
-	ticker := time.NewTicker(100 * time.Millisecond)
+	ticker := time.NewTicker(nodeUpdateIntervalMs * time.Millisecond)
 	defer ticker.Stop()
 
 	nm := s.server.NamespaceManager()
@@ -218,35 +593,7 @@ func (s *OPCUAServer) updateNodeValues() {
 			}
 
 			s.mu.RLock()
-			for tagName, nodeIDStr := range s.nodeMapping {
-				// Get current value from tag manager
-				value, err := s.tagManager.GetTagValue(tagName)
-				if err != nil {
-					continue
-				}
-
-				// Find the node using string identifier
-				nodeIDObj := ua.ParseNodeID(fmt.Sprintf("ns=2;s=%s", nodeIDStr))
-				if varNode, ok := nm.FindVariable(nodeIDObj); ok {
-					// Create new data value
-					var dataValue ua.DataValue
-
-					switch v := value.(type) {
-					case float64:
-						dataValue = ua.NewDataValue(v, 0, time.Now(), 0, time.Now(), 0)
-					case int32:
-						dataValue = ua.NewDataValue(v, 0, time.Now(), 0, time.Now(), 0)
-					case bool:
-						dataValue = ua.NewDataValue(v, 0, time.Now(), 0, time.Now(), 0)
-					case string:
-						dataValue = ua.NewDataValue(v, 0, time.Now(), 0, time.Now(), 0)
-					default:
-						continue
-					}
-
-					varNode.SetValue(dataValue)
-				}
-			}
+			s.updateMotorStates(nm)
 			s.mu.RUnlock()
 		}
 	}
@@ -258,6 +605,9 @@ func (s *OPCUAServer) Stop() {
 	s.running = false
 	s.mu.Unlock()
 
+	if s.chaos != nil {
+		s.chaos.stop()
+	}
 	if s.server != nil {
 		s.server.Close()
 	}
@@ -269,7 +619,7 @@ func (s *OPCUAServer) Stop() {
 	log.Println("[OPCUA] Server stopped")
 }
 
-// GetNodeID returns the node ID string for a tag name
+// GetNodeID returns the node identifier for a tag name (the tag name for string NodeIds, e.g. "i=1000" otherwise)
 func (s *OPCUAServer) GetNodeID(tagName string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -278,10 +628,10 @@ func (s *OPCUAServer) GetNodeID(tagName string) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("tag '%s' not found in node mapping", tagName)
 	}
-	return nodeID, nil
+	return nodeIdentifier(nodeID), nil
 }
 
-// GetNodeIDString returns the full node ID string for a tag name (ns=2;s=...)
+// GetNodeIDString returns the full node ID string for a tag name (ns=<index>;s=...)
 func (s *OPCUAServer) GetNodeIDString(tagName string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -290,7 +640,17 @@ func (s *OPCUAServer) GetNodeIDString(tagName string) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("tag '%s' not found in node mapping", tagName)
 	}
-	return fmt.Sprintf("ns=2;s=%s", nodeID), nil
+	return fmt.Sprint(nodeID), nil
+}
+
+// findTag returns the tag name of a node identifier or full node ID string (caller holds the lock)
+func (s *OPCUAServer) findTag(nodeIDStr string) (string, bool) {
+	for tagName, nid := range s.nodeMapping {
+		if nodeIdentifier(nid) == nodeIDStr || fmt.Sprint(nid) == nodeIDStr {
+			return tagName, true
+		}
+	}
+	return "", false
 }
 
 // ReadTagValue reads a tag value by node ID string
@@ -298,13 +658,11 @@ func (s *OPCUAServer) ReadTagValue(nodeIDStr string) (interface{}, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Find tag by node ID
-	for tagName, nid := range s.nodeMapping {
-		if nid == nodeIDStr {
-			return s.tagManager.GetTagValue(tagName)
-		}
+	tagName, ok := s.findTag(nodeIDStr)
+	if !ok {
+		return nil, fmt.Errorf("node ID '%s' not found", nodeIDStr)
 	}
-	return nil, fmt.Errorf("node ID 'ns=2;s=%s' not found", nodeIDStr)
+	return s.tagManager.GetTagValue(tagName)
 }
 
 // WriteTagValue writes a value to a tag by node ID string
@@ -312,13 +670,11 @@ func (s *OPCUAServer) WriteTagValue(nodeIDStr string, value interface{}) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Find tag by node ID
-	for tagName, nid := range s.nodeMapping {
-		if nid == nodeIDStr {
-			return s.tagManager.SetTagValue(tagName, value)
-		}
+	tagName, ok := s.findTag(nodeIDStr)
+	if !ok {
+		return fmt.Errorf("node ID '%s' not found", nodeIDStr)
 	}
-	return fmt.Errorf("node ID 'ns=2;s=%s' not found", nodeIDStr)
+	return s.tagManager.WriteTagValue(tagName, value)
 }
 
 // GetAllNodeValues returns all node values
@@ -0,0 +1,186 @@
+package opcuaserver
+
+import (
+	"fmt"
+	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim/sensors"
+	"reflect"
+	"time"
+
+	"github.com/awcullen/opcua/server"
+	"github.com/awcullen/opcua/ua"
+)
+
+// motorStateEncodingID is the "Default Binary" encoding of MotorState in the types namespace.
+// It is registered by namespace URI, the encoder resolves the index of the running server.
+var motorStateEncodingID = ua.ExpandedNodeID{
+	NamespaceURI: typesNamespaceURI,
+	NodeID:       ua.NodeIDString{ID: "DataType.MotorState.DefaultBinary"},
+}
+
+// MotorState is the OPC UA structure of a motor state variable.
+// The field order is the binary encoding order and must match motorStateDefinition.
+type MotorState struct {
+	Position float64
+	Velocity float64
+	Torque   float64
+	Target   float64
+	Enabled  bool
+}
+
+func init() {
+	// Structures in variants are encoded as ExtensionObjects with this encoding ID
+	ua.RegisterBinaryEncodingID(reflect.TypeOf(MotorState{}), motorStateEncodingID)
+}
+
+// motorStateTypeID is the structured DataType of motor state variables
+func (s *OPCUAServer) motorStateTypeID() ua.NodeID {
+	return ua.NodeIDString{NamespaceIndex: s.typesNamespace, ID: "DataType.MotorState"}
+}
+
+// motorStateEncodingNodeID is the motor state encoding ID in the types namespace of this server
+func (s *OPCUAServer) motorStateEncodingNodeID() ua.NodeID {
+	return ua.NodeIDString{NamespaceIndex: s.typesNamespace, ID: "DataType.MotorState.DefaultBinary"}
+}
+
+// motorStateDefinition is the DataTypeDefinition of MotorState, used by clients to decode the structure
+func (s *OPCUAServer) motorStateDefinition() ua.StructureDefinition {
+	return ua.StructureDefinition{
+		DefaultEncodingID: s.motorStateEncodingNodeID(),
+		BaseDataType:      ua.DataTypeIDStructure,
+		StructureType:     ua.StructureTypeStructure,
+		Fields: []ua.StructureField{
+			structureField("Position", ua.DataTypeIDDouble, "Current position (degrees or steps)"),
+			structureField("Velocity", ua.DataTypeIDDouble, "Current velocity (RPM or steps/second)"),
+			structureField("Torque", ua.DataTypeIDDouble, "Current motor torque (Nm), 0 for step motors"),
+			structureField("Target", ua.DataTypeIDDouble, "Commanded target velocity (servo) or position (step)"),
+			structureField("Enabled", ua.DataTypeIDBoolean, "Simulation running"),
+		},
+	}
+}
+
+// structureField describes a scalar field of a structured DataType
+func structureField(name string, dataType ua.NodeID, description string) ua.StructureField {
+	return ua.StructureField{
+		Name:        name,
+		Description: ua.LocalizedText{Text: description},
+		DataType:    dataType,
+		ValueRank:   ua.ValueRankScalar,
+	}
+}
+
+// motorStateVariant converts a motor state to its structure value
+func motorStateVariant(state sensors.MotorState) ua.Variant {
+	return MotorState{
+		Position: state.Position,
+		Velocity: state.Velocity,
+		Torque:   state.Torque,
+		Target:   state.Target,
+		Enabled:  state.Enabled,
+	}
+}
+
+// dataTypeNodes creates the structured DataType nodes with their binary encoding objects
+func (s *OPCUAServer) dataTypeNodes() []server.Node {
+	return []server.Node{
+		server.NewDataTypeNode(
+			s.server,
+			s.motorStateTypeID(),
+			ua.QualifiedName{NamespaceIndex: s.typesNamespace, Name: "MotorState"},
+			ua.LocalizedText{Text: "MotorState"},
+			ua.LocalizedText{Text: "Position, velocity, torque and target of a motor"},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasSubtype,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.DataTypeIDStructure},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasEncoding,
+					TargetID:        ua.ExpandedNodeID{NodeID: s.motorStateEncodingNodeID()},
+				},
+			},
+			false,
+			s.motorStateDefinition(),
+		),
+		server.NewObjectNode(
+			s.server,
+			s.motorStateEncodingNodeID(),
+			ua.QualifiedName{Name: "Default Binary"},
+			ua.LocalizedText{Text: "Default Binary"},
+			ua.LocalizedText{},
+			nil,
+			[]ua.Reference{
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+					TargetID:        ua.ExpandedNodeID{NodeID: ua.ObjectTypeIDDataTypeEncodingType},
+				},
+				{
+					ReferenceTypeID: ua.ReferenceTypeIDHasEncoding,
+					IsInverse:       true,
+					TargetID:        ua.ExpandedNodeID{NodeID: s.motorStateTypeID()},
+				},
+			},
+			0,
+		),
+	}
+}
+
+// motorStateNode creates the read-only MotorState variable of a motor tag next to the tag node.
+// It returns nil if the tag does not belong to a motor.
+func (s *OPCUAServer) motorStateNode(tag *plc.Tag, browseName string, parentNodeID, parentRefType ua.NodeID) server.Node {
+	if s.sensorManager == nil {
+		return nil
+	}
+	state, err := s.sensorManager.MotorState(tag.Name)
+	if err != nil {
+		return nil
+	}
+
+	ns := s.tagNamespace(tag)
+	nodeID := ua.NodeIDString{NamespaceIndex: ns, ID: tag.Name + ".State"}
+	s.stateNodes[tag.Name] = nodeID
+
+	return server.NewVariableNode(
+		s.server,
+		nodeID,
+		ua.QualifiedName{NamespaceIndex: ns, Name: browseName + "State"},
+		ua.LocalizedText{Text: browseName + "State"},
+		ua.LocalizedText{Text: fmt.Sprintf("Motor state of %s", tag.Name)},
+		nil,
+		[]ua.Reference{
+			{
+				ReferenceTypeID: ua.ReferenceTypeIDHasTypeDefinition,
+				TargetID:        ua.ExpandedNodeID{NodeID: ua.VariableTypeIDBaseDataVariableType},
+			},
+			{
+				ReferenceTypeID: parentRefType,
+				IsInverse:       true,
+				TargetID:        ua.ExpandedNodeID{NodeID: parentNodeID},
+			},
+		},
+		ua.NewDataValue(motorStateVariant(state), 0, time.Now(), 0, time.Now(), 0),
+		s.motorStateTypeID(),
+		ua.ValueRankScalar,
+		[]uint32{},
+		ua.AccessLevelsCurrentRead,
+		max(minimumSamplingInterval(tag), nodeUpdateIntervalMs), // published by updateNodeValues
+		false,
+		nil,
+	)
+}
+
+// updateMotorStates publishes the current state of all motors
+func (s *OPCUAServer) updateMotorStates(nm *server.NamespaceManager) {
+	for tagName, nodeID := range s.stateNodes {
+		state, err := s.sensorManager.MotorState(tagName)
+		if err != nil {
+			continue
+		}
+		if varNode, ok := nm.FindVariable(nodeID); ok {
+			now := time.Now()
+			varNode.SetValue(ua.NewDataValue(motorStateVariant(state), 0, now, 0, now, 0))
+		}
+	}
+}
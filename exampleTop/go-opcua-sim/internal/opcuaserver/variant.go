@@ -0,0 +1,171 @@
+package opcuaserver
+
+import (
+	"go-opcua-sim/internal/plc"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/awcullen/opcua/ua"
+)
+
+// tagDataType returns the OPC UA data type of a tag type
+func tagDataType(tagType plc.TagType) ua.NodeID {
+	switch tagType {
+	case plc.TagTypeBool:
+		return ua.DataTypeIDBoolean
+	case plc.TagTypeSByte:
+		return ua.DataTypeIDSByte
+	case plc.TagTypeByte:
+		return ua.DataTypeIDByte
+	case plc.TagTypeInt16:
+		return ua.DataTypeIDInt16
+	case plc.TagTypeUInt16:
+		return ua.DataTypeIDUInt16
+	case plc.TagTypeInt32:
+		return ua.DataTypeIDInt32
+	case plc.TagTypeUInt32:
+		return ua.DataTypeIDUInt32
+	case plc.TagTypeInt64:
+		return ua.DataTypeIDInt64
+	case plc.TagTypeUInt64:
+		return ua.DataTypeIDUInt64
+	case plc.TagTypeFloat32:
+		return ua.DataTypeIDFloat
+	case plc.TagTypeString:
+		return ua.DataTypeIDString
+	case plc.TagTypeDateTime:
+		return ua.DataTypeIDDateTime
+	case plc.TagTypeByteString:
+		return ua.DataTypeIDByteString
+	case plc.TagTypeLocalizedText:
+		return ua.DataTypeIDLocalizedText
+	default:
+		return ua.DataTypeIDDouble
+	}
+}
+
+// tagVariant converts a tag value to its OPC UA variant representation
+func tagVariant(value interface{}) ua.Variant {
+	switch v := value.(type) {
+	case plc.ByteString:
+		return ua.ByteString(v)
+	case plc.LocalizedText:
+		return ua.LocalizedText{Locale: v.Locale, Text: v.Text}
+	case []plc.ByteString:
+		values := make([]ua.ByteString, len(v))
+		for i, element := range v {
+			values[i] = ua.ByteString(element)
+		}
+		return values
+	case []plc.LocalizedText:
+		values := make([]ua.LocalizedText, len(v))
+		for i, element := range v {
+			values[i] = ua.LocalizedText{Locale: element.Locale, Text: element.Text}
+		}
+		return values
+	default:
+		return value
+	}
+}
+
+// variantValue converts a written variant to a value accepted by the tag manager
+func variantValue(v ua.Variant) interface{} {
+	switch val := v.(type) {
+	case ua.ByteString:
+		return plc.ByteString(val)
+	case ua.LocalizedText:
+		return plc.LocalizedText{Locale: val.Locale, Text: val.Text}
+	case []ua.ByteString:
+		values := make([]plc.ByteString, len(val))
+		for i, element := range val {
+			values[i] = plc.ByteString(element)
+		}
+		return values
+	case []ua.LocalizedText:
+		values := make([]plc.LocalizedText, len(val))
+		for i, element := range val {
+			values[i] = plc.LocalizedText{Locale: element.Locale, Text: element.Text}
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// variantMatches reports whether a variant holds a value of the given scalar data type
+func variantMatches(v ua.Variant, dataType ua.NodeID) bool {
+	switch v.(type) {
+	case bool:
+		return dataType == ua.DataTypeIDBoolean
+	case int8:
+		return dataType == ua.DataTypeIDSByte
+	case uint8:
+		return dataType == ua.DataTypeIDByte
+	case int16:
+		return dataType == ua.DataTypeIDInt16
+	case uint16:
+		return dataType == ua.DataTypeIDUInt16
+	case int32:
+		return dataType == ua.DataTypeIDInt32
+	case uint32:
+		return dataType == ua.DataTypeIDUInt32
+	case int64:
+		return dataType == ua.DataTypeIDInt64
+	case uint64:
+		return dataType == ua.DataTypeIDUInt64
+	case float32:
+		return dataType == ua.DataTypeIDFloat
+	case float64:
+		return dataType == ua.DataTypeIDDouble
+	case string:
+		return dataType == ua.DataTypeIDString
+	case time.Time:
+		return dataType == ua.DataTypeIDDateTime
+	case ua.ByteString:
+		return dataType == ua.DataTypeIDByteString
+	case ua.LocalizedText:
+		return dataType == ua.DataTypeIDLocalizedText
+	default:
+		return false
+	}
+}
+
+// numericValue converts an integer or floating point value to float64
+func numericValue(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// variantFloat64 converts a scalar variant to float64 (true = 1)
+func variantFloat64(v ua.Variant) float64 {
+	if val, ok := v.(bool); ok {
+		if val {
+			return 1.0
+		}
+		return 0.0
+	}
+	f, _ := numericValue(v)
+	return f
+}
+
+// float64ToVariant converts a float64 to the variant type of a tag (integers are rounded)
+func float64ToVariant(value float64, tagType plc.TagType) ua.Variant {
+	if tagType != plc.TagTypeFloat64 && tagType != plc.TagTypeFloat32 {
+		value = math.Round(value)
+	}
+	converted, err := plc.ConvertValue(tagType, value)
+	if err != nil {
+		return value
+	}
+	return tagVariant(converted)
+}
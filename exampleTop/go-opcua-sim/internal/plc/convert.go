@@ -0,0 +1,271 @@
+package plc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ErrOutOfRange is returned when a value does not fit the range of the tag data type
+var ErrOutOfRange = errors.New("out of range")
+
+// ErrTypeMismatch is returned when a client writes a value of another data type than the tag
+var ErrTypeMismatch = errors.New("type mismatch")
+
+// ByteString is an opaque byte sequence (OPC UA ByteString), distinct from a Byte array
+type ByteString []byte
+
+// LocalizedText is a text with an optional locale (OPC UA LocalizedText)
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+// zeroValue returns the initial value of a tag type
+func zeroValue(tagType TagType) interface{} {
+	switch tagType {
+	case TagTypeFloat64:
+		return 0.0
+	case TagTypeInt32:
+		return int32(0)
+	case TagTypeBool:
+		return false
+	case TagTypeSByte:
+		return int8(0)
+	case TagTypeByte:
+		return uint8(0)
+	case TagTypeInt16:
+		return int16(0)
+	case TagTypeUInt16:
+		return uint16(0)
+	case TagTypeUInt32:
+		return uint32(0)
+	case TagTypeInt64:
+		return int64(0)
+	case TagTypeUInt64:
+		return uint64(0)
+	case TagTypeFloat32:
+		return float32(0)
+	case TagTypeDateTime:
+		return time.Time{}
+	case TagTypeByteString:
+		return ByteString{}
+	case TagTypeLocalizedText:
+		return LocalizedText{}
+	default:
+		return ""
+	}
+}
+
+// ConvertValue converts a scalar value to the Go representation of a tag type.
+// Integers are range checked (floats are truncated first) and strings are parsed,
+// so 64-bit values can be given exactly (e.g. "18446744073709551615").
+func ConvertValue(tagType TagType, value interface{}) (interface{}, error) {
+	switch tagType {
+	case TagTypeBool:
+		return toBool(tagType, value)
+	case TagTypeSByte:
+		n, err := toSigned(tagType, value, math.MinInt8, math.MaxInt8)
+		return int8(n), err
+	case TagTypeInt16:
+		n, err := toSigned(tagType, value, math.MinInt16, math.MaxInt16)
+		return int16(n), err
+	case TagTypeInt32:
+		n, err := toSigned(tagType, value, math.MinInt32, math.MaxInt32)
+		return int32(n), err
+	case TagTypeInt64:
+		return toSigned(tagType, value, math.MinInt64, math.MaxInt64)
+	case TagTypeByte:
+		n, err := toUnsigned(tagType, value, math.MaxUint8)
+		return uint8(n), err
+	case TagTypeUInt16:
+		n, err := toUnsigned(tagType, value, math.MaxUint16)
+		return uint16(n), err
+	case TagTypeUInt32:
+		n, err := toUnsigned(tagType, value, math.MaxUint32)
+		return uint32(n), err
+	case TagTypeUInt64:
+		return toUnsigned(tagType, value, math.MaxUint64)
+	case TagTypeFloat32:
+		f, err := toFloat(tagType, value)
+		if err == nil && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return nil, rangeError(tagType, value)
+		}
+		return float32(f), err
+	case TagTypeFloat64:
+		return toFloat(tagType, value)
+	case TagTypeDateTime:
+		return toTime(tagType, value)
+	case TagTypeByteString:
+		switch v := value.(type) {
+		case ByteString:
+			return append(ByteString{}, v...), nil
+		case []byte:
+			return append(ByteString{}, v...), nil
+		case string:
+			return ByteString(v), nil
+		}
+		return nil, typeError(tagType, value)
+	case TagTypeLocalizedText:
+		switch v := value.(type) {
+		case LocalizedText:
+			return v, nil
+		case string:
+			return LocalizedText{Text: v}, nil
+		case map[string]interface{}: // JSON {"locale": "en", "text": "..."}
+			locale, _ := v["locale"].(string)
+			text, _ := v["text"].(string)
+			return LocalizedText{Locale: locale, Text: text}, nil
+		}
+		return nil, typeError(tagType, value)
+	default:
+		return fmt.Sprintf("%v", value), nil
+	}
+}
+
+// toSigned converts a numeric value or decimal string to an integer within [min, max]
+func toSigned(tagType TagType, value interface{}, min, max int64) (int64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n >= min && n <= max {
+			return n, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := rv.Uint(); n <= uint64(max) {
+			return int64(n), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		// float64(max)+1 is the first value past max, also where max is not exactly representable
+		if f := math.Trunc(rv.Float()); f >= float64(min) && f < float64(max)+1 {
+			return int64(f), nil
+		}
+	case reflect.String:
+		n, err := strconv.ParseInt(rv.String(), 10, 64)
+		if err == nil {
+			return toSigned(tagType, n, min, max)
+		}
+		if f, ferr := strconv.ParseFloat(rv.String(), 64); ferr == nil && !errors.Is(err, strconv.ErrRange) {
+			return toSigned(tagType, f, min, max)
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			return 0, valueError(tagType, value)
+		}
+	default:
+		return 0, typeError(tagType, value)
+	}
+	return 0, rangeError(tagType, value)
+}
+
+// toUnsigned converts a numeric value or decimal string to an integer within [0, max]
+func toUnsigned(tagType TagType, value interface{}, max uint64) (uint64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n >= 0 && uint64(n) <= max {
+			return uint64(n), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := rv.Uint(); n <= max {
+			return n, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := math.Trunc(rv.Float()); f >= 0 && f < float64(max)+1 {
+			return uint64(f), nil
+		}
+	case reflect.String:
+		n, err := strconv.ParseUint(rv.String(), 10, 64)
+		if err == nil {
+			return toUnsigned(tagType, n, max)
+		}
+		if f, ferr := strconv.ParseFloat(rv.String(), 64); ferr == nil && !errors.Is(err, strconv.ErrRange) {
+			return toUnsigned(tagType, f, max)
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			return 0, valueError(tagType, value)
+		}
+	default:
+		return 0, typeError(tagType, value)
+	}
+	return 0, rangeError(tagType, value)
+}
+
+// toFloat converts a numeric value or decimal string to float64
+func toFloat(tagType TagType, value interface{}) (float64, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		f, err := strconv.ParseFloat(rv.String(), 64)
+		if err != nil {
+			return 0, valueError(tagType, value)
+		}
+		return f, nil
+	}
+	return 0, typeError(tagType, value)
+}
+
+// toBool converts a bool, a number (non-zero = true) or "true"/"false"
+func toBool(tagType TagType, value interface{}) (bool, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() != 0, nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() != 0, nil
+	case reflect.String:
+		b, err := strconv.ParseBool(rv.String())
+		if err != nil {
+			return false, valueError(tagType, value)
+		}
+		return b, nil
+	}
+	return false, typeError(tagType, value)
+}
+
+// toTime converts a time, an RFC 3339 string or Unix seconds (UTC)
+func toTime(tagType TagType, value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, valueError(tagType, value)
+		}
+		return t, nil
+	}
+	seconds, err := toFloat(tagType, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
+
+// typeError reports a value of a type that cannot be converted
+func typeError(tagType TagType, value interface{}) error {
+	return fmt.Errorf("invalid type %T for %s tag", value, getTagTypeString(tagType))
+}
+
+// valueError reports a string that cannot be parsed
+func valueError(tagType TagType, value interface{}) error {
+	return fmt.Errorf("invalid value %q for %s tag", value, getTagTypeString(tagType))
+}
+
+// rangeError reports a value outside the range of the tag type
+func rangeError(tagType TagType, value interface{}) error {
+	return fmt.Errorf("%v is %w for %s tag", value, ErrOutOfRange, getTagTypeString(tagType))
+}
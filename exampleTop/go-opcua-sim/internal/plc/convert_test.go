@@ -0,0 +1,138 @@
+package plc
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestToSigned(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		min, max int64
+		want     int64
+		errRange bool // ErrOutOfRange expected
+		err      bool // other error expected
+	}{
+		{"int64 max", int64(math.MaxInt64), math.MinInt64, math.MaxInt64, math.MaxInt64, false, false},
+		{"int64 min", int64(math.MinInt64), math.MinInt64, math.MaxInt64, math.MinInt64, false, false},
+		{"uint64 above int64 max", uint64(math.MaxInt64) + 1, math.MinInt64, math.MaxInt64, 0, true, false},
+		{"int32 below min", int64(math.MinInt32) - 1, math.MinInt32, math.MaxInt32, 0, true, false},
+		{"int32 above max", int64(math.MaxInt32) + 1, math.MinInt32, math.MaxInt32, 0, true, false},
+		{"float truncated", 2147483647.9, math.MinInt32, math.MaxInt32, math.MaxInt32, false, false},
+		{"float negative truncated", -2147483648.9, math.MinInt32, math.MaxInt32, math.MinInt32, false, false},
+		{"float max+1", 2147483648.0, math.MinInt32, math.MaxInt32, 0, true, false},
+		{"float 2^63", math.Pow(2, 63), math.MinInt64, math.MaxInt64, 0, true, false},
+		{"float below 2^63", math.Nextafter(math.Pow(2, 63), 0), math.MinInt64, math.MaxInt64, 1<<63 - 1024, false, false},
+		{"float -2^63", -math.Pow(2, 63), math.MinInt64, math.MaxInt64, math.MinInt64, false, false},
+		{"NaN", math.NaN(), math.MinInt64, math.MaxInt64, 0, true, false},
+		{"+Inf", math.Inf(1), math.MinInt64, math.MaxInt64, 0, true, false},
+		{"-Inf", math.Inf(-1), math.MinInt64, math.MaxInt64, 0, true, false},
+		{"string int64 max", "9223372036854775807", math.MinInt64, math.MaxInt64, math.MaxInt64, false, false},
+		{"string int64 max+1", "9223372036854775808", math.MinInt64, math.MaxInt64, 0, true, false},
+		{"string exponent", "1e3", math.MinInt16, math.MaxInt16, 1000, false, false},
+		{"string fraction", "-12.7", math.MinInt8, math.MaxInt8, -12, false, false},
+		{"string exponent out of range", "1e30", math.MinInt64, math.MaxInt64, 0, true, false},
+		{"string NaN", "NaN", math.MinInt64, math.MaxInt64, 0, true, false},
+		{"string invalid", "abc", math.MinInt64, math.MaxInt64, 0, false, true},
+		{"bool", true, math.MinInt64, math.MaxInt64, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toSigned(TagTypeInt64, tt.value, tt.min, tt.max)
+			checkConvertError(t, err, tt.errRange, tt.err)
+			if err == nil && got != tt.want {
+				t.Errorf("toSigned(%v) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToUnsigned(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		max      uint64
+		want     uint64
+		errRange bool
+		err      bool
+	}{
+		{"uint64 max", uint64(math.MaxUint64), math.MaxUint64, math.MaxUint64, false, false},
+		{"uint32 above max", uint64(math.MaxUint32) + 1, math.MaxUint32, 0, true, false},
+		{"int64 max", int64(math.MaxInt64), math.MaxUint64, math.MaxInt64, false, false},
+		{"negative int", int32(-1), math.MaxUint64, 0, true, false},
+		{"negative float", -1.0, math.MaxUint64, 0, true, false},
+		{"float truncated", 255.9, math.MaxUint8, 255, false, false},
+		{"float max+1", 256.0, math.MaxUint8, 0, true, false},
+		{"float 2^64", math.Pow(2, 64), math.MaxUint64, 0, true, false},
+		{"float below 2^64", math.Nextafter(math.Pow(2, 64), 0), math.MaxUint64, 1<<64 - 2048, false, false},
+		{"NaN", math.NaN(), math.MaxUint64, 0, true, false},
+		{"+Inf", math.Inf(1), math.MaxUint64, 0, true, false},
+		{"-Inf", math.Inf(-1), math.MaxUint64, 0, true, false},
+		{"string uint64 max", "18446744073709551615", math.MaxUint64, math.MaxUint64, false, false},
+		{"string uint64 max+1", "18446744073709551616", math.MaxUint64, 0, true, false},
+		{"string exponent", "1e3", math.MaxUint16, 1000, false, false},
+		{"string negative", "-1", math.MaxUint64, 0, true, false},
+		{"string invalid", "0x10", math.MaxUint64, 0, false, true},
+		{"bool", false, math.MaxUint64, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toUnsigned(TagTypeUInt64, tt.value, tt.max)
+			checkConvertError(t, err, tt.errRange, tt.err)
+			if err == nil && got != tt.want {
+				t.Errorf("toUnsigned(%v) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  time.Time
+		err   bool
+	}{
+		{"time", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"RFC 3339", "2024-05-01T12:00:00.5Z", time.Date(2024, 5, 1, 12, 0, 0, 5e8, time.UTC), false},
+		{"Unix seconds", int64(1714564800), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"fractional Unix seconds", 1714564800.25, time.Date(2024, 5, 1, 12, 0, 0, 25e7, time.UTC), false},
+		{"negative fractional Unix seconds", -1.5, time.Date(1969, 12, 31, 23, 59, 58, 5e8, time.UTC), false},
+		{"string Unix seconds", "1714564800", time.Time{}, true},
+		{"bool", true, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toTime(TagTypeDateTime, tt.value)
+			checkConvertError(t, err, false, tt.err)
+			if err == nil && !got.Equal(tt.want) {
+				t.Errorf("toTime(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertValueFloat32Range(t *testing.T) {
+	if _, err := ConvertValue(TagTypeFloat32, math.MaxFloat64); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("MaxFloat64 as Float32: err = %v, want %v", err, ErrOutOfRange)
+	}
+	if v, err := ConvertValue(TagTypeFloat32, math.Inf(-1)); err != nil || !math.IsInf(float64(v.(float32)), -1) {
+		t.Errorf("-Inf as Float32 = %v, %v, want -Inf", v, err)
+	}
+}
+
+// checkConvertError checks that err is ErrOutOfRange, another error or nil as expected
+func checkConvertError(t *testing.T, err error, errRange, other bool) {
+	t.Helper()
+	switch {
+	case errRange && !errors.Is(err, ErrOutOfRange):
+		t.Errorf("err = %v, want %v", err, ErrOutOfRange)
+	case other && (err == nil || errors.Is(err, ErrOutOfRange)):
+		t.Errorf("err = %v, want a type or value error", err)
+	case !errRange && !other && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
@@ -18,6 +19,7 @@ type LuaEngine struct {
 	running     bool
 	stopChan    chan struct{}
 	initialized bool
+	pushed      map[string]lua.LValue // Data table values set from the tags before run_logic, copies of array tables
 }
 
 // NewLuaEngine creates a new Lua engine
@@ -88,19 +90,7 @@ func (le *LuaEngine) registerPLCFunctions() {
 			return 2
 		}
 
-		value := tag.GetValue()
-		switch v := value.(type) {
-		case float64:
-			L.Push(lua.LNumber(v))
-		case int32:
-			L.Push(lua.LNumber(v))
-		case bool:
-			L.Push(lua.LBool(v))
-		case string:
-			L.Push(lua.LString(v))
-		default:
-			L.Push(lua.LNil)
-		}
+		L.Push(luaValue(L, tag.GetValue()))
 		L.Push(lua.LNil) // no error
 		return 2
 	}))
@@ -125,6 +115,8 @@ func (le *LuaEngine) registerPLCFunctions() {
 			goValue = bool(value.(lua.LBool))
 		case lua.LTString:
 			goValue = string(value.(lua.LString))
+		case lua.LTTable:
+			goValue = goArray(value.(*lua.LTable))
 		default:
 			L.Push(lua.LBool(false))
 			L.Push(lua.LString("unsupported value type"))
@@ -142,6 +134,53 @@ func (le *LuaEngine) registerPLCFunctions() {
 		return 2
 	}))
 
+	// Get tag quality name (e.g. "Good", "Bad_SensorFailure")
+	le.L.SetGlobal("get_quality", le.L.NewFunction(func(L *lua.LState) int {
+		quality, err := le.tagManager.GetTagQuality(L.CheckString(1))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		L.Push(lua.LString(quality.String()))
+		L.Push(lua.LNil) // no error
+		return 2
+	}))
+
+	// Set tag quality by name or StatusCode number
+	le.L.SetGlobal("set_quality", le.L.NewFunction(func(L *lua.LState) int {
+		tagName := L.CheckString(1)
+
+		var quality Quality
+		switch value := L.Get(2).(type) {
+		case lua.LNumber:
+			quality = Quality(uint32(value))
+		case lua.LString:
+			parsed, err := ParseQuality(string(value))
+			if err != nil {
+				L.Push(lua.LBool(false))
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+			quality = parsed
+		default:
+			L.Push(lua.LBool(false))
+			L.Push(lua.LString("unsupported quality type"))
+			return 2
+		}
+
+		if err := le.tagManager.SetTagQuality(tagName, quality); err != nil {
+			L.Push(lua.LBool(false))
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		L.Push(lua.LBool(true))
+		L.Push(lua.LNil) // no error
+		return 2
+	}))
+
 	// Log function
 	le.L.SetGlobal("plc_log", le.L.NewFunction(func(L *lua.LState) int {
 		message := L.CheckString(1)
@@ -168,24 +207,47 @@ func (le *LuaEngine) registerPLCFunctions() {
 func (le *LuaEngine) createDataTable() {
 	dataTable := le.L.NewTable()
 
+	le.pushed = make(map[string]lua.LValue)
 	tags := le.tagManager.GetAllTags()
 	for _, tag := range tags {
-		value := tag.GetValue()
-		switch v := value.(type) {
-		case float64:
-			dataTable.RawSetString(tag.Name, lua.LNumber(v))
-		case int32:
-			dataTable.RawSetString(tag.Name, lua.LNumber(v))
-		case bool:
-			dataTable.RawSetString(tag.Name, lua.LBool(v))
-		case string:
-			dataTable.RawSetString(tag.Name, lua.LString(v))
-		}
+		le.push(dataTable, tag.Name, luaValue(le.L, tag.GetValue()))
 	}
 
 	le.L.SetGlobal("Data", dataTable)
 }
 
+// push sets a Data table value and remembers it, array tables as a copy so that changes
+// the script makes to the elements are seen
+func (le *LuaEngine) push(dataTable *lua.LTable, name string, value lua.LValue) {
+	dataTable.RawSetString(name, value)
+	if table, ok := value.(*lua.LTable); ok {
+		copied := le.L.NewTable()
+		for i := 1; i <= table.Len(); i++ {
+			copied.Append(table.RawGetInt(i))
+		}
+		value = copied
+	}
+	le.pushed[name] = value
+}
+
+// unchanged reports whether a Data table value is still the value pushed from the tag
+func unchanged(value, pushed lua.LValue) bool {
+	table, ok := value.(*lua.LTable)
+	pushedTable, pushedOK := pushed.(*lua.LTable)
+	if !ok || !pushedOK {
+		return value == pushed
+	}
+	if table.Len() != pushedTable.Len() {
+		return false
+	}
+	for i := 1; i <= table.Len(); i++ {
+		if table.RawGetInt(i) != pushedTable.RawGetInt(i) {
+			return false
+		}
+	}
+	return true
+}
+
 // UpdateDataTable updates the Data table with current tag values
 func (le *LuaEngine) UpdateDataTable() {
 	dataTable := le.L.GetGlobal("Data")
@@ -198,17 +260,17 @@ func (le *LuaEngine) UpdateDataTable() {
 	table := dataTable.(*lua.LTable)
 	tags := le.tagManager.GetAllTags()
 
+	current := make(map[string]bool, len(tags))
 	for _, tag := range tags {
-		value := tag.GetValue()
-		switch v := value.(type) {
-		case float64:
-			table.RawSetString(tag.Name, lua.LNumber(v))
-		case int32:
-			table.RawSetString(tag.Name, lua.LNumber(v))
-		case bool:
-			table.RawSetString(tag.Name, lua.LBool(v))
-		case string:
-			table.RawSetString(tag.Name, lua.LString(v))
+		le.push(table, tag.Name, luaValue(le.L, tag.GetValue()))
+		current[tag.Name] = true
+	}
+
+	// Tags removed at runtime disappear from the Data table
+	for name := range le.pushed {
+		if !current[name] {
+			table.RawSetString(name, lua.LNil)
+			delete(le.pushed, name)
 		}
 	}
 }
@@ -226,6 +288,12 @@ func (le *LuaEngine) SyncDataTableToTags() error {
 	for _, tag := range tags {
 		luaValue := table.RawGetString(tag.Name)
 
+		// Values and arrays the script left unchanged are not written back, so a concurrent sensor
+		// update is kept and 64-bit integers do not lose precision through Lua numbers
+		if unchanged(luaValue, le.pushed[tag.Name]) {
+			continue
+		}
+
 		var goValue interface{}
 		switch luaValue.Type() {
 		case lua.LTNumber:
@@ -234,6 +302,8 @@ func (le *LuaEngine) SyncDataTableToTags() error {
 			goValue = bool(luaValue.(lua.LBool))
 		case lua.LTString:
 			goValue = string(luaValue.(lua.LString))
+		case lua.LTTable:
+			goValue = goArray(luaValue.(*lua.LTable))
 		default:
 			continue // Skip nil or unsupported types
 		}
@@ -246,6 +316,55 @@ func (le *LuaEngine) SyncDataTableToTags() error {
 	return nil
 }
 
+// luaValue converts a tag value to Lua (DateTime as Unix seconds, arrays as 1-based tables)
+func luaValue(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case ByteString:
+		return lua.LString(v)
+	case LocalizedText:
+		return lua.LString(v.Text)
+	case time.Time:
+		return lua.LNumber(float64(v.Unix()) + float64(v.Nanosecond())/1e9)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lua.LNumber(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(rv.Float())
+	case reflect.Slice:
+		table := L.NewTable()
+		for i := 0; i < rv.Len(); i++ {
+			table.Append(luaValue(L, rv.Index(i).Interface()))
+		}
+		return table
+	}
+	return lua.LNil
+}
+
+// goArray converts a Lua sequence to a slice for an array tag (unsupported elements stay nil)
+func goArray(table *lua.LTable) []interface{} {
+	values := make([]interface{}, table.Len())
+	for i := range values {
+		switch element := table.RawGetInt(i + 1).(type) {
+		case lua.LNumber:
+			values[i] = float64(element)
+		case lua.LBool:
+			values[i] = bool(element)
+		case lua.LString:
+			values[i] = string(element)
+		}
+	}
+	return values
+}
+
 // RunLogic executes the run_logic function once
 func (le *LuaEngine) RunLogic() error {
 	if !le.initialized {
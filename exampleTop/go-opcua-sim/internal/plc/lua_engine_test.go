@@ -0,0 +1,83 @@
+package plc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// luaTestEngine returns an initialized engine running the script over a waveform array tag and a flag
+func luaTestEngine(t *testing.T, script string) (*LuaEngine, *Tag) {
+	t.Helper()
+	tagManager := NewTagManager()
+	waveform := NewArrayTag("Waveform", "%DF100", "", TagTypeFloat64, 3)
+	for _, tag := range []*Tag{waveform, NewTag("Flag", "%MX0", "", TagTypeBool)} {
+		if err := tagManager.AddTag(tag); err != nil {
+			t.Fatalf("add tag: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "logic.lua")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	le := NewLuaEngine(tagManager, path, 100)
+	if err := le.Initialize(); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	t.Cleanup(le.Close)
+	return le, waveform
+}
+
+func TestLuaSyncArrays(t *testing.T) {
+	le, waveform := luaTestEngine(t, `
+function run_logic()
+	if Data.Flag then
+		Data.Waveform[2] = 5
+	end
+end
+`)
+
+	// An array the script only read is not written back over a waveform update during the scan
+	le.UpdateDataTable()
+	waveform.SetValue([]float64{1, 2, 3})
+	if err := le.SyncDataTableToTags(); err != nil {
+		t.Fatal(err)
+	}
+	if got := waveform.GetValue(); !reflect.DeepEqual(got, []float64{1, 2, 3}) {
+		t.Errorf("untouched array = %v, want the waveform update", got)
+	}
+
+	// An element the script changed is written back
+	flag, _ := le.tagManager.GetTag("Flag")
+	flag.SetValue(true)
+	if err := le.RunLogic(); err != nil {
+		t.Fatal(err)
+	}
+	if got := waveform.GetValue(); !reflect.DeepEqual(got, []float64{1, 5, 3}) {
+		t.Errorf("changed array = %v, want [1 5 3]", got)
+	}
+}
+
+func TestLuaSyncReplacedArray(t *testing.T) {
+	le, waveform := luaTestEngine(t, `
+function run_logic()
+	Data.Waveform = {7, 8, 9}
+end
+`)
+	if err := le.RunLogic(); err != nil {
+		t.Fatal(err)
+	}
+	if got := waveform.GetValue(); !reflect.DeepEqual(got, []float64{7, 8, 9}) {
+		t.Errorf("replaced array = %v, want [7 8 9]", got)
+	}
+
+	// Assigning the same values again leaves a newer tag value alone
+	le.UpdateDataTable()
+	le.L.DoString(`Data.Waveform = {7, 8, 9}`)
+	waveform.SetValue([]float64{4, 4, 4})
+	le.SyncDataTableToTags()
+	if got := waveform.GetValue(); !reflect.DeepEqual(got, []float64{4, 4, 4}) {
+		t.Errorf("array = %v, want [4 4 4]", got)
+	}
+}
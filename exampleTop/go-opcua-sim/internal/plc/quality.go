@@ -0,0 +1,100 @@
+package plc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Quality is the data quality of a tag, encoded as an OPC UA StatusCode
+type Quality uint32
+
+const (
+	QualityGood                                    Quality = 0x00000000
+	QualityGoodLocalOverride                       Quality = 0x00960000
+	QualityUncertain                               Quality = 0x40000000
+	QualityUncertainNoCommunicationLastUsableValue Quality = 0x408F0000
+	QualityUncertainLastUsableValue                Quality = 0x40900000
+	QualityUncertainSubstituteValue                Quality = 0x40910000
+	QualityUncertainInitialValue                   Quality = 0x40920000
+	QualityUncertainSensorNotAccurate              Quality = 0x40930000
+	QualityUncertainEngineeringUnitsExceeded       Quality = 0x40940000
+	QualityUncertainSubNormal                      Quality = 0x40950000
+	QualityBad                                     Quality = 0x80000000
+	QualityBadCommunicationFailure                 Quality = 0x80310000 // Bad_NoCommunication
+	QualityBadWaitingForInitialData                Quality = 0x80320000
+	QualityBadConfigurationError                   Quality = 0x80890000
+	QualityBadNotConnected                         Quality = 0x808A0000
+	QualityBadDeviceFailure                        Quality = 0x808B0000
+	QualityBadSensorFailure                        Quality = 0x808C0000
+	QualityBadOutOfService                         Quality = 0x808D0000
+)
+
+// qualityNames are the OPC UA symbolic names of the qualities
+var qualityNames = map[Quality]string{
+	QualityGood:              "Good",
+	QualityGoodLocalOverride: "Good_LocalOverride",
+	QualityUncertain:         "Uncertain",
+	QualityUncertainNoCommunicationLastUsableValue: "Uncertain_NoCommunicationLastUsableValue",
+	QualityUncertainLastUsableValue:                "Uncertain_LastUsableValue",
+	QualityUncertainSubstituteValue:                "Uncertain_SubstituteValue",
+	QualityUncertainInitialValue:                   "Uncertain_InitialValue",
+	QualityUncertainSensorNotAccurate:              "Uncertain_SensorNotAccurate",
+	QualityUncertainEngineeringUnitsExceeded:       "Uncertain_EngineeringUnitsExceeded",
+	QualityUncertainSubNormal:                      "Uncertain_SubNormal",
+	QualityBad:                                     "Bad",
+	QualityBadCommunicationFailure:                 "Bad_CommunicationFailure",
+	QualityBadWaitingForInitialData:                "Bad_WaitingForInitialData",
+	QualityBadConfigurationError:                   "Bad_ConfigurationError",
+	QualityBadNotConnected:                         "Bad_NotConnected",
+	QualityBadDeviceFailure:                        "Bad_DeviceFailure",
+	QualityBadSensorFailure:                        "Bad_SensorFailure",
+	QualityBadOutOfService:                         "Bad_OutOfService",
+}
+
+// ParseQuality returns the quality of a symbolic name (e.g. "Bad_SensorFailure", "BadSensorFailure")
+// or a numeric StatusCode (e.g. "0x808C0000").
+// Bad_NoCommunication is accepted as the OPC UA name of Bad_CommunicationFailure.
+func ParseQuality(name string) (Quality, error) {
+	key := normalizeQualityName(name)
+	if key == "badnocommunication" {
+		return QualityBadCommunicationFailure, nil
+	}
+	for quality, qualityName := range qualityNames {
+		if normalizeQualityName(qualityName) == key {
+			return quality, nil
+		}
+	}
+	if code, err := strconv.ParseUint(name, 0, 32); err == nil {
+		return Quality(code), nil
+	}
+	return 0, fmt.Errorf("unknown quality: %s", name)
+}
+
+// normalizeQualityName makes quality names case and underscore insensitive
+func normalizeQualityName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", ""))
+}
+
+// String returns the symbolic name of the quality, or its StatusCode in hex
+func (q Quality) String() string {
+	if name, ok := qualityNames[q]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%08X", uint32(q))
+}
+
+// IsGood reports whether the quality has Good severity
+func (q Quality) IsGood() bool {
+	return q&0xC0000000 == 0
+}
+
+// IsUncertain reports whether the quality has Uncertain severity
+func (q Quality) IsUncertain() bool {
+	return q&0xC0000000 == 0x40000000
+}
+
+// IsBad reports whether the quality has Bad severity
+func (q Quality) IsBad() bool {
+	return q&0x80000000 != 0
+}
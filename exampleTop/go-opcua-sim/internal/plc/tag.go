@@ -2,6 +2,8 @@ package plc
 
 import (
 	"fmt"
+	"go-opcua-sim/internal/config"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -14,33 +16,98 @@ const (
 	TagTypeInt32
 	TagTypeBool
 	TagTypeString
+	TagTypeSByte
+	TagTypeByte
+	TagTypeInt16
+	TagTypeUInt16
+	TagTypeUInt32
+	TagTypeInt64
+	TagTypeUInt64
+	TagTypeFloat32
+	TagTypeDateTime
+	TagTypeByteString
+	TagTypeLocalizedText
 )
 
+// tagTypeNames maps the OPC UA built-in data type names to tag types
+var tagTypeNames = map[string]TagType{
+	"Boolean":       TagTypeBool,
+	"SByte":         TagTypeSByte,
+	"Byte":          TagTypeByte,
+	"Int16":         TagTypeInt16,
+	"UInt16":        TagTypeUInt16,
+	"Int32":         TagTypeInt32,
+	"UInt32":        TagTypeUInt32,
+	"Int64":         TagTypeInt64,
+	"UInt64":        TagTypeUInt64,
+	"Float":         TagTypeFloat32,
+	"Double":        TagTypeFloat64,
+	"String":        TagTypeString,
+	"DateTime":      TagTypeDateTime,
+	"ByteString":    TagTypeByteString,
+	"LocalizedText": TagTypeLocalizedText,
+}
+
+// ParseTagType returns the tag type of an OPC UA built-in data type name (e.g. "UInt16")
+func ParseTagType(name string) (TagType, error) {
+	tagType, ok := tagTypeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported data type: %s", name)
+	}
+	return tagType, nil
+}
+
+// DataTypeName returns the OPC UA built-in data type name of the tag type (e.g. "Double")
+func (tt TagType) DataTypeName() string {
+	for name, tagType := range tagTypeNames {
+		if tagType == tt {
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// IsNumeric reports whether the tag type holds integer or floating point values
+func (tt TagType) IsNumeric() bool {
+	switch tt {
+	case TagTypeBool, TagTypeString, TagTypeDateTime, TagTypeByteString, TagTypeLocalizedText:
+		return false
+	default:
+		return true
+	}
+}
+
 // Tag represents a PLC tag (variable)
 type Tag struct {
-	Name        string      // Tag name (same as sensor name)
-	Type        TagType     // Data type
-	Value       interface{} // Current value
-	Address     string      // PLC address (%DF100, %MW0, etc)
-	Description string      // Tag description
-	Quality     bool        // Data quality (good/bad)
-	Timestamp   time.Time   // Last update timestamp
-	mu          sync.RWMutex
+	Name         string              // Tag name (same as sensor name)
+	Type         TagType             // Data type (element type of array tags)
+	Length       int                 // Array length of a one-dimensional array tag, 0 = scalar
+	Value        interface{}         // Current value
+	Address      string              // PLC address (%DF100, %MW0, etc)
+	Description  string              // Tag description
+	DisplayNames map[string]string   // Locale -> DisplayName, empty = browse name
+	Descriptions map[string]string   // Locale -> Description, empty = Description
+	BrowsePath   string              // OPC UA browse path (e.g. "Plant/Tank1/Temperature"), empty = flat
+	ReadOnly     bool                // Clients cannot write the value (AccessLevel without CurrentWrite)
+	Permissions  map[string]string   // Lowercase role -> access ("rw", "r", "none"), empty = server defaults
+	Alarms       *config.AlarmLimits // HiHi/Hi/Lo/LoLo limit alarm, nil = none
+	Units        string              // UNECE engineering units code, empty = none
+	EURange      *config.Range       // Normal operating range, nil = instrument range
+	Instrument   *config.Range       // Instrument range, nil = sensor output range
+	Deadband     float64             // Percent of the EURange a change must exceed to be published, 0 = every change
+	NodeID       string              // Explicit NodeId identifier (e.g. "i=1000"), empty = "s=<Name>"
+	Namespace    string              // Namespace URI of NodeID, empty = simulator namespace
+	Aliases      []string            // Additional NodeIds resolving to the tag
+	UpdateMs     int                 // Update interval of the sensor in ms, 0 = unknown
+	Quality      Quality             // Data quality (OPC UA StatusCode)
+	Timestamp    time.Time           // Last update timestamp (acquisition time of the value)
+	onChange     func(*Tag)          // Set by the TagManager holding the tag
+	mu           sync.RWMutex
 }
 
 // NewTag creates a new tag
 func NewTag(name, address, description string, tagType TagType) *Tag {
-	var defaultValue interface{}
-	switch tagType {
-	case TagTypeFloat64:
-		defaultValue = 0.0
-	case TagTypeInt32:
-		defaultValue = int32(0)
-	case TagTypeBool:
-		defaultValue = false
-	case TagTypeString:
-		defaultValue = ""
-	}
+	defaultValue := zeroValue(tagType)
 
 	return &Tag{
 		Name:        name,
@@ -48,18 +115,80 @@ func NewTag(name, address, description string, tagType TagType) *Tag {
 		Value:       defaultValue,
 		Address:     address,
 		Description: description,
-		Quality:     true,
+		Quality:     QualityGood,
 		Timestamp:   time.Now(),
 	}
 }
 
+// NewArrayTag creates a new one-dimensional array tag with length zero-valued elements
+func NewArrayTag(name, address, description string, tagType TagType, length int) *Tag {
+	tag := NewTag(name, address, description, tagType)
+	tag.Length = length
+	tag.Value = initialValue(tagType, length)
+	return tag
+}
+
+// initialValue returns the zero value of a tag (length zero-valued elements for array tags)
+func initialValue(tagType TagType, length int) interface{} {
+	if length == 0 {
+		return zeroValue(tagType)
+	}
+	return reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(zeroValue(tagType))), length, length).Interface()
+}
+
+// Clear sets the tag to the zero value of its type
+func (t *Tag) Clear() {
+	t.mu.Lock()
+	t.Value = initialValue(t.Type, t.Length)
+	t.Timestamp = time.Now()
+	t.mu.Unlock()
+	t.changed()
+}
+
+// changed notifies the TagManager of a new value or quality (called without the lock held)
+func (t *Tag) changed() {
+	t.mu.RLock()
+	onChange := t.onChange
+	t.mu.RUnlock()
+	if onChange != nil {
+		onChange(t)
+	}
+}
+
+// attach sends the change notifications of a tag to its TagManager
+func (t *Tag) attach(onChange func(*Tag)) {
+	t.mu.Lock()
+	t.onChange = onChange
+	t.mu.Unlock()
+}
+
+// detach stops change notifications of a tag removed from its TagManager
+func (t *Tag) detach() {
+	t.mu.Lock()
+	t.onChange = nil
+	t.mu.Unlock()
+}
+
+// IsArray reports whether the tag holds a one-dimensional array
+func (t *Tag) IsArray() bool {
+	return t.Length > 0
+}
+
 // GetValue returns the tag value (thread-safe)
+// Array values are replaced on every SetValue and never modified in place.
 func (t *Tag) GetValue() interface{} {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return t.Value
 }
 
+// Sample returns the value, quality and timestamp of the tag as one consistent sample
+func (t *Tag) Sample() (interface{}, Quality, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Value, t.Quality, t.Timestamp
+}
+
 // GetFloat64 returns the tag value as float64
 func (t *Tag) GetFloat64() (float64, error) {
 	t.mu.RLock()
@@ -78,6 +207,9 @@ func (t *Tag) GetFloat64() (float64, error) {
 		}
 		return 0.0, nil
 	default:
+		if f, err := toFloat(t.Type, t.Value); err == nil {
+			return f, nil
+		}
 		return 0, fmt.Errorf("tag %s: cannot convert %T to float64", t.Name, t.Value)
 	}
 }
@@ -123,65 +255,109 @@ func (t *Tag) GetBool() (bool, error) {
 	}
 }
 
-// SetValue sets the tag value (thread-safe)
+// SetValue sets the tag value (thread-safe) and notifies the change listeners
 func (t *Tag) SetValue(value interface{}) error {
+	if err := t.setValue(value, nil); err != nil {
+		return err
+	}
+	t.changed()
+	return nil
+}
+
+// SetValueQuality sets the value and the quality as one sample,
+// so the value is never published with the previous quality
+func (t *Tag) SetValueQuality(value interface{}, quality Quality) error {
+	if err := t.setValue(value, &quality); err != nil {
+		return err
+	}
+	t.changed()
+	return nil
+}
+
+// setValue converts and stores the value and, if given, the quality with the current time as timestamp
+func (t *Tag) setValue(value interface{}, quality *Quality) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Type validation
-	switch t.Type {
-	case TagTypeFloat64:
-		switch v := value.(type) {
-		case float64:
-			t.Value = v
-		case int:
-			t.Value = float64(v)
-		case int32:
-			t.Value = float64(v)
-		default:
-			return fmt.Errorf("tag %s: invalid type %T for Float64 tag", t.Name, value)
-		}
-	case TagTypeInt32:
-		switch v := value.(type) {
-		case int32:
-			t.Value = v
-		case int:
-			t.Value = int32(v)
-		case float64:
-			t.Value = int32(v)
-		default:
-			return fmt.Errorf("tag %s: invalid type %T for Int32 tag", t.Name, value)
+	if t.IsArray() {
+		if err := t.setArray(value); err != nil {
+			return err
 		}
-	case TagTypeBool:
-		switch v := value.(type) {
-		case bool:
-			t.Value = v
-		case int:
-			t.Value = v != 0
-		case int32:
-			t.Value = v != 0
-		case float64:
-			t.Value = v != 0
-		default:
-			return fmt.Errorf("tag %s: invalid type %T for Bool tag", t.Name, value)
+	} else {
+		converted, err := t.convert(value)
+		if err != nil {
+			return err
 		}
-	case TagTypeString:
-		t.Value = fmt.Sprintf("%v", value)
+		t.Value = converted
 	}
 
+	if quality != nil {
+		t.Quality = *quality
+	}
 	t.Timestamp = time.Now()
 	return nil
 }
 
-// SetQuality sets the data quality
-func (t *Tag) SetQuality(quality bool) {
+// setArray converts every element of a slice value to the tag type (caller holds the lock)
+func (t *Tag) setArray(value interface{}) error {
+	elements := reflect.ValueOf(value)
+	if elements.Kind() != reflect.Slice {
+		return fmt.Errorf("tag %s: invalid type %T for array tag", t.Name, value)
+	}
+	if elements.Len() > t.Length {
+		return fmt.Errorf("tag %s: %d elements exceed array length %d", t.Name, elements.Len(), t.Length)
+	}
+
+	converted := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(zeroValue(t.Type))), elements.Len(), elements.Len())
+	for i := 0; i < elements.Len(); i++ {
+		element, err := t.convert(elements.Index(i).Interface())
+		if err != nil {
+			return fmt.Errorf("%w (element %d)", err, i)
+		}
+		converted.Index(i).Set(reflect.ValueOf(element))
+	}
+
+	t.Value = converted.Interface()
+	return nil
+}
+
+// CheckType checks that a value has exactly the Go type of the tag value (a slice of it for array tags).
+// Client writes are not converted, so a float is not truncated into an integer tag.
+func (t *Tag) CheckType(value interface{}) error {
+	expected := reflect.TypeOf(zeroValue(t.Type))
+	if t.IsArray() {
+		expected = reflect.SliceOf(expected)
+	}
+	if reflect.TypeOf(value) != expected {
+		return fmt.Errorf("tag %s: %T is a %w for %s", t.Name, value, ErrTypeMismatch, expected)
+	}
+	return nil
+}
+
+// convert validates a scalar value and converts it to the tag type
+func (t *Tag) convert(value interface{}) (interface{}, error) {
+	converted, err := ConvertValue(t.Type, value)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s: %w", t.Name, err)
+	}
+	return converted, nil
+}
+
+// SetQuality sets the data quality. A changed quality is a new sample with the current time as timestamp.
+func (t *Tag) SetQuality(quality Quality) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
+	if t.Quality == quality {
+		t.mu.Unlock()
+		return
+	}
 	t.Quality = quality
+	t.Timestamp = time.Now()
+	t.mu.Unlock()
+	t.changed()
 }
 
 // GetQuality returns the data quality
-func (t *Tag) GetQuality() bool {
+func (t *Tag) GetQuality() Quality {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return t.Quality
@@ -194,10 +370,47 @@ func (t *Tag) GetTimestamp() time.Time {
 	return t.Timestamp
 }
 
+// TagWriteListener is called after a tag has been written by an external client
+type TagWriteListener func(tag *Tag)
+
+// TagChangeListener is called after the value or quality of a tag has changed (by any writer).
+// It is called from the goroutine of the writer, possibly concurrently for different tags.
+type TagChangeListener func(tag *Tag)
+
+// ModelChange is the kind of a runtime change of the tag set
+type ModelChange int
+
+const (
+	TagAdded    ModelChange = iota // a new tag was added
+	TagRemoved                     // a tag was removed
+	TagReplaced                    // a tag was replaced by a tag with the same name (e.g. a new data type)
+)
+
+// String returns the name of the model change
+func (mc ModelChange) String() string {
+	switch mc {
+	case TagAdded:
+		return "added"
+	case TagRemoved:
+		return "removed"
+	case TagReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// TagModelListener is called after a tag has been added, removed or replaced.
+// For TagRemoved the removed tag is passed, for TagReplaced the new tag.
+type TagModelListener func(tag *Tag, change ModelChange)
+
 // TagManager manages all PLC tags
 type TagManager struct {
-	tags map[string]*Tag
-	mu   sync.RWMutex
+	tags            map[string]*Tag
+	writeListeners  []TagWriteListener
+	changeListeners []TagChangeListener
+	modelListeners  []TagModelListener
+	mu              sync.RWMutex
 }
 
 // NewTagManager creates a new tag manager
@@ -207,16 +420,94 @@ func NewTagManager() *TagManager {
 	}
 }
 
-// AddTag adds a new tag
-func (tm *TagManager) AddTag(tag *Tag) error {
+// AddWriteListener registers a listener that is notified of external tag writes
+func (tm *TagManager) AddWriteListener(listener TagWriteListener) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.writeListeners = append(tm.writeListeners, listener)
+}
+
+// AddChangeListener registers a listener that is notified of every tag value or quality change
+func (tm *TagManager) AddChangeListener(listener TagChangeListener) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.changeListeners = append(tm.changeListeners, listener)
+}
+
+// AddModelListener registers a listener that is notified when tags are added, removed or replaced
+func (tm *TagManager) AddModelListener(listener TagModelListener) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.modelListeners = append(tm.modelListeners, listener)
+}
 
+// notifyModel calls the model listeners for a tag
+func (tm *TagManager) notifyModel(tag *Tag, change ModelChange) {
+	tm.mu.RLock()
+	listeners := tm.modelListeners
+	tm.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(tag, change)
+	}
+}
+
+// notifyChange calls the change listeners for a tag
+func (tm *TagManager) notifyChange(tag *Tag) {
+	tm.mu.RLock()
+	listeners := tm.changeListeners
+	tm.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(tag)
+	}
+}
+
+// AddTag adds a new tag
+func (tm *TagManager) AddTag(tag *Tag) error {
+	tm.mu.Lock()
 	if _, exists := tm.tags[tag.Name]; exists {
+		tm.mu.Unlock()
 		return fmt.Errorf("tag '%s' already exists", tag.Name)
 	}
+	tag.attach(tm.notifyChange)
+	tm.tags[tag.Name] = tag
+	tm.mu.Unlock()
+
+	tm.notifyModel(tag, TagAdded)
+	return nil
+}
+
+// RemoveTag removes a tag. The removed tag no longer notifies change listeners.
+func (tm *TagManager) RemoveTag(name string) error {
+	tm.mu.Lock()
+	tag, exists := tm.tags[name]
+	if !exists {
+		tm.mu.Unlock()
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+	delete(tm.tags, name)
+	tm.mu.Unlock()
 
+	tag.detach()
+	tm.notifyModel(tag, TagRemoved)
+	return nil
+}
+
+// ReplaceTag replaces the tag with the same name, e.g. to change its data type
+func (tm *TagManager) ReplaceTag(tag *Tag) error {
+	tm.mu.Lock()
+	old, exists := tm.tags[tag.Name]
+	if !exists {
+		tm.mu.Unlock()
+		return fmt.Errorf("tag '%s' not found", tag.Name)
+	}
+	tag.attach(tm.notifyChange)
 	tm.tags[tag.Name] = tag
+	tm.mu.Unlock()
+
+	old.detach()
+	tm.notifyModel(tag, TagReplaced)
 	return nil
 }
 
@@ -271,6 +562,59 @@ func (tm *TagManager) SetTagValue(name string, value interface{}) error {
 	return tag.SetValue(value)
 }
 
+// GetTagQuality gets tag quality by name
+func (tm *TagManager) GetTagQuality(name string) (Quality, error) {
+	tag, err := tm.GetTag(name)
+	if err != nil {
+		return 0, err
+	}
+	return tag.GetQuality(), nil
+}
+
+// SetTagQuality sets tag quality by name
+func (tm *TagManager) SetTagQuality(name string, quality Quality) error {
+	tag, err := tm.GetTag(name)
+	if err != nil {
+		return err
+	}
+	tag.SetQuality(quality)
+	return nil
+}
+
+// WriteTagValue sets tag value on behalf of an external client (e.g. OPC UA write)
+// and notifies the registered write listeners. The value must have the type of the tag (ErrTypeMismatch).
+func (tm *TagManager) WriteTagValue(name string, value interface{}) error {
+	return tm.writeTag(name, value, nil)
+}
+
+// WriteTagSample is WriteTagValue that also sets the tag quality together with the value
+func (tm *TagManager) WriteTagSample(name string, value interface{}, quality Quality) error {
+	return tm.writeTag(name, value, &quality)
+}
+
+func (tm *TagManager) writeTag(name string, value interface{}, quality *Quality) error {
+	tag, err := tm.GetTag(name)
+	if err != nil {
+		return err
+	}
+	if err := tag.CheckType(value); err != nil {
+		return err
+	}
+	if err := tag.setValue(value, quality); err != nil {
+		return err
+	}
+	tag.changed()
+
+	tm.mu.RLock()
+	listeners := tm.writeListeners
+	tm.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(tag)
+	}
+	return nil
+}
+
 // GetTagCount returns the number of tags
 func (tm *TagManager) GetTagCount() int {
 	tm.mu.RLock()
@@ -12,15 +12,10 @@ func GenerateTagsFromSensors(sensorDefs []config.SensorDefinition) (*TagManager,
 	tagManager := NewTagManager()
 
 	for _, sensor := range sensorDefs {
-		tagType := determineTagType(sensor.Address, sensor.Type)
-
-		tag := NewTag(
-			sensor.Name,
-			sensor.Address,
-			sensor.Description,
-			tagType,
-		)
-
+		tag, err := NewSensorTag(sensor)
+		if err != nil {
+			return nil, err
+		}
 		if err := tagManager.AddTag(tag); err != nil {
 			return nil, fmt.Errorf("failed to add tag '%s': %w", sensor.Name, err)
 		}
@@ -30,6 +25,44 @@ func GenerateTagsFromSensors(sensorDefs []config.SensorDefinition) (*TagManager,
 	return tagManager, nil
 }
 
+// NewSensorTag creates the tag of a sensor definition
+func NewSensorTag(sensor config.SensorDefinition) (*Tag, error) {
+	tagType := determineTagType(sensor.Address, sensor.Type)
+	if sensor.DataType != "" {
+		var err error
+		if tagType, err = ParseTagType(sensor.DataType); err != nil {
+			return nil, fmt.Errorf("sensor '%s': %w", sensor.Name, err)
+		}
+	}
+
+	var tag *Tag
+	if sensor.ArrayLength > 0 {
+		tag = NewArrayTag(sensor.Name, sensor.Address, sensor.Description, tagType, sensor.ArrayLength)
+	} else {
+		tag = NewTag(
+			sensor.Name,
+			sensor.Address,
+			sensor.Description,
+			tagType,
+		)
+	}
+	tag.BrowsePath = sensor.BrowsePath
+	tag.DisplayNames = sensor.DisplayNames
+	tag.Descriptions = sensor.Descriptions
+	tag.ReadOnly = sensor.TagAccess() == config.AccessReadOnly
+	tag.Permissions = rolePermissions(sensor.Permissions)
+	tag.Alarms = sensor.Alarms
+	tag.Units = sensor.EngineeringUnits
+	tag.EURange = sensor.EURange
+	tag.Instrument = sensor.InstrumentRange
+	tag.Deadband = sensor.DeadbandPercent
+	tag.NodeID = sensor.NodeID
+	tag.Namespace = sensor.NamespaceURI
+	tag.Aliases = sensor.Aliases
+	tag.UpdateMs = sensor.UpdateIntervalMs
+	return tag, nil
+}
+
 // determineTagType determines the tag type based on address and sensor type
 func determineTagType(address, sensorType string) TagType {
 	// Check address prefix
@@ -46,7 +79,7 @@ func determineTagType(address, sensorType string) TagType {
 
 	// Fallback: determine by sensor type
 	switch sensorType {
-	case "temperature", "pressure", "sine", "random", "vibration", "noise":
+	case "temperature", "pressure", "sine", "random", "vibration", "noise", "waveform", "vibrationwaveform":
 		return TagTypeFloat64
 	case "stepmotor", "servomotor":
 		return TagTypeFloat64
@@ -72,30 +105,25 @@ func PrintTagSummary(tagManager *TagManager) {
 	}
 
 	fmt.Println("By Type:")
-	if count, ok := typeCount[TagTypeFloat64]; ok {
-		fmt.Printf("  Float64: %d tags\n", count)
-	}
-	if count, ok := typeCount[TagTypeInt32]; ok {
-		fmt.Printf("  Int32:   %d tags\n", count)
-	}
-	if count, ok := typeCount[TagTypeBool]; ok {
-		fmt.Printf("  Bool:    %d tags\n", count)
-	}
-	if count, ok := typeCount[TagTypeString]; ok {
-		fmt.Printf("  String:  %d tags\n", count)
+	for tagType := TagTypeFloat64; tagType <= TagTypeLocalizedText; tagType++ {
+		if count, ok := typeCount[tagType]; ok {
+			fmt.Printf("  %-14s %d tags\n", getTagTypeString(tagType)+":", count)
+		}
 	}
-
 	fmt.Println("\nTag List:")
-	fmt.Printf("%-40s %-12s %-10s %s\n", "Name", "Address", "Type", "Description")
+	fmt.Printf("%-40s %-12s %-13s %s\n", "Name", "Address", "Type", "Description")
 	fmt.Println(strings.Repeat("-", 100))
 
 	for _, tag := range tags {
 		typeStr := getTagTypeString(tag.Type)
+		if tag.IsArray() {
+			typeStr += fmt.Sprintf("[%d]", tag.Length)
+		}
 		desc := tag.Description
 		if len(desc) > 35 {
 			desc = desc[:32] + "..."
 		}
-		fmt.Printf("%-40s %-12s %-10s %s\n", tag.Name, tag.Address, typeStr, desc)
+		fmt.Printf("%-40s %-12s %-13s %s\n", tag.Name, tag.Address, typeStr, desc)
 	}
 	fmt.Println()
 }
@@ -111,6 +139,28 @@ func getTagTypeString(tagType TagType) string {
 		return "Bool"
 	case TagTypeString:
 		return "String"
+	case TagTypeSByte:
+		return "SByte"
+	case TagTypeByte:
+		return "Byte"
+	case TagTypeInt16:
+		return "Int16"
+	case TagTypeUInt16:
+		return "UInt16"
+	case TagTypeUInt32:
+		return "UInt32"
+	case TagTypeInt64:
+		return "Int64"
+	case TagTypeUInt64:
+		return "UInt64"
+	case TagTypeFloat32:
+		return "Float32"
+	case TagTypeDateTime:
+		return "DateTime"
+	case TagTypeByteString:
+		return "ByteString"
+	case TagTypeLocalizedText:
+		return "LocalizedText"
 	default:
 		return "Unknown"
 	}
@@ -131,6 +181,9 @@ func GenerateLuaTemplate(tagManager *TagManager) string {
 
 	for _, tag := range tags {
 		typeStr := getTagTypeString(tag.Type)
+		if tag.IsArray() {
+			typeStr += fmt.Sprintf("[%d], Lua table", tag.Length)
+		}
 		template += fmt.Sprintf("  Data.%-35s -- %s (%s, %s)\n",
 			tag.Name,
 			tag.Description,
@@ -206,3 +259,15 @@ end
 
 	return template
 }
+
+// rolePermissions returns the permissions with role names normalized to lowercase, nil for none
+func rolePermissions(permissions map[string]string) map[string]string {
+	if len(permissions) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(permissions))
+	for role, access := range permissions {
+		normalized[config.NormalizeRole(role)] = access
+	}
+	return normalized
+}
@@ -0,0 +1,31 @@
+package plc
+
+import (
+	"go-opcua-sim/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestNewSensorTagPermissions(t *testing.T) {
+	sensor := config.SensorDefinition{
+		Name:        "Setpoint",
+		Type:        "memory",
+		Address:     "%MW0",
+		Permissions: map[string]string{"Operator": "rw", "Anonymous": "none"},
+	}
+	tag, err := NewSensorTag(sensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"operator": "rw", "anonymous": "none"}; !reflect.DeepEqual(tag.Permissions, want) {
+		t.Errorf("tag permissions = %v, want %v", tag.Permissions, want)
+	}
+	if _, ok := sensor.Permissions["Operator"]; !ok {
+		t.Error("sensor permissions modified")
+	}
+
+	sensor.Permissions = nil
+	if tag, _ := NewSensorTag(sensor); tag.Permissions != nil {
+		t.Errorf("tag permissions = %v, want nil", tag.Permissions)
+	}
+}
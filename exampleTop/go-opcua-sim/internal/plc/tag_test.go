@@ -0,0 +1,44 @@
+package plc
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTagManagerAttachesWrittenTags(t *testing.T) {
+	tagManager := NewTagManager()
+	var notified atomic.Int32
+	tagManager.AddChangeListener(func(tag *Tag) { notified.Add(1) })
+
+	// The tag is written while it is added, replaced and removed (run with -race)
+	tag := NewTag("Level", "%DF0", "", TagTypeFloat64)
+	replacement := NewTag("Level", "%DF0", "", TagTypeFloat64)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			tag.SetValue(float64(i))
+			replacement.SetValue(float64(i))
+		}
+	}()
+	if err := tagManager.AddTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if err := tagManager.ReplaceTag(replacement); err != nil {
+		t.Fatal(err)
+	}
+	if err := tagManager.RemoveTag("Level"); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	// A removed tag no longer notifies the listeners
+	before := notified.Load()
+	replacement.SetValue(1.0)
+	tag.SetValue(1.0)
+	if notified.Load() != before {
+		t.Error("removed tag notified the change listeners")
+	}
+}
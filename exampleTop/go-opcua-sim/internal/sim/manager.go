@@ -1,11 +1,14 @@
 package sim
 
 import (
+	"errors"
 	"fmt"
 	"go-opcua-sim/internal/config"
 	"go-opcua-sim/internal/plc"
 	"go-opcua-sim/internal/sim/sensors"
 	"log"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +22,28 @@ type SensorManager struct {
 	lastUpdate   time.Time
 	mu           sync.RWMutex
 	updateCount  uint64
+	qualities    map[string]plc.Quality // Quality last published for each sensor
+	definitions  []config.SensorDefinition // Definitions of the current sensors, in sensor order
+	modelMu      sync.Mutex                // Serializes update cycles and runtime sensor changes
+	locks        map[sensors.Sensor]*sync.Mutex // Serializes the simulation of each sensor with commands and state reads
+	schedules    map[sensors.Sensor]schedule    // Last and next update of each sensor
+	cycle        time.Duration                  // Period of the update loop
+}
+
+// schedule holds the update times of a sensor
+type schedule struct {
+	last time.Time
+	next time.Time
+}
+
+// UpdateCycle is the period of the sensor update loop, the shortest effective update interval of a sensor
+const UpdateCycle = 100 * time.Millisecond
+
+// ConfigChanges lists the sensors changed by ApplyConfig
+type ConfigChanges struct {
+	Added    []string
+	Removed  []string
+	Replaced []string
 }
 
 // NewSensorManager creates a new sensor manager
@@ -28,7 +53,12 @@ func NewSensorManager(tagManager *plc.TagManager, cfg *config.SensorConfig) (*Se
 		tagManager: tagManager,
 		stopChan:   make(chan bool),
 		lastUpdate: time.Now(),
+		qualities:  make(map[string]plc.Quality),
+		locks:      make(map[sensors.Sensor]*sync.Mutex),
+		schedules:  make(map[sensors.Sensor]schedule),
+		cycle:      UpdateCycle,
 	}
+	manager.definitions = append(manager.definitions, cfg.Sensors...)
 
 	// Create sensors from configuration
 	for _, def := range cfg.Sensors {
@@ -36,6 +66,9 @@ func NewSensorManager(tagManager *plc.TagManager, cfg *config.SensorConfig) (*Se
 		if err != nil {
 			return nil, fmt.Errorf("failed to create sensor '%s': %w", def.Name, err)
 		}
+		if err := manager.applyInitialValue(sensor); err != nil {
+			return nil, fmt.Errorf("sensor '%s' has invalid initialValue: %w", def.Name, err)
+		}
 		manager.sensors = append(manager.sensors, sensor)
 		log.Printf("Created sensor: %s (type=%s, address=%s)", def.Name, def.Type, def.Address)
 	}
@@ -44,11 +77,416 @@ func NewSensorManager(tagManager *plc.TagManager, cfg *config.SensorConfig) (*Se
 		return nil, fmt.Errorf("no sensors created")
 	}
 
+	// Forward client writes to the actuators
+	tagManager.AddWriteListener(manager.handleTagWrite)
+
 	return manager, nil
 }
 
-// Start starts the sensor update loop
+// handleTagWrite applies an externally written tag value to the matching actuator
+func (sm *SensorManager) handleTagWrite(tag *plc.Tag) {
+	actuator, ok := sm.GetSensor(tag.Name).(sensors.Actuator)
+	if !ok {
+		return
+	}
+
+	value, err := tag.GetFloat64()
+	if err != nil {
+		log.Printf("Error commanding actuator %s: %v", tag.Name, err)
+		return
+	}
+
+	lock := sm.lock(actuator)
+	lock.Lock()
+	actuator.Command(value)
+	lock.Unlock()
+	log.Printf("Actuator %s commanded to %.3f", tag.Name, value)
+}
+
+// lock returns the lock of a sensor. The sensor types are not safe for concurrent use, so Update runs
+// under it and so do the commands and state reads coming from clients while a cycle is running.
+func (sm *SensorManager) lock(sensor sensors.Sensor) *sync.Mutex {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	lock, ok := sm.locks[sensor]
+	if !ok {
+		lock = &sync.Mutex{}
+		sm.locks[sensor] = lock
+	}
+	return lock
+}
+
+// applyInitialValue writes the initial value of a memory variable to its tag
+func (sm *SensorManager) applyInitialValue(sensor sensors.Sensor) error {
+	if _, ok := sensor.(sensors.MemoryVariable); !ok {
+		return nil
+	}
+	tag, err := sm.tagManager.GetTag(sensor.GetName())
+	if err != nil {
+		return err
+	}
+	return initializeTag(sensor, tag)
+}
+
+// initializeTag writes the initial value of a memory variable to a tag
+func initializeTag(sensor sensors.Sensor, tag *plc.Tag) error {
+	memory, ok := sensor.(sensors.MemoryVariable)
+	if !ok {
+		return nil
+	}
+	if initial := memory.InitialValue(); initial != nil {
+		return tag.SetValue(initial)
+	}
+	tag.Clear()
+	return nil
+}
+
+// ResetSensor resets a sensor to its initial state
+func (sm *SensorManager) ResetSensor(name string) error {
+	sensor := sm.GetSensor(name)
+	if sensor == nil {
+		return fmt.Errorf("sensor not found: %s", name)
+	}
+	lock := sm.lock(sensor)
+	lock.Lock()
+	sensor.Reset()
+	lock.Unlock()
+	if err := sm.applyInitialValue(sensor); err != nil {
+		return err
+	}
+	log.Printf("Sensor %s reset", name)
+	return nil
+}
+
+// SetSensorEnabled starts or stops the simulation of a sensor
+func (sm *SensorManager) SetSensorEnabled(name string, enabled bool) error {
+	sensor := sm.GetSensor(name)
+	if sensor == nil {
+		return fmt.Errorf("sensor not found: %s", name)
+	}
+	sensor.SetEnabled(enabled)
+	log.Printf("Sensor %s enabled=%t", name, enabled)
+	return nil
+}
+
+// SetSensorQuality sets the quality of the sensor values (kept until the next change or Reset).
+// Memory variables are not simulated, so their tag quality is set directly.
+func (sm *SensorManager) SetSensorQuality(name string, quality plc.Quality) error {
+	sensor := sm.GetSensor(name)
+	if sensor == nil {
+		return fmt.Errorf("sensor not found: %s", name)
+	}
+	if _, ok := sensor.(sensors.MemoryVariable); ok {
+		if err := sm.tagManager.SetTagQuality(name, quality); err != nil {
+			return err
+		}
+	}
+	sensor.SetQuality(quality)
+	log.Printf("Sensor %s quality=%s", name, quality)
+	return nil
+}
+
+// SensorQuality returns the quality of the sensor values, the tag quality for memory variables
+func (sm *SensorManager) SensorQuality(name string) (plc.Quality, error) {
+	sensor := sm.GetSensor(name)
+	if sensor == nil {
+		return 0, fmt.Errorf("sensor not found: %s", name)
+	}
+	if _, ok := sensor.(sensors.MemoryVariable); ok {
+		tag, err := sm.tagManager.GetTag(name)
+		if err != nil {
+			return 0, err
+		}
+		_, quality, _ := tag.Sample()
+		return quality, nil
+	}
+	return sensor.Quality(), nil
+}
+
+// CommandSensor sets the target of an actuator and returns the applied target
+func (sm *SensorManager) CommandSensor(name string, value float64) (float64, error) {
+	actuator, ok := sm.GetSensor(name).(sensors.Actuator)
+	if !ok {
+		return 0, fmt.Errorf("sensor is not an actuator: %s", name)
+	}
+	lock := sm.lock(actuator)
+	lock.Lock()
+	defer lock.Unlock()
+	actuator.Command(value)
+	log.Printf("Actuator %s commanded to %.3f", name, value)
+	return actuator.Target(), nil
+}
+
+// SetSensorLoad sets the external load torque of a motor
+func (sm *SensorManager) SetSensorLoad(name string, torque float64) error {
+	motor, ok := sm.GetSensor(name).(sensors.LoadController)
+	if !ok {
+		return fmt.Errorf("sensor has no load control: %s", name)
+	}
+	lock := sm.lock(motor)
+	lock.Lock()
+	motor.SetLoadTorque(torque)
+	lock.Unlock()
+	log.Printf("Motor %s load torque set to %.3f Nm", name, torque)
+	return nil
+}
+
+// MotorState returns the complete state of a motor
+func (sm *SensorManager) MotorState(name string) (sensors.MotorState, error) {
+	motor, ok := sm.GetSensor(name).(sensors.StateReporter)
+	if !ok {
+		return sensors.MotorState{}, fmt.Errorf("sensor has no motor state: %s", name)
+	}
+	lock := sm.lock(motor)
+	lock.Lock()
+	defer lock.Unlock()
+	return motor.State(), nil
+}
+
+// SensorRange returns the output range of an analog sensor
+func (sm *SensorManager) SensorRange(name string) (float64, float64, bool) {
+	ranged, ok := sm.GetSensor(name).(sensors.RangedSensor)
+	if !ok {
+		return 0, 0, false
+	}
+	low, high := ranged.Range()
+	return low, high, true
+}
+
+// WriteRange returns the values a client may write to the tag of a sensor: the command range
+// of an actuator or the output range of another analog sensor. ok is false when any value is accepted.
+func (sm *SensorManager) WriteRange(name string) (float64, float64, bool) {
+	switch sensor := sm.GetSensor(name).(type) {
+	case sensors.CommandRanger:
+		low, high := sensor.CommandRange()
+		return low, high, true
+	case sensors.Actuator:
+		return 0, 0, false
+	case sensors.RangedSensor:
+		low, high := sensor.Range()
+		return low, high, true
+	}
+	return 0, 0, false
+}
+
+// AddSensor creates a sensor and its tag at runtime
+func (sm *SensorManager) AddSensor(def config.SensorDefinition) error {
+	sm.modelMu.Lock()
+	defer sm.modelMu.Unlock()
+
+	if sm.GetSensor(def.Name) != nil {
+		return fmt.Errorf("sensor already exists: %s", def.Name)
+	}
+	if err := sm.validateChange(def); err != nil {
+		return err
+	}
+	return sm.addSensor(def)
+}
+
+// RemoveSensor removes a sensor and its tag at runtime
+func (sm *SensorManager) RemoveSensor(name string) error {
+	sm.modelMu.Lock()
+	defer sm.modelMu.Unlock()
+	return sm.removeSensor(name)
+}
+
+// ReplaceSensor replaces a sensor and its tag with a new definition of the same name at runtime,
+// e.g. to change the sensor type, data type or browse path
+func (sm *SensorManager) ReplaceSensor(def config.SensorDefinition) error {
+	sm.modelMu.Lock()
+	defer sm.modelMu.Unlock()
+
+	if sm.GetSensor(def.Name) == nil {
+		return fmt.Errorf("sensor not found: %s", def.Name)
+	}
+	if err := sm.validateChange(def); err != nil {
+		return err
+	}
+	return sm.replaceSensor(def)
+}
+
+// ApplyConfig changes the sensors to a reloaded configuration: sensors missing in the configuration are removed,
+// changed definitions are replaced and new sensors are added. Unchanged sensors keep running.
+func (sm *SensorManager) ApplyConfig(cfg *config.SensorConfig) (ConfigChanges, error) {
+	sm.modelMu.Lock()
+	defer sm.modelMu.Unlock()
+
+	var changes ConfigChanges
+	var errs []error
+
+	wanted := make(map[string]bool, len(cfg.Sensors))
+	for _, def := range cfg.Sensors {
+		wanted[def.Name] = true
+	}
+
+	// Remove first, so addresses and browse paths can be reused by the new sensors
+	for _, def := range append([]config.SensorDefinition(nil), sm.definitions...) {
+		if wanted[def.Name] {
+			continue
+		}
+		if err := sm.removeSensor(def.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changes.Removed = append(changes.Removed, def.Name)
+	}
+
+	for _, def := range cfg.Sensors {
+		current, exists := sm.definition(def.Name)
+		switch {
+		case !exists:
+			if err := sm.addSensor(def); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			changes.Added = append(changes.Added, def.Name)
+		case !reflect.DeepEqual(current, def):
+			if err := sm.replaceSensor(def); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			changes.Replaced = append(changes.Replaced, def.Name)
+		}
+	}
+
+	return changes, errors.Join(errs...)
+}
+
+// validateChange validates the current definitions with a new or changed definition.
+// Device namespaces are not checked, they are fixed at startup.
+func (sm *SensorManager) validateChange(def config.SensorDefinition) error {
+	candidate := &config.SensorConfig{}
+	for _, current := range sm.definitions {
+		if current.Name != def.Name {
+			candidate.Sensors = append(candidate.Sensors, current)
+		}
+	}
+	candidate.Sensors = append(candidate.Sensors, def)
+	if err := config.Validate(candidate); err != nil {
+		return fmt.Errorf("invalid sensor definition: %w", err)
+	}
+	return nil
+}
+
+// definition returns the definition of a current sensor (caller holds modelMu)
+func (sm *SensorManager) definition(name string) (config.SensorDefinition, bool) {
+	for _, def := range sm.definitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return config.SensorDefinition{}, false
+}
+
+// newSensor creates a sensor and its initialized tag
+func newSensor(def config.SensorDefinition) (sensors.Sensor, *plc.Tag, error) {
+	sensor, err := CreateSensor(def)
+	if err != nil {
+		return nil, nil, err
+	}
+	tag, err := plc.NewSensorTag(def)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := initializeTag(sensor, tag); err != nil {
+		return nil, nil, fmt.Errorf("sensor '%s' has invalid initialValue: %w", def.Name, err)
+	}
+	return sensor, tag, nil
+}
+
+// addSensor adds a sensor and its tag (caller holds modelMu).
+// The sensor is registered first, so tag listeners can look it up.
+func (sm *SensorManager) addSensor(def config.SensorDefinition) error {
+	sensor, tag, err := newSensor(def)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	sm.sensors = append(sm.sensors[:len(sm.sensors):len(sm.sensors)], sensor)
+	sm.mu.Unlock()
+
+	if err := sm.tagManager.AddTag(tag); err != nil {
+		sm.dropSensor(def.Name)
+		return err
+	}
+	sm.definitions = append(sm.definitions, def)
+	log.Printf("Added sensor: %s (type=%s, address=%s)", def.Name, def.Type, def.Address)
+	return nil
+}
+
+// removeSensor removes a sensor and its tag (caller holds modelMu)
+func (sm *SensorManager) removeSensor(name string) error {
+	if !sm.dropSensor(name) {
+		return fmt.Errorf("sensor not found: %s", name)
+	}
+	delete(sm.qualities, name)
+	for i, def := range sm.definitions {
+		if def.Name == name {
+			sm.definitions = append(sm.definitions[:i:i], sm.definitions[i+1:]...)
+			break
+		}
+	}
+
+	if err := sm.tagManager.RemoveTag(name); err != nil {
+		return err
+	}
+	log.Printf("Removed sensor: %s", name)
+	return nil
+}
+
+// replaceSensor swaps a sensor and its tag for a new definition (caller holds modelMu)
+func (sm *SensorManager) replaceSensor(def config.SensorDefinition) error {
+	sensor, tag, err := newSensor(def)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	replaced := make([]sensors.Sensor, len(sm.sensors))
+	for i, current := range sm.sensors {
+		replaced[i] = current
+		if current.GetName() == def.Name {
+			replaced[i] = sensor
+		}
+	}
+	sm.sensors = replaced
+	sm.mu.Unlock()
+	delete(sm.qualities, def.Name)
+
+	if err := sm.tagManager.ReplaceTag(tag); err != nil {
+		return err
+	}
+	for i := range sm.definitions {
+		if sm.definitions[i].Name == def.Name {
+			sm.definitions[i] = def
+		}
+	}
+	log.Printf("Replaced sensor: %s (type=%s, address=%s)", def.Name, def.Type, def.Address)
+	return nil
+}
+
+// dropSensor removes a sensor from the sensor list. The list is copied, so a running
+// update cycle or a caller of GetAllSensors keeps a consistent slice.
+func (sm *SensorManager) dropSensor(name string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for i, sensor := range sm.sensors {
+		if sensor.GetName() == name {
+			sm.sensors = append(sm.sensors[:i:i], sm.sensors[i+1:]...)
+			delete(sm.locks, sensor)
+			delete(sm.schedules, sensor)
+			return true
+		}
+	}
+	return false
+}
+
+// Start starts the sensor update loop. Each sensor is updated when its update interval has passed.
 func (sm *SensorManager) Start(updateInterval time.Duration) {
+	sm.cycle = updateInterval
 	sm.ticker = time.NewTicker(updateInterval)
 	sm.lastUpdate = time.Now()
 
@@ -78,7 +516,7 @@ func (sm *SensorManager) Stop() {
 // update updates all sensors and writes values to tags
 func (sm *SensorManager) update() {
 	now := time.Now()
-	deltaTime := now.Sub(sm.lastUpdate)
+	previous := sm.lastUpdate
 	sm.lastUpdate = now
 
 	sm.mu.Lock()
@@ -86,27 +524,47 @@ func (sm *SensorManager) update() {
 	count := sm.updateCount
 	sm.mu.Unlock()
 
-	// Update all sensors in parallel
+	// Sensors are not added or removed during a cycle
+	sm.modelMu.Lock()
+	defer sm.modelMu.Unlock()
+
+	// Update the sensors that are due in parallel
 	var wg sync.WaitGroup
 	for _, sensor := range sm.sensors {
+		deltaTime, due := sm.due(sensor, previous, now)
+		if !due {
+			continue
+		}
 		wg.Add(1)
-		go func(s sensors.Sensor) {
+		go func(s sensors.Sensor, deltaTime time.Duration) {
 			defer wg.Done()
 
 			if !s.IsEnabled() {
 				return
 			}
+			if _, ok := s.(sensors.MemoryVariable); ok {
+				return // the tag keeps the last written value
+			}
 
-			// Generate new value
-			value := s.Update(deltaTime)
+			// Generate new value (array sensors produce a whole array)
+			var value interface{}
+			lock := sm.lock(s)
+			lock.Lock()
+			if arraySensor, ok := s.(sensors.ArraySensor); ok {
+				value = arraySensor.UpdateArray(deltaTime)
+			} else {
+				value = s.Update(deltaTime)
+			}
+			lock.Unlock()
 
 			// Write to tag manager
 			if err := sm.tagManager.SetTagValue(s.GetName(), value); err != nil {
 				log.Printf("Error writing sensor %s to tag: %v", s.GetName(), err)
 			}
-		}(sensor)
+		}(sensor, deltaTime)
 	}
 	wg.Wait()
+	sm.publishQualities()
 
 	// Log periodically (every 2 seconds = ~20 updates at 100ms interval)
 	if count%20 == 0 {
@@ -114,6 +572,51 @@ func (sm *SensorManager) update() {
 	}
 }
 
+// due reports whether the update interval of a sensor has passed and returns the time since its last update.
+// A sensor is due within half a cycle of its next update time, so intervals that are multiples of the cycle
+// are kept despite ticker jitter and shorter intervals run every cycle (caller holds modelMu).
+func (sm *SensorManager) due(sensor sensors.Sensor, previous, now time.Time) (time.Duration, bool) {
+	s, ok := sm.schedules[sensor]
+	if !ok {
+		s = schedule{last: previous, next: now} // added since the last cycle
+	}
+	if now.Before(s.next.Add(-sm.cycle / 2)) {
+		return 0, false
+	}
+
+	deltaTime := now.Sub(s.last)
+	s.last = now
+	s.next = s.next.Add(sensor.GetUpdateInterval())
+	if s.next.Before(now) {
+		s.next = now.Add(sensor.GetUpdateInterval()) // behind after a stall, no catch-up burst
+	}
+	sm.schedules[sensor] = s
+	return deltaTime, true
+}
+
+// publishQualities writes changed sensor qualities to their tags. Disabled sensors are Bad_OutOfService.
+// Only changes are written, so a quality set on the tag by the PLC logic is kept until the sensor quality changes.
+func (sm *SensorManager) publishQualities() {
+	for _, sensor := range sm.sensors {
+		if _, ok := sensor.(sensors.MemoryVariable); ok {
+			continue // the quality is set by clients or the PLC logic
+		}
+
+		quality := sensor.Quality()
+		if !sensor.IsEnabled() {
+			quality = plc.QualityBadOutOfService
+		}
+		if quality == sm.qualities[sensor.GetName()] {
+			continue
+		}
+		if err := sm.tagManager.SetTagQuality(sensor.GetName(), quality); err != nil {
+			log.Printf("Error writing sensor %s quality to tag: %v", sensor.GetName(), err)
+			continue
+		}
+		sm.qualities[sensor.GetName()] = quality
+	}
+}
+
 // logSensorValues logs current sensor values
 func (sm *SensorManager) logSensorValues() {
 	sm.mu.RLock()
@@ -128,6 +631,8 @@ func (sm *SensorManager) logSensorValues() {
 		tag, err := sm.tagManager.GetTag(sensor.GetName())
 		if err != nil {
 			log.Printf("  %s: ERROR - %v", sensor.GetName(), err)
+		} else if tag.IsArray() {
+			log.Printf("  %s (%s): [%d] %s", sensor.GetName(), sensor.GetAddress(), tag.Length, summarizeArray(tag.GetValue()))
 		} else {
 			value, _ := tag.GetFloat64()
 			log.Printf("  %s (%s): %.3f", sensor.GetName(), sensor.GetAddress(), value)
@@ -137,6 +642,8 @@ func (sm *SensorManager) logSensorValues() {
 
 // GetSensorCount returns the number of managed sensors
 func (sm *SensorManager) GetSensorCount() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
 	return len(sm.sensors)
 }
 
@@ -166,3 +673,24 @@ func (sm *SensorManager) GetSensor(name string) sensors.Sensor {
 	}
 	return nil
 }
+
+// summarizeArray formats the first elements of an array tag value for logging
+func summarizeArray(value interface{}) string {
+	const shown = 3
+	var elements []string
+	switch v := value.(type) {
+	case []float64:
+		for i := 0; i < len(v) && i < shown; i++ {
+			elements = append(elements, fmt.Sprintf("%.3f", v[i]))
+		}
+	case []int32:
+		for i := 0; i < len(v) && i < shown; i++ {
+			elements = append(elements, fmt.Sprintf("%d", v[i]))
+		}
+	case []bool:
+		for i := 0; i < len(v) && i < shown; i++ {
+			elements = append(elements, fmt.Sprintf("%t", v[i]))
+		}
+	}
+	return "[" + strings.Join(elements, " ") + " ...]"
+}
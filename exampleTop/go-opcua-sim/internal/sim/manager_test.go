@@ -0,0 +1,172 @@
+package sim
+
+import (
+	"go-opcua-sim/internal/config"
+	"go-opcua-sim/internal/plc"
+	"go-opcua-sim/internal/sim/sensors"
+	"reflect"
+	"testing"
+)
+
+// testManager returns a sensor manager over the tags of the definitions
+func testManager(t *testing.T, defs ...config.SensorDefinition) (*SensorManager, *plc.TagManager) {
+	t.Helper()
+	tagManager, err := plc.GenerateTagsFromSensors(defs)
+	if err != nil {
+		t.Fatalf("generate tags: %v", err)
+	}
+	manager, err := NewSensorManager(tagManager, &config.SensorConfig{Sensors: defs})
+	if err != nil {
+		t.Fatalf("sensor manager: %v", err)
+	}
+	return manager, tagManager
+}
+
+func testDefinition(name, sensorType, address string, parameters map[string]interface{}) config.SensorDefinition {
+	return config.SensorDefinition{
+		Name:             name,
+		Type:             sensorType,
+		Enabled:          true,
+		Address:          address,
+		UpdateIntervalMs: 100,
+		Parameters:       parameters,
+	}
+}
+
+func TestSensorManagerModelChanges(t *testing.T) {
+	level := testDefinition("Level", "memory", "%DF0", nil)
+	manager, tagManager := testManager(t, level)
+
+	var changes []plc.ModelChange
+	tagManager.AddModelListener(func(tag *plc.Tag, change plc.ModelChange) {
+		changes = append(changes, change)
+	})
+
+	speed := testDefinition("Speed", "integer", "%DW2", map[string]interface{}{"maxValue": 50.0})
+	if err := manager.AddSensor(speed); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := manager.AddSensor(speed); err == nil {
+		t.Error("adding an existing sensor succeeded")
+	}
+	duplicate := testDefinition("Other", "memory", "%DW2", nil)
+	if err := manager.AddSensor(duplicate); err == nil {
+		t.Error("adding a sensor at a used address succeeded")
+	}
+	if manager.GetSensorCount() != 2 || !tagManager.TagExists("Speed") {
+		t.Fatalf("sensors = %d, Speed tag %v, want 2 sensors with a Speed tag", manager.GetSensorCount(), tagManager.TagExists("Speed"))
+	}
+
+	// A replaced sensor takes the new definition, its tag the new data type
+	speed.Type = "sine"
+	speed.DataType = "Double"
+	if err := manager.ReplaceSensor(speed); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if _, ok := manager.GetSensor("Speed").(sensors.CommandRanger); ok {
+		t.Error("replaced sensor is still the integer actuator")
+	}
+	if tag, _ := tagManager.GetTag("Speed"); tag.Type != plc.TagTypeFloat64 {
+		t.Errorf("replaced tag type = %v, want Float64", tag.Type)
+	}
+	if err := manager.ReplaceSensor(testDefinition("Missing", "memory", "%DF8", nil)); err == nil {
+		t.Error("replacing a missing sensor succeeded")
+	}
+
+	if err := manager.RemoveSensor("Speed"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if manager.GetSensor("Speed") != nil || tagManager.TagExists("Speed") {
+		t.Error("removed sensor or tag still present")
+	}
+	if err := manager.RemoveSensor("Speed"); err == nil {
+		t.Error("removing a missing sensor succeeded")
+	}
+
+	want := []plc.ModelChange{plc.TagAdded, plc.TagReplaced, plc.TagRemoved}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("model changes = %v, want %v", changes, want)
+	}
+}
+
+func TestSensorManagerApplyConfig(t *testing.T) {
+	level := testDefinition("Level", "memory", "%DF0", nil)
+	flow := testDefinition("Flow", "memory", "%DF4", nil)
+	pressure := testDefinition("Pressure", "memory", "%DF8", nil)
+	manager, tagManager := testManager(t, level, flow, pressure)
+
+	// Flow is unchanged, Pressure is changed, Level is removed and its address reused by Temp
+	changedPressure := pressure
+	changedPressure.Description = "changed"
+	temp := testDefinition("Temp", "memory", "%DF0", nil)
+	changes, err := manager.ApplyConfig(&config.SensorConfig{Sensors: []config.SensorDefinition{flow, changedPressure, temp}})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := ConfigChanges{Added: []string{"Temp"}, Removed: []string{"Level"}, Replaced: []string{"Pressure"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+	if tagManager.TagExists("Level") || !tagManager.TagExists("Temp") || manager.GetSensorCount() != 3 {
+		t.Error("sensors not changed to the configuration")
+	}
+
+	// Applying the same configuration changes nothing
+	if changes, err := manager.ApplyConfig(&config.SensorConfig{Sensors: []config.SensorDefinition{flow, changedPressure, temp}}); err != nil ||
+		!reflect.DeepEqual(changes, ConfigChanges{}) {
+		t.Errorf("reapplied changes = %+v, %v, want none", changes, err)
+	}
+}
+
+func TestSensorManagerWriteRange(t *testing.T) {
+	position := testDefinition("Servo", "servomotor", "%DF0", map[string]interface{}{"maxVelocity": 1500.0, "outputMode": "position"})
+	valve := testDefinition("Valve", "integer", "%DW4", map[string]interface{}{"minValue": 10.0, "maxValue": 90.0})
+	relay := testDefinition("Relay", "relay", "%MX0", nil)
+	temperature := testDefinition("Temp", "temperature", "%DF8", map[string]interface{}{"minValue": -20.0, "maxValue": 80.0})
+	memory := testDefinition("Memory", "memory", "%DF12", nil)
+	manager, _ := testManager(t, position, valve, relay, temperature, memory)
+
+	tests := []struct {
+		name      string
+		low, high float64
+		ok        bool
+	}{
+		// A position servo is commanded in RPM, not in degrees
+		{"Servo", -1500, 1500, true},
+		{"Valve", 10, 90, true},
+		{"Relay", 0, 0, false},
+		{"Temp", -20, 80, true},
+		{"Memory", 0, 0, false},
+		{"Missing", 0, 0, false},
+	}
+	for _, tt := range tests {
+		low, high, ok := manager.WriteRange(tt.name)
+		if low != tt.low || high != tt.high || ok != tt.ok {
+			t.Errorf("WriteRange(%s) = %v, %v, %v, want %v, %v, %v", tt.name, low, high, ok, tt.low, tt.high, tt.ok)
+		}
+	}
+}
+
+func TestSensorManagerCommands(t *testing.T) {
+	valve := testDefinition("Valve", "integer", "%DW0", map[string]interface{}{"maxValue": 100.0, "autoMode": true})
+	manager, tagManager := testManager(t, valve, testDefinition("Memory", "memory", "%DF4", nil))
+
+	// Commands are clamped and end the auto mode
+	if target, err := manager.CommandSensor("Valve", 150); err != nil || target != 100 {
+		t.Errorf("CommandSensor = %v, %v, want 100", target, err)
+	}
+	if manager.GetSensor("Valve").(*sensors.IntegerActuator).AutoMode {
+		t.Error("commanded actuator still in auto mode")
+	}
+	if _, err := manager.CommandSensor("Memory", 1); err == nil {
+		t.Error("commanding a memory sensor succeeded")
+	}
+
+	// A client write to the tag commands the actuator
+	if err := tagManager.WriteTagValue("Valve", int32(40)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if target := manager.GetSensor("Valve").(sensors.Actuator).Target(); target != 40 {
+		t.Errorf("target after a write = %v, want 40", target)
+	}
+}
@@ -29,6 +29,16 @@ func CreateSensor(def config.SensorDefinition) (sensors.Sensor, error) {
 		return nil, fmt.Errorf("failed to create sensor '%s': %w", def.Name, err)
 	}
 
+	// Array tags need a sensor producing arrays (or a memory variable) and vice versa
+	_, isArray := sensor.(sensors.ArraySensor)
+	_, isMemory := sensor.(sensors.MemoryVariable)
+	if isArray && def.ArrayLength == 0 {
+		return nil, fmt.Errorf("sensor '%s' of type %s requires arrayLength > 0", def.Name, def.Type)
+	}
+	if !isArray && !isMemory && def.ArrayLength > 0 {
+		return nil, fmt.Errorf("sensor '%s' of type %s does not produce arrays (arrayLength %d)", def.Name, def.Type, def.ArrayLength)
+	}
+
 	return sensor, nil
 }
 
@@ -130,6 +140,74 @@ func init() {
 		), nil
 	})
 
+	// Register Waveform array sensor
+	RegisterSensor("waveform", func(def config.SensorDefinition) (sensors.Sensor, error) {
+		shape := "sine"
+		if p, ok := def.Parameters["shape"].(string); ok {
+			shape = p
+		}
+		switch shape {
+		case "sine", "square", "sawtooth", "triangle":
+		default:
+			return nil, fmt.Errorf("unknown waveform shape: %s", shape)
+		}
+		offset := config.GetFloat64Param(def.Parameters, "offset", 0.0)
+		amplitude := config.GetFloat64Param(def.Parameters, "amplitude", 1.0)
+		cycles := config.GetFloat64Param(def.Parameters, "cycles", 1.0)
+		frequency := config.GetFloat64Param(def.Parameters, "frequency", 0.2)
+		noiseStdDev := config.GetFloat64Param(def.Parameters, "noiseStdDev", 0.0)
+
+		return sensors.NewWaveformSensor(
+			def.Name, def.Address, def.Enabled, def.UpdateIntervalMs, def.ArrayLength,
+			shape, offset, amplitude, cycles, frequency, noiseStdDev,
+			def.Description,
+		), nil
+	})
+
+	// Register Vibration waveform/spectrum array sensor
+	RegisterSensor("vibrationwaveform", func(def config.SensorDefinition) (sensors.Sensor, error) {
+		amplitude := config.GetFloat64Param(def.Parameters, "amplitude", 1.0)
+		frequency := config.GetFloat64Param(def.Parameters, "frequency", 50.0)
+		harmonics := int(config.GetFloat64Param(def.Parameters, "harmonics", 3))
+		spikeProb := config.GetFloat64Param(def.Parameters, "spikeProb", 0.01)
+		spikeAmp := config.GetFloat64Param(def.Parameters, "spikeAmp", 5.0)
+		noiseStdDev := config.GetFloat64Param(def.Parameters, "noiseStdDev", 0.2)
+		sampleRate := config.GetFloat64Param(def.Parameters, "sampleRate", 5120.0)
+		resonance := config.GetFloat64Param(def.Parameters, "resonance", 1200.0)
+		outputMode := "waveform"
+		if p, ok := def.Parameters["outputMode"].(string); ok {
+			outputMode = p
+		}
+		if sampleRate <= 0 {
+			return nil, fmt.Errorf("sampleRate must be positive: %g", sampleRate)
+		}
+		switch outputMode {
+		case "waveform":
+		case "spectrum":
+			if def.ArrayLength&(def.ArrayLength-1) != 0 {
+				return nil, fmt.Errorf("spectrum arrayLength must be a power of two: %d", def.ArrayLength)
+			}
+		default:
+			return nil, fmt.Errorf("unknown outputMode: %s (expected waveform or spectrum)", outputMode)
+		}
+
+		return sensors.NewVibrationWaveformSensor(
+			def.Name, def.Address, def.Enabled, def.UpdateIntervalMs, def.ArrayLength,
+			amplitude, frequency, harmonics, spikeProb, spikeAmp, noiseStdDev,
+			sampleRate, resonance, outputMode == "spectrum",
+			def.Description,
+		), nil
+	})
+
+	// Register Memory variable (no simulation, value set by clients or PLC logic)
+	RegisterSensor("memory", func(def config.SensorDefinition) (sensors.Sensor, error) {
+		return sensors.NewMemorySensor(
+			def.Name, def.Address, def.Enabled, def.UpdateIntervalMs,
+			def.Parameters["initialValue"],
+			def.Description,
+		), nil
+	})
+
 	// Register Noise sensor
 	RegisterSensor("noise", func(def config.SensorDefinition) (sensors.Sensor, error) {
 		ambientLevel := config.GetFloat64Param(def.Parameters, "ambientLevel", 55.0)
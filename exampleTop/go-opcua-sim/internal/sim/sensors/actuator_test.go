@@ -0,0 +1,83 @@
+package sensors
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServoMotorRanges(t *testing.T) {
+	tests := []struct {
+		outputMode        string
+		rangeLow, rangeHi float64
+	}{
+		{"velocity", -1500, 1500},
+		{"position", 0, 360},
+	}
+	for _, tt := range tests {
+		t.Run(tt.outputMode, func(t *testing.T) {
+			servo := NewServoMotor("Servo", "%DF0", true, 100, 1500, 10, 0.001, 0.01, tt.outputMode, true, "sine", 30, 0.5, 0.1, 0.01, "")
+			if low, high := servo.Range(); low != tt.rangeLow || high != tt.rangeHi {
+				t.Errorf("Range() = %v, %v, want %v, %v", low, high, tt.rangeLow, tt.rangeHi)
+			}
+			// The servo is commanded in RPM in both output modes
+			if low, high := servo.CommandRange(); low != -1500 || high != 1500 {
+				t.Errorf("CommandRange() = %v, %v, want -1500, 1500", low, high)
+			}
+			servo.Command(-750)
+			if servo.AutoMode || servo.Target() != -750 {
+				t.Errorf("after Command: auto mode %v, target %v, want manual -750", servo.AutoMode, servo.Target())
+			}
+		})
+	}
+}
+
+func TestIntegerActuatorCommand(t *testing.T) {
+	actuator := NewIntegerActuator("Valve", "%DW0", true, 100, 10, 90, 50, true, "step", 1, 1, "")
+	if low, high := actuator.CommandRange(); low != 10 || high != 90 {
+		t.Errorf("CommandRange() = %v, %v, want 10, 90", low, high)
+	}
+
+	tests := []struct {
+		command float64
+		want    float64
+	}{
+		{42.6, 43},
+		{5, 10},
+		{120, 90},
+	}
+	for _, tt := range tests {
+		actuator.Command(tt.command)
+		if got := actuator.Target(); got != tt.want {
+			t.Errorf("Command(%v): target %v, want %v", tt.command, got, tt.want)
+		}
+	}
+
+	// A commanded actuator keeps its value instead of following the auto pattern
+	if actuator.AutoMode {
+		t.Error("commanded actuator still in auto mode")
+	}
+	if got := actuator.Update(1500 * time.Millisecond); got != 90 {
+		t.Errorf("Update() = %v, want the commanded 90", got)
+	}
+
+	actuator.Reset()
+	if got := actuator.Target(); got != 50 {
+		t.Errorf("target after Reset = %v, want the default 50", got)
+	}
+}
+
+func TestRelayActuatorCommand(t *testing.T) {
+	relay := NewRelayActuator("Relay", "%MX0", true, 100, false, true, 1, "")
+	relay.Command(1)
+	if relay.AutoToggle || relay.Target() != 1 {
+		t.Errorf("after Command(1): auto toggle %v, target %v, want manual 1", relay.AutoToggle, relay.Target())
+	}
+	// The commanded state is kept over a toggle period
+	if got := relay.Update(2 * time.Second); got != 1 {
+		t.Errorf("Update() = %v, want the commanded 1", got)
+	}
+	relay.Command(0)
+	if got := relay.Target(); got != 0 {
+		t.Errorf("target after Command(0) = %v, want 0", got)
+	}
+}
@@ -12,7 +12,7 @@ type IntegerActuator struct {
 	MaxValue     int     // Maximum value
 	DefaultValue int     // Default value
 	AutoMode     bool    // Auto mode for testing
-	AutoPattern  string  // Pattern: "ramp", "sine", "step"
+	AutoPattern  string  // Pattern: "ramp", "sawtooth", "sine", "step"
 	RampRate     float64 // Ramp rate (units per second)
 	StepPeriod   float64 // Step period (seconds)
 	CurrentValue int     // Current value
@@ -71,6 +71,11 @@ func (i *IntegerActuator) Update(deltaTime time.Duration) float64 {
 				i.CurrentValue = i.MaxValue - int(float64(i.MaxValue-i.MinValue)*progress)
 			}
 
+		case "sawtooth":
+			// Count up from min to max, then wrap around to min
+			span := float64(i.MaxValue - i.MinValue + 1)
+			i.CurrentValue = i.MinValue + int(math.Mod(elapsed*i.RampRate, span))
+
 		case "sine":
 			// Sine wave between min and max
 			period := i.StepPeriod
@@ -113,6 +118,27 @@ func (i *IntegerActuator) SetValue(value int) {
 	i.TargetValue = value
 }
 
+// Command sets the actuator value from a written value
+func (i *IntegerActuator) Command(value float64) {
+	i.AutoMode = false
+	i.SetValue(int(math.Round(value)))
+}
+
+// Target returns the current actuator value
+func (i *IntegerActuator) Target() float64 {
+	return float64(i.CurrentValue)
+}
+
+// Range returns the value limits
+func (i *IntegerActuator) Range() (float64, float64) {
+	return float64(i.MinValue), float64(i.MaxValue)
+}
+
+// CommandRange returns the value limits
+func (i *IntegerActuator) CommandRange() (float64, float64) {
+	return float64(i.MinValue), float64(i.MaxValue)
+}
+
 // Reset resets the actuator to default value
 func (i *IntegerActuator) Reset() {
 	i.BaseSensor.Reset()
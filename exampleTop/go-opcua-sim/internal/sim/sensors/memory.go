@@ -0,0 +1,44 @@
+package sensors
+
+import (
+	"time"
+)
+
+// MemorySensor is a plain PLC memory variable (e.g. a test variable of any data type).
+// It generates no values; the tag only changes by client writes or PLC logic.
+type MemorySensor struct {
+	BaseSensor
+	Initial interface{} // Initial tag value (converted to the tag type), nil = zero value
+}
+
+// NewMemorySensor creates a new memory variable
+func NewMemorySensor(name, address string, enabled bool, updateIntervalMs int,
+	initial interface{}, description string) *MemorySensor {
+	return &MemorySensor{
+		BaseSensor: BaseSensor{
+			Name:             name,
+			Address:          address,
+			Enabled:          enabled,
+			UpdateIntervalMs: updateIntervalMs,
+			Description:      description,
+			ElapsedTime:      0,
+		},
+		Initial: initial,
+	}
+}
+
+// InitialValue returns the initial tag value
+func (m *MemorySensor) InitialValue() interface{} {
+	return m.Initial
+}
+
+// Update is not used for memory variables (the tag keeps its value)
+func (m *MemorySensor) Update(deltaTime time.Duration) float64 {
+	m.AddElapsedTime(deltaTime)
+	return 0.0
+}
+
+// Reset resets the sensor to initial state
+func (m *MemorySensor) Reset() {
+	m.BaseSensor.Reset()
+}
@@ -111,6 +111,11 @@ func (n *NoiseSensor) Update(deltaTime time.Duration) float64 {
 	return baseNoise
 }
 
+// Range returns the allowed noise level range (dB)
+func (n *NoiseSensor) Range() (float64, float64) {
+	return n.MinValue, n.MaxValue
+}
+
 // Reset resets the sensor to initial state
 func (n *NoiseSensor) Reset() {
 	n.BaseSensor.Reset()
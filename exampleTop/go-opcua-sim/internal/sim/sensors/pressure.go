@@ -103,6 +103,11 @@ func (p *PressureSensor) generateGaussianNoise() float64 {
 	return z0 * p.NoiseStdDev
 }
 
+// Range returns the pressure range of the cycle
+func (p *PressureSensor) Range() (float64, float64) {
+	return p.MinPressure, p.MaxPressure
+}
+
 // Reset resets the sensor to initial state
 func (p *PressureSensor) Reset() {
 	p.BaseSensor.Reset()
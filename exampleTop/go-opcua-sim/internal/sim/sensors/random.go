@@ -63,6 +63,11 @@ func (r *RandomSensor) Update(deltaTime time.Duration) float64 {
 	return r.CurrentValue
 }
 
+// Range returns the range of the random walk
+func (r *RandomSensor) Range() (float64, float64) {
+	return r.MinValue, r.MaxValue
+}
+
 // Reset resets the sensor to initial state
 func (r *RandomSensor) Reset() {
 	r.BaseSensor.Reset()
@@ -63,6 +63,20 @@ func (r *RelayActuator) SetState(state bool) {
 	r.CurrentState = state
 }
 
+// Command sets the relay state from a written value (non-zero = energized)
+func (r *RelayActuator) Command(value float64) {
+	r.AutoToggle = false
+	r.SetState(value != 0)
+}
+
+// Target returns the relay state (1 = energized)
+func (r *RelayActuator) Target() float64 {
+	if r.CurrentState {
+		return 1.0
+	}
+	return 0.0
+}
+
 // Reset resets the actuator to default state
 func (r *RelayActuator) Reset() {
 	r.BaseSensor.Reset()
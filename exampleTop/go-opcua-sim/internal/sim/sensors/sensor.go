@@ -1,6 +1,7 @@
 package sensors
 
 import (
+	"go-opcua-sim/internal/plc"
 	"math"
 	"math/rand"
 	"sync"
@@ -15,6 +16,9 @@ type Sensor interface {
 	// GetAddress returns the PLC address (e.g., "%DF100")
 	GetAddress() string
 
+	// GetUpdateInterval returns the period between two updates of the sensor value
+	GetUpdateInterval() time.Duration
+
 	// Update generates the next sensor value based on elapsed time
 	Update(deltaTime time.Duration) float64
 
@@ -23,6 +27,88 @@ type Sensor interface {
 
 	// IsEnabled returns whether the sensor is active
 	IsEnabled() bool
+
+	// SetEnabled starts or stops the sensor simulation
+	SetEnabled(enabled bool)
+
+	// Quality returns the quality of the sensor values (e.g. Bad_SensorFailure after a detected failure)
+	Quality() plc.Quality
+
+	// SetQuality sets the quality of the sensor values
+	SetQuality(quality plc.Quality)
+}
+
+// Actuator is a sensor that can be commanded by external clients (e.g. EPICS output records)
+type Actuator interface {
+	Sensor
+
+	// Command applies an externally written value to the actuator.
+	// An explicit command takes the actuator out of its automatic test pattern.
+	Command(value float64)
+
+	// Target returns the currently commanded value (after limits are applied)
+	Target() float64
+}
+
+// CommandRanger is an actuator that accepts commands within a known range
+type CommandRanger interface {
+	Actuator
+
+	// CommandRange returns the lowest and highest value Command accepts
+	CommandRange() (float64, float64)
+}
+
+// LoadController is a motor with a simulated external load
+type LoadController interface {
+	Sensor
+
+	// SetLoadTorque sets the external load torque (Nm)
+	SetLoadTorque(torque float64)
+}
+
+// ArraySensor is a sensor producing a one-dimensional array (e.g. a waveform) on every update
+type ArraySensor interface {
+	Sensor
+
+	// UpdateArray generates the next array based on elapsed time
+	UpdateArray(deltaTime time.Duration) []float64
+
+	// Length returns the number of array elements
+	Length() int
+}
+
+// RangedSensor is an analog sensor with a known output range (OPC UA InstrumentRange)
+type RangedSensor interface {
+	Sensor
+
+	// Range returns the lowest and highest value the sensor produces
+	Range() (float64, float64)
+}
+
+// MemoryVariable is a sensor without simulation: its tag holds the last value written
+// by clients or the PLC logic
+type MemoryVariable interface {
+	Sensor
+
+	// InitialValue returns the value the tag starts with and returns to on Reset (nil = zero value)
+	InitialValue() interface{}
+}
+
+// MotorState is the complete state of a motor, of which Update publishes a single value
+type MotorState struct {
+	Position float64 // Current position (degrees or steps)
+	Velocity float64 // Current velocity (RPM or steps/second)
+	Torque   float64 // Current motor torque (Nm), 0 for motors without torque model
+	Target   float64 // Commanded target (velocity or position)
+	Enabled  bool    // Simulation running
+}
+
+// StateReporter is a motor exposing its complete state as a structure
+type StateReporter interface {
+	Sensor
+
+	// State returns the current motor state
+	State() MotorState
 }
 
 // BaseSensor provides common functionality for all sensors
@@ -33,6 +119,7 @@ type BaseSensor struct {
 	UpdateIntervalMs  int
 	Description       string
 	ElapsedTime       float64 // seconds
+	quality           plc.Quality
 	mu                sync.RWMutex
 }
 
@@ -46,6 +133,11 @@ func (b *BaseSensor) GetAddress() string {
 	return b.Address
 }
 
+// GetUpdateInterval returns the configured update interval
+func (b *BaseSensor) GetUpdateInterval() time.Duration {
+	return time.Duration(b.UpdateIntervalMs) * time.Millisecond
+}
+
 // IsEnabled returns whether the sensor is enabled
 func (b *BaseSensor) IsEnabled() bool {
 	b.mu.RLock()
@@ -53,6 +145,27 @@ func (b *BaseSensor) IsEnabled() bool {
 	return b.Enabled
 }
 
+// SetEnabled sets the enabled state (thread-safe)
+func (b *BaseSensor) SetEnabled(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Enabled = enabled
+}
+
+// Quality returns the quality of the sensor values (thread-safe)
+func (b *BaseSensor) Quality() plc.Quality {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.quality
+}
+
+// SetQuality sets the quality of the sensor values (thread-safe)
+func (b *BaseSensor) SetQuality(quality plc.Quality) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.quality = quality
+}
+
 // AddElapsedTime adds time to the elapsed counter (thread-safe)
 func (b *BaseSensor) AddElapsedTime(deltaTime time.Duration) {
 	b.mu.Lock()
@@ -67,11 +180,12 @@ func (b *BaseSensor) GetElapsedTime() float64 {
 	return b.ElapsedTime
 }
 
-// Reset resets the base sensor state
+// Reset resets the base sensor state (the quality returns to Good)
 func (b *BaseSensor) Reset() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.ElapsedTime = 0
+	b.quality = plc.QualityGood
 }
 
 // gaussianNoise generates Gaussian noise with given mean and standard deviation
@@ -22,7 +22,6 @@ type ServoMotor struct {
 	AutoMode        bool    // Auto mode for demo
 	AutoPattern     string  // Pattern: "sine", "ramp", "step"
 	AutoPeriod      float64 // Period for auto pattern (seconds)
-	Enabled         bool    // Motor enabled state
 
 	// PID controller for velocity control
 	Kp              float64 // Proportional gain
@@ -213,9 +212,39 @@ func (s *ServoMotor) SetLoadTorque(torque float64) {
 	s.LoadTorque = torque
 }
 
-// SetEnabled sets the motor enabled state
-func (s *ServoMotor) SetEnabled(enabled bool) {
-	s.Enabled = enabled
+// Command sets the target velocity (RPM) from a written value
+func (s *ServoMotor) Command(value float64) {
+	s.AutoMode = false
+	s.SetTargetVelocity(value)
+}
+
+// Target returns the commanded target velocity (RPM)
+func (s *ServoMotor) Target() float64 {
+	return s.TargetVelocity
+}
+
+// CommandRange returns the target velocity range ±MaxVelocity (RPM), also in position output mode
+func (s *ServoMotor) CommandRange() (float64, float64) {
+	return -s.MaxVelocity, s.MaxVelocity
+}
+
+// State returns position, velocity, torque and target velocity
+func (s *ServoMotor) State() MotorState {
+	return MotorState{
+		Position: s.CurrentPosition,
+		Velocity: s.CurrentVelocity,
+		Torque:   s.CurrentTorque,
+		Target:   s.TargetVelocity,
+		Enabled:  s.IsEnabled(),
+	}
+}
+
+// Range returns the output range: ±MaxVelocity (RPM) or 0-360 degrees
+func (s *ServoMotor) Range() (float64, float64) {
+	if s.OutputMode == "position" {
+		return 0, 360
+	}
+	return -s.MaxVelocity, s.MaxVelocity
 }
 
 // Reset resets the motor to initial state
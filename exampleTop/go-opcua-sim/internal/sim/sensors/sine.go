@@ -47,6 +47,11 @@ func (s *SineSensor) Update(deltaTime time.Duration) float64 {
 	return value
 }
 
+// Range returns the peak values of the sine wave
+func (s *SineSensor) Range() (float64, float64) {
+	return s.Offset - math.Abs(s.Amplitude), s.Offset + math.Abs(s.Amplitude)
+}
+
 // Reset resets the sensor to initial state
 func (s *SineSensor) Reset() {
 	s.BaseSensor.Reset()
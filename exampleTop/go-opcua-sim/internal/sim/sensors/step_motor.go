@@ -18,7 +18,6 @@ type StepMotor struct {
 	AutoMode        bool    // Auto mode for demo
 	AutoPattern     string  // Pattern: "oscillate", "rotate", "step"
 	AutoPeriod      float64 // Period for auto pattern (seconds)
-	Enabled         bool    // Motor enabled state
 }
 
 // NewStepMotor creates a new step motor
@@ -155,9 +154,30 @@ func (s *StepMotor) SetTargetPosition(position float64) {
 	s.TargetPosition = position
 }
 
-// SetEnabled sets the motor enabled state
-func (s *StepMotor) SetEnabled(enabled bool) {
-	s.Enabled = enabled
+// Command moves the motor to the written target position (steps)
+func (s *StepMotor) Command(value float64) {
+	s.AutoMode = false
+	s.SetTargetPosition(value)
+}
+
+// Target returns the commanded target position (steps)
+func (s *StepMotor) Target() float64 {
+	return s.TargetPosition
+}
+
+// State returns position, velocity and target position (a step motor has no torque model)
+func (s *StepMotor) State() MotorState {
+	return MotorState{
+		Position: s.CurrentPosition,
+		Velocity: s.CurrentVelocity,
+		Target:   s.TargetPosition,
+		Enabled:  s.IsEnabled(),
+	}
+}
+
+// Range returns the position range of one revolution (steps)
+func (s *StepMotor) Range() (float64, float64) {
+	return 0, float64(s.StepsPerRev)
 }
 
 // Reset resets the motor to initial state
@@ -85,6 +85,11 @@ func (t *TemperatureSensor) generateGaussianNoise() float64 {
 	return z0 * t.NoiseStdDev
 }
 
+// Range returns the allowed temperature range
+func (t *TemperatureSensor) Range() (float64, float64) {
+	return t.MinValue, t.MaxValue
+}
+
 // Reset resets the sensor to initial state
 func (t *TemperatureSensor) Reset() {
 	t.BaseSensor.Reset()
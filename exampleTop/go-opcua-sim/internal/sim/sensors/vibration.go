@@ -103,6 +103,11 @@ func (v *VibrationSensor) Update(deltaTime time.Duration) float64 {
 	return vibration
 }
 
+// Range returns the allowed vibration range
+func (v *VibrationSensor) Range() (float64, float64) {
+	return v.MinValue, v.MaxValue
+}
+
 // Reset resets the sensor to initial state
 func (v *VibrationSensor) Reset() {
 	v.BaseSensor.Reset()
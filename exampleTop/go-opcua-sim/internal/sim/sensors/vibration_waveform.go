@@ -0,0 +1,189 @@
+package sensors
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// VibrationWaveformSensor samples the vibration signal of a VibrationSensor at a fixed sample rate
+// and publishes either the time waveform (acceleration) or its amplitude spectrum (FFT)
+type VibrationWaveformSensor struct {
+	VibrationSensor
+	SampleRate float64 // Samples per second of the waveform
+	Resonance  float64 // Ringing frequency of impacts (Hz)
+	Spectrum   bool    // Publish the amplitude spectrum instead of the waveform
+	length     int
+	last       []float64
+	rms        float64
+}
+
+// NewVibrationWaveformSensor creates a new vibration waveform/spectrum sensor.
+// A spectrum of length bins is computed from 2*length samples, so bin k is k*sampleRate/(2*length) Hz.
+func NewVibrationWaveformSensor(name, address string, enabled bool, updateIntervalMs, length int,
+	amplitude, frequency float64, harmonics int, spikeProb, spikeAmp, noiseStdDev,
+	sampleRate, resonance float64, spectrum bool, description string) *VibrationWaveformSensor {
+	return &VibrationWaveformSensor{
+		VibrationSensor: VibrationSensor{
+			BaseSensor: BaseSensor{
+				Name:             name,
+				Address:          address,
+				Enabled:          enabled,
+				UpdateIntervalMs: updateIntervalMs,
+				Description:      description,
+				ElapsedTime:      0,
+			},
+			Amplitude:   amplitude,
+			Frequency:   frequency,
+			Harmonics:   harmonics,
+			SpikeProb:   spikeProb,
+			SpikeAmp:    spikeAmp,
+			NoiseStdDev: noiseStdDev,
+		},
+		SampleRate: sampleRate,
+		Resonance:  resonance,
+		Spectrum:   spectrum,
+		length:     length,
+		last:       make([]float64, length),
+	}
+}
+
+// Length returns the number of array elements
+func (v *VibrationWaveformSensor) Length() int {
+	return v.length
+}
+
+// UpdateArray samples the latest window of the vibration signal
+func (v *VibrationWaveformSensor) UpdateArray(deltaTime time.Duration) []float64 {
+	if !v.IsEnabled() {
+		return v.last
+	}
+
+	v.AddElapsedTime(deltaTime)
+	elapsed := v.GetElapsedTime()
+
+	// Impacts happen at a random time within the last update interval
+	if rand.Float64() < v.SpikeProb {
+		v.lastSpikeTime = elapsed - rand.Float64()*deltaTime.Seconds()
+		v.spikeDecay = v.SpikeAmp
+	}
+
+	count := v.length
+	if v.Spectrum {
+		count = 2 * v.length
+	}
+
+	// Window of samples ending at the current simulation time
+	samples := make([]float64, count)
+	var sumSquares float64
+	for i := range samples {
+		samples[i] = v.signal(elapsed - float64(count-1-i)/v.SampleRate)
+		sumSquares += samples[i] * samples[i]
+	}
+	v.rms = math.Sqrt(sumSquares / float64(count))
+
+	if v.Spectrum {
+		v.last = amplitudeSpectrum(samples)
+	} else {
+		v.last = samples
+	}
+	return v.last
+}
+
+// signal returns the vibration signal at time t: harmonics, decaying impact ringing and noise
+func (v *VibrationWaveformSensor) signal(t float64) float64 {
+	value := 0.0
+	for i := 1; i <= v.Harmonics; i++ {
+		value += v.Amplitude / float64(i) * math.Sin(2.0*math.Pi*v.Frequency*float64(i)*t)
+	}
+
+	if v.spikeDecay > 0 && t >= v.lastSpikeTime {
+		sinceSpike := t - v.lastSpikeTime
+		value += v.SpikeAmp * math.Exp(-sinceSpike*5.0) * math.Sin(2.0*math.Pi*v.Resonance*sinceSpike)
+	}
+
+	if v.NoiseStdDev > 0 {
+		value += gaussianNoise(0, v.NoiseStdDev)
+	}
+	return value
+}
+
+// Update samples the next window and returns the RMS level of the waveform
+func (v *VibrationWaveformSensor) Update(deltaTime time.Duration) float64 {
+	v.UpdateArray(deltaTime)
+	return v.rms
+}
+
+// Range returns the peak range of the waveform (harmonics plus impact ringing) or of the spectrum amplitudes
+func (v *VibrationWaveformSensor) Range() (float64, float64) {
+	peak := v.SpikeAmp
+	for i := 1; i <= v.Harmonics; i++ {
+		peak += v.Amplitude / float64(i)
+	}
+	if v.Spectrum {
+		return 0, peak
+	}
+	return -peak, peak
+}
+
+// Reset resets the sensor to initial state
+func (v *VibrationWaveformSensor) Reset() {
+	v.VibrationSensor.Reset()
+	v.last = make([]float64, v.length)
+	v.rms = 0
+}
+
+// amplitudeSpectrum returns the single-sided amplitude spectrum (len/2 bins) of a Hann-windowed signal.
+// The number of samples must be a power of two.
+func amplitudeSpectrum(samples []float64) []float64 {
+	n := len(samples)
+	re := make([]float64, n)
+	im := make([]float64, n)
+
+	var gain float64
+	for i, x := range samples {
+		window := 0.5 - 0.5*math.Cos(2.0*math.Pi*float64(i)/float64(n))
+		re[i] = x * window
+		gain += window
+	}
+	fft(re, im)
+
+	bins := make([]float64, n/2)
+	for k := range bins {
+		bins[k] = 2.0 * math.Hypot(re[k], im[k]) / gain
+	}
+	bins[0] /= 2.0 // DC is not mirrored
+	return bins
+}
+
+// fft computes an in-place iterative radix-2 Cooley-Tukey FFT (len must be a power of two)
+func fft(re, im []float64) {
+	n := len(re)
+
+	// Bit-reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		angle := -2.0 * math.Pi / float64(size)
+		for start := 0; start < n; start += size {
+			for k := 0; k < size/2; k++ {
+				wr, wi := math.Cos(angle*float64(k)), math.Sin(angle*float64(k))
+				a, b := start+k, start+k+size/2
+				tr := re[b]*wr - im[b]*wi
+				ti := re[b]*wi + im[b]*wr
+				re[b], im[b] = re[a]-tr, im[a]-ti
+				re[a], im[a] = re[a]+tr, im[a]+ti
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sensors
+
+import (
+	"math"
+	"time"
+)
+
+// WaveformSensor generates an array holding a scrolling periodic signal (function generator)
+// Element i = Offset + Amplitude * shape(Cycles*i/Length + Frequency*t) + noise
+type WaveformSensor struct {
+	BaseSensor
+	Shape       string  // "sine", "square", "sawtooth" or "triangle"
+	Offset      float64 // DC offset
+	Amplitude   float64 // Amplitude of the signal
+	Cycles      float64 // Signal periods across the whole array
+	Frequency   float64 // Scroll rate of the signal phase (Hz)
+	NoiseStdDev float64 // Standard deviation of Gaussian noise per element
+	length      int
+	last        []float64
+}
+
+// NewWaveformSensor creates a new waveform array sensor
+func NewWaveformSensor(name, address string, enabled bool, updateIntervalMs, length int,
+	shape string, offset, amplitude, cycles, frequency, noiseStdDev float64, description string) *WaveformSensor {
+	return &WaveformSensor{
+		BaseSensor: BaseSensor{
+			Name:             name,
+			Address:          address,
+			Enabled:          enabled,
+			UpdateIntervalMs: updateIntervalMs,
+			Description:      description,
+			ElapsedTime:      0,
+		},
+		Shape:       shape,
+		Offset:      offset,
+		Amplitude:   amplitude,
+		Cycles:      cycles,
+		Frequency:   frequency,
+		NoiseStdDev: noiseStdDev,
+		length:      length,
+		last:        make([]float64, length),
+	}
+}
+
+// Length returns the number of array elements
+func (w *WaveformSensor) Length() int {
+	return w.length
+}
+
+// UpdateArray generates the next waveform
+func (w *WaveformSensor) UpdateArray(deltaTime time.Duration) []float64 {
+	if !w.IsEnabled() {
+		return w.last
+	}
+
+	w.AddElapsedTime(deltaTime)
+	elapsed := w.GetElapsedTime()
+
+	values := make([]float64, w.length)
+	for i := range values {
+		phase := w.Cycles*float64(i)/float64(w.length) + w.Frequency*elapsed
+		values[i] = w.Offset + w.Amplitude*waveShape(w.Shape, phase)
+		if w.NoiseStdDev > 0 {
+			values[i] += gaussianNoise(0, w.NoiseStdDev)
+		}
+	}
+
+	w.last = values
+	return values
+}
+
+// Update generates the next waveform and returns its first element
+func (w *WaveformSensor) Update(deltaTime time.Duration) float64 {
+	return w.UpdateArray(deltaTime)[0]
+}
+
+// Range returns the peak values of the signal (without noise)
+func (w *WaveformSensor) Range() (float64, float64) {
+	return w.Offset - math.Abs(w.Amplitude), w.Offset + math.Abs(w.Amplitude)
+}
+
+// Reset resets the sensor to initial state
+func (w *WaveformSensor) Reset() {
+	w.BaseSensor.Reset()
+	w.last = make([]float64, w.length)
+}
+
+// waveShape evaluates a unit periodic signal (-1..1) at phase (in periods)
+func waveShape(shape string, phase float64) float64 {
+	frac := phase - math.Floor(phase)
+	switch shape {
+	case "square":
+		if frac < 0.5 {
+			return 1
+		}
+		return -1
+	case "sawtooth":
+		return 2*frac - 1
+	case "triangle":
+		return 1 - 4*math.Abs(frac-0.5)
+	default: // sine
+		return math.Sin(2.0 * math.Pi * frac)
+	}
+}
@@ -1,23 +1,37 @@
 #!/bin/bash
 # Setup PKI (Public Key Infrastructure) for OPC UA server
+# Usage: ./setup_pki.sh [pki_dir]
 
 set -e
 
-echo "Setting up PKI for OPC UA server..."
+PKI_DIR="${1:-pki}"
 
-# Create PKI directory structure
-mkdir -p pki
+echo "Setting up PKI for OPC UA server in ${PKI_DIR}..."
+
+# Create PKI directory structure (same layout as the devOpcua certificate store)
+mkdir -p "${PKI_DIR}/trusted/certs" "${PKI_DIR}/trusted/crl" \
+         "${PKI_DIR}/issuers/certs" "${PKI_DIR}/issuers/crl" \
+         "${PKI_DIR}/rejected"
 
 # Generate private key
-openssl genrsa -out pki/server.key 2048
+openssl genrsa -out "${PKI_DIR}/server.key" 2048
 
 # Generate self-signed certificate
-openssl req -new -x509 -key pki/server.key -out pki/server.crt -days 365 \
-  -subj "/C=US/ST=State/L=City/O=go-opcua-sim/CN=localhost"
+# The ApplicationURI must be in subjectAltName for secure endpoints
+openssl req -new -x509 -key "${PKI_DIR}/server.key" -out "${PKI_DIR}/server.crt" -days 365 \
+  -subj "/C=US/ST=State/L=City/O=go-opcua-sim/CN=localhost" \
+  -addext "subjectAltName=URI:urn:go-opcua-sim,DNS:localhost,DNS:$(hostname)" \
+  -addext "keyUsage=critical,digitalSignature,nonRepudiation,keyEncipherment,dataEncipherment,keyCertSign" \
+  -addext "extendedKeyUsage=serverAuth,clientAuth"
 
 echo "✓ PKI setup complete"
 echo ""
 echo "Created files:"
-echo "  - pki/server.key (private key)"
-echo "  - pki/server.crt (certificate)"
+echo "  - ${PKI_DIR}/server.key (private key)"
+echo "  - ${PKI_DIR}/server.crt (certificate)"
+echo "  - ${PKI_DIR}/trusted/certs   (trusted client/CA certificates)"
+echo "  - ${PKI_DIR}/trusted/crl     (CA revocation lists)"
+echo "  - ${PKI_DIR}/issuers/certs   (intermediate issuer certificates)"
+echo "  - ${PKI_DIR}/issuers/crl     (issuer revocation lists)"
+echo "  - ${PKI_DIR}/rejected        (rejected client certificates, strict mode)"
 echo ""